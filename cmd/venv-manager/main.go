@@ -4,6 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/jacopobonomi/venv-manager/internal/config"
 	"github.com/jacopobonomi/venv-manager/internal/manager"
@@ -21,15 +27,29 @@ const (
 )
 
 var (
-	globalFlag bool
-	jsonFlag   bool
-	mgr        *manager.Manager
-	cfg        *config.Config
+	globalFlag  bool
+	jsonFlag    bool
+	jobsFlag    int
+	offlineFlag bool
+	lockTimeout time.Duration
+	mgr         *manager.Manager
+	cfg         *config.Config
 
 	rootCmd = &cobra.Command{
 		Use:   "venv-manager",
 		Short: "A powerful CLI tool for managing Python virtual environments",
 		Long:  "venv-manager creates, manages, and works with Python virtual environments.",
+		PersistentPreRun: func(c *cobra.Command, _ []string) {
+			if c.Flags().Changed("jobs") {
+				mgr.SetJobs(jobsFlag)
+			}
+			if c.Flags().Changed("offline") {
+				mgr.SetOffline(offlineFlag)
+			}
+			if c.Flags().Changed("lock-timeout") {
+				mgr.SetLockTimeout(lockTimeout)
+			}
+		},
 	}
 )
 
@@ -40,22 +60,49 @@ func init() {
 		fmt.Fprintf(os.Stderr, "%swarning: failed to load config: %v%s\n", colorYellow, err, colorReset)
 		cfg = &config.Config{}
 	}
+	if err := manager.ValidateInstaller(cfg.Installer); err != nil {
+		fmt.Fprintf(os.Stderr, "%swarning: %v, using pip%s\n", colorYellow, err, colorReset)
+	}
 	mgr = manager.NewWithOptions(manager.Options{
 		BaseDir:       cfg.BaseDir,
 		DefaultPython: cfg.DefaultPython,
 		UseUv:         cfg.UseUv,
+		Jobs:          cfg.Jobs,
+		Wheelhouse:    cfg.Wheelhouse,
+		Offline:       cfg.Offline,
+		Installer:     cfg.Installer,
+		PythonDirs:    cfg.PythonDirs,
+		LockTimeout:   time.Duration(cfg.LockTimeoutSeconds) * time.Second,
+		Index: manager.IndexConfig{
+			IndexURL:       cfg.IndexURL,
+			ExtraIndexURLs: cfg.ExtraIndexURLs,
+			TrustedHosts:   cfg.TrustedHosts,
+			Cert:           cfg.Cert,
+			ClientCert:     cfg.ClientCert,
+			Env:            cfg.PipEnv,
+		},
+		AutoSnapshot: cfg.AutoSnapshot,
+		Retention: manager.RetentionPolicy{
+			KeepLast:   cfg.SnapshotKeepLast,
+			MaxAgeDays: cfg.SnapshotMaxAgeDays,
+		},
 	})
 
 	rootCmd.PersistentFlags().BoolVar(&globalFlag, "global", false, "Apply command to all environments")
 	rootCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "Output as JSON")
+	rootCmd.PersistentFlags().BoolVar(&offlineFlag, "offline", false, "Install packages only from the local wheelhouse, never from an index")
+	rootCmd.PersistentFlags().IntVarP(&jobsFlag, "jobs", "j", 0, "Venvs to process in parallel with --global (default: config jobs, else one per CPU)")
+	rootCmd.PersistentFlags().DurationVar(&lockTimeout, "lock-timeout", 0, "How long to wait for a venv another process is changing (default: config lock_timeout_seconds, else 30s)")
 
 	rootCmd.AddCommand(
-		createCmd(), listCmd(), removeCmd(), renameCmd(), cloneCmd(),
+		createCmd(), listCmd(), removeCmd(), renameCmd(), moveCmd(), cloneCmd(),
 		packagesCmd(), installCmd(), upgradeCmd(), cleanCmd(),
 		activateCmd(), deactivateCmd(), sizeCmd(),
 		runCmd(), doctorCmd(), pruneCmd(), exportCmd(), importCmd(),
 		configCmd(), tuiCmd(), describeCmd(), execCmd(), mcpCmd(),
 		snapshotCmd(), snapshotsCmd(), rollbackCmd(), scanCmd(), watchCmd(),
+		depsCmd(), whyCmd(), autoremoveCmd(), diffCmd(), dedupeCmd(),
+		wheelhouseCmd(), indexCmd(), pythonsCmd(), repythonCmd(), repairCmd(), locksCmd(),
 		completionCmd(),
 	)
 }
@@ -68,6 +115,8 @@ func die(err error) {
 func printJSON(v any) {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
+	// Keep specifiers such as ">=3.10" readable.
+	enc.SetEscapeHTML(false)
 	if err := enc.Encode(v); err != nil {
 		die(err)
 	}
@@ -78,15 +127,46 @@ func createCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "create <name>",
 		Short: "Create a new virtual environment",
-		Args:  cobra.ExactArgs(1),
+		Long: `Create a new virtual environment.
+
+--python takes a version ("3", "3.12", "3.11.*"), a PEP 440 range
+(">=3.10,<3.13"), an implementation ("pypy3.10") or a path, and picks the
+newest matching interpreter found by 'venv-manager pythons'. Without it, the
+nearest .python-version file or pyproject.toml requires-python (searched
+upwards from the current directory) is used, then default_python.`,
+		Args: cobra.ExactArgs(1),
 		Run: func(_ *cobra.Command, args []string) {
-			if err := mgr.Create(args[0], pythonVersion); err != nil {
+			opts := manager.CreateOptions{Python: pythonVersion}
+			if opts.Python == "" {
+				cwd, err := os.Getwd()
+				if err != nil {
+					die(err)
+				}
+				var warnings []string
+				opts.Python, opts.PythonSource, opts.PythonSourcePath, warnings, err = manager.ProjectPython(cwd)
+				if err != nil {
+					die(err)
+				}
+				for _, w := range warnings {
+					fmt.Fprintf(os.Stderr, "%swarning: %s%s\n", colorYellow, w, colorReset)
+				}
+			}
+			if err := mgr.CreateWithOptions(args[0], opts); err != nil {
 				die(err)
 			}
 			fmt.Printf("%s✨ Created virtual environment '%s'%s\n", colorGreen, args[0], colorReset)
+			if c, err := mgr.VenvPythonChoice(args[0]); err == nil && c != nil && c.Version != "" {
+				from := ""
+				if c.SourcePath != "" {
+					from = fmt.Sprintf(" (%s from %s)", c.Requested, c.SourcePath)
+				} else if c.Requested != "" {
+					from = fmt.Sprintf(" (%s from %s)", c.Requested, c.Source)
+				}
+				fmt.Printf("   Python %s%s\n", c.Version, from)
+			}
 		},
 	}
-	cmd.Flags().StringVar(&pythonVersion, "python", "", "Python version to use (e.g. 3.12)")
+	cmd.Flags().StringVar(&pythonVersion, "python", "", `Python to use: version, range or path (e.g. 3.12, ">=3.10,<3.13", pypy3.10)`)
 	return cmd
 }
 
@@ -143,6 +223,29 @@ func renameCmd() *cobra.Command {
 	}
 }
 
+func moveCmd() *cobra.Command {
+	var to string
+	c := &cobra.Command{
+		Use:   "move <name> --to <dir>",
+		Short: "Move a virtual environment to another base directory",
+		Long: `Moves a venv to <dir>/<name> and rewrites the absolute paths it records
+(console-script shebangs, activate scripts, pyvenv.cfg, .pth files and
+direct_url.json), so it keeps working from there. Across filesystems the venv
+is copied and the original removed once the copy is in place.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			dst, err := mgr.Move(args[0], to)
+			if err != nil {
+				die(err)
+			}
+			fmt.Printf("%s🚚 Moved '%s' to %s%s\n", colorGreen, args[0], dst, colorReset)
+		},
+	}
+	c.Flags().StringVar(&to, "to", "", "Base directory to move the venv into")
+	c.MarkFlagRequired("to")
+	return c
+}
+
 func cloneCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "clone <source> <target>",
@@ -179,6 +282,155 @@ func packagesCmd() *cobra.Command {
 	}
 }
 
+func depsCmd() *cobra.Command {
+	var tree bool
+	cmd := &cobra.Command{
+		Use:   "deps <name>",
+		Short: "Show the installed dependency graph of a venv",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			g, err := mgr.DependencyGraph(args[0])
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(g)
+				return
+			}
+			if tree {
+				g.WriteTree(os.Stdout)
+				return
+			}
+			fmt.Printf("%s🔗 Dependencies in '%s':%s\n", colorYellow, args[0], colorReset)
+			for _, n := range g.Packages {
+				fmt.Printf("%s==%s\n", n.Name, n.Version)
+				for _, d := range n.Requires {
+					spec := d.Specifier
+					if spec == "" {
+						spec = "any"
+					}
+					switch {
+					case d.Missing:
+						fmt.Printf("  %s- %s %s (missing)%s\n", colorRed, d.Name, spec, colorReset)
+					case d.Conflict:
+						fmt.Printf("  %s- %s %s (installed %s)%s\n", colorRed, d.Name, spec, d.Installed, colorReset)
+					default:
+						fmt.Printf("  - %s %s\n", d.Name, spec)
+					}
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&tree, "tree", false, "Render as a tree rooted at top-level packages")
+	return cmd
+}
+
+func whyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "why <name> <package>",
+		Short: "Show which top-level packages pull in a package",
+		Args:  cobra.ExactArgs(2),
+		Run: func(_ *cobra.Command, args []string) {
+			rep, err := mgr.Why(args[0], args[1])
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(rep)
+				return
+			}
+			fmt.Print(rep.String())
+		},
+	}
+}
+
+func autoremoveCmd() *cobra.Command {
+	var (
+		reqFile string
+		dryRun  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "autoremove <name>",
+		Short: "Uninstall packages no top-level requirement depends on",
+		Long: `Finds installed packages that no declared top-level package reaches
+through the dependency graph and uninstalls them. Declared packages are those
+pip marked as explicitly requested, or the entries of --requirements.
+A snapshot labelled "autoremove" is taken first, so 'rollback' undoes it.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			rep, err := mgr.Autoremove(args[0], manager.AutoremoveOptions{Requirements: reqFile, DryRun: dryRun})
+			if err != nil {
+				die(err)
+			}
+			for _, n := range rep.Missing {
+				fmt.Fprintf(os.Stderr, "%swarning: '%s' is declared in %s but not installed%s\n", colorYellow, n, reqFile, colorReset)
+			}
+			if jsonFlag {
+				printJSON(rep)
+				return
+			}
+			if len(rep.Orphans) == 0 {
+				fmt.Printf("%s✨ No orphaned packages in '%s'%s\n", colorGreen, args[0], colorReset)
+				return
+			}
+			if dryRun {
+				fmt.Printf("%s🧹 Would remove from '%s':%s\n", colorYellow, args[0], colorReset)
+			} else {
+				fmt.Printf("%s🧹 Removed from '%s' (snapshot %s):%s\n", colorGreen, args[0], rep.Snapshot.ID, colorReset)
+			}
+			for _, o := range rep.Orphans {
+				fmt.Printf("- %s\n", o)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&reqFile, "requirements", "r", "", "Requirements file listing the top-level packages to keep")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report; do not uninstall")
+	return cmd
+}
+
+func diffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <a> <b>",
+		Short: "Compare two venvs, snapshots or manifests",
+		Long: `Each side can be a venv name, a snapshot reference (snapshot:<venv>/<id>)
+or the path of a manifest written by 'export'. Changes are reported from <a> to <b>.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(_ *cobra.Command, args []string) {
+			d, err := mgr.Diff(args[0], args[1])
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(d)
+				return
+			}
+			fmt.Printf("%s🔀 %s → %s%s\n", colorYellow, d.Left, d.Right, colorReset)
+			if d.Identical() {
+				fmt.Printf("%s✅ No differences%s\n", colorGreen, colorReset)
+				return
+			}
+			if d.PythonChanged {
+				fmt.Printf("  python      %s → %s\n", d.LeftPython, d.RightPython)
+			}
+			for _, c := range d.Added {
+				fmt.Printf("%s+ added       %-30s %s%s\n", colorGreen, c.Name, c.To, colorReset)
+			}
+			for _, c := range d.Removed {
+				fmt.Printf("%s- removed     %-30s %s%s\n", colorRed, c.Name, c.From, colorReset)
+			}
+			for _, c := range d.Upgraded {
+				fmt.Printf("↑ upgraded    %-30s %s → %s\n", c.Name, c.From, c.To)
+			}
+			for _, c := range d.Downgraded {
+				fmt.Printf("%s↓ downgraded  %-30s %s → %s%s\n", colorYellow, c.Name, c.From, c.To, colorReset)
+			}
+			for _, c := range d.Changed {
+				fmt.Printf("~ changed     %-30s %s → %s\n", c.Name, c.From, c.To)
+			}
+		},
+	}
+}
+
 func installCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "install <name> <requirements-file>",
@@ -194,39 +446,604 @@ func installCmd() *cobra.Command {
 }
 
 func upgradeCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		opts             manager.UpgradeOptions
+		include, exclude string
+	)
+	cmd := &cobra.Command{
 		Use:   "upgrade [name]",
 		Short: "Upgrade packages in an environment",
+		Long: `Upgrades outdated packages. Each change is classified as a major, minor or
+patch upgrade. --only patch|minor caps upgrades at that level: packages whose
+latest release is beyond the cap are upgraded to the newest release within it.
+Use --dry-run to print the plan without changing anything.
+
+With --verify "<cmd>", the venv is snapshotted first; after upgrading,
+'pip check' and <cmd> run inside the venv, and if either fails the venv is
+rolled back to the snapshot. The exit status is non-zero on any failure.`,
 		Run: func(_ *cobra.Command, args []string) {
 			mgr.SetGlobal(globalFlag)
 			name := ""
 			if len(args) > 0 && !globalFlag {
 				name = args[0]
 			}
-			if err := mgr.Upgrade(name); err != nil {
+			opts.Include, opts.Exclude = splitList(include), splitList(exclude)
+			plans, err := mgr.UpgradeWithOptions(name, opts)
+			if err != nil {
+				die(err)
+			}
+			rep := manager.UpgradeReport(plans)
+			if jsonFlag {
+				printJSON(plans)
+				if rep.Failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+			for _, p := range plans {
+				printUpgradePlan(p, opts.DryRun)
+			}
+			if len(plans) > 1 {
+				printBulkReport(rep)
+			}
+			if err := rep.Err(); err != nil {
 				die(err)
 			}
-			fmt.Printf("%s⬆️  Packages upgraded successfully%s\n", colorGreen, colorReset)
+			if !opts.DryRun {
+				fmt.Printf("%s⬆️  Packages upgraded successfully%s\n", colorGreen, colorReset)
+			}
 		},
 	}
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Print the current→latest plan without upgrading")
+	cmd.Flags().StringVar(&opts.Only, "only", "", "Cap upgrades at this level: patch or minor")
+	cmd.Flags().StringVar(&include, "include", "", "Comma-separated packages to upgrade (default all)")
+	cmd.Flags().StringVar(&exclude, "exclude", "", "Comma-separated packages to leave alone")
+	cmd.Flags().StringVar(&opts.Verify, "verify", "", "Command to run after upgrading; roll back if it or 'pip check' fails")
+	return cmd
+}
+
+func printUpgradePlan(p *manager.UpgradePlan, dryRun bool) {
+	if len(p.Changes) == 0 && p.Error == "" {
+		fmt.Printf("%s✅ '%s' is up to date%s\n", colorGreen, p.Venv, colorReset)
+		return
+	}
+	title := "Upgrade"
+	if dryRun {
+		title = "Upgrade plan"
+	}
+	fmt.Printf("%s⬆️  %s for '%s':%s\n", colorYellow, title, p.Venv, colorReset)
+	if len(p.Changes) > 0 {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "PACKAGE\tCURRENT\tLATEST\tLEVEL\tACTION")
+		for _, c := range p.Changes {
+			action := "→ " + c.Latest
+			switch {
+			case c.Skipped != "":
+				action = "skip (" + c.Skipped + ")"
+			case c.Error != "":
+				action = "failed"
+			case c.Capped:
+				action = "→ " + strings.TrimPrefix(c.Target, c.Name) + " (capped)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Name, c.Current, c.Latest, c.Level, action)
+		}
+		tw.Flush()
+	}
+	if v := p.Verification; v != nil {
+		if len(v.Changed) > 0 {
+			fmt.Println("Changed packages:")
+			for _, c := range v.Changed {
+				fmt.Printf("  %s %s → %s\n", c.Name, orDash(c.From), orDash(c.To))
+			}
+		}
+		switch {
+		case v.Passed:
+			fmt.Printf("%s✅ Verified: pip check and '%s' passed%s\n", colorGreen, v.Command, colorReset)
+		case v.RolledBack:
+			fmt.Printf("%s❌ %s failed; rolled back to snapshot %s%s\n", colorRed, v.FailedStep, v.Snapshot, colorReset)
+		default:
+			fmt.Printf("%s❌ %s failed and rollback to %s failed too%s\n", colorRed, v.FailedStep, v.Snapshot, colorReset)
+		}
+		if v.Output != "" {
+			fmt.Println(v.Output)
+		}
+	}
+	if p.Error != "" {
+		fmt.Printf("%s%s%s\n", colorRed, p.Error, colorReset)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
 
 func cleanCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		opts manager.CleanOptions
+		keep string
+	)
+	cmd := &cobra.Command{
 		Use:   "clean [name]",
 		Short: "Clean cache files",
+		Long: `Purges the pip cache and __pycache__ dirs.
+
+With --slim, installed packages are also stripped of tests/, test/, docs/,
+doc/, examples/ and example/ dirs, stale .pyc files (source changed or gone,
+or built for another interpreter) and .dist-info files not needed at runtime.
+Entry point and top-level modules are never removed; --keep protects whole
+packages. Use --dry-run to list what would go first.`,
+		Run: func(_ *cobra.Command, args []string) {
+			mgr.SetGlobal(globalFlag)
+			name := ""
+			if len(args) > 0 && !globalFlag {
+				name = args[0]
+			}
+			opts.Keep = splitList(keep)
+			rep, err := mgr.CleanWithOptions(name, opts)
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(rep)
+				if rep.Failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+			if opts.Slim {
+				for _, res := range rep.Results {
+					printSlimResult(res, opts.DryRun)
+				}
+			}
+			if len(rep.Results) > 1 {
+				printBulkReport(rep)
+			}
+			if err := rep.Err(); err != nil {
+				die(err)
+			}
+			if !opts.DryRun {
+				fmt.Printf("%s🧹 Environment cleaned successfully%s\n", colorGreen, colorReset)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&opts.Slim, "slim", false, "Also strip tests, docs, stale bytecode and unneeded metadata from installed packages")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "With --slim, list what would be removed without deleting")
+	cmd.Flags().StringVar(&keep, "keep", "", "Comma-separated packages --slim must not touch")
+	return cmd
+}
+
+func dedupeCmd() *cobra.Command {
+	var dryRun, undo bool
+	cmd := &cobra.Command{
+		Use:   "dedupe [--undo [id]]",
+		Short: "Hardlink identical package files across venvs",
+		Long: `Hashes the files in the site-packages of every venv and replaces identical
+copies with hardlinks to one file. Content is compared again right before
+linking, and files on different filesystems are never linked.
+
+Each run writes an undo record; "dedupe --undo" gives every linked file its
+own copy again (the newest run unless an id is given).`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			var (
+				rep *manager.DedupeReport
+				err error
+			)
+			if undo {
+				id := ""
+				if len(args) > 0 {
+					id = args[0]
+				}
+				rep, err = mgr.UndoDedupe(id)
+			} else {
+				if len(args) > 0 {
+					die(fmt.Errorf("an id is only accepted with --undo"))
+				}
+				rep, err = mgr.Dedupe(manager.DedupeOptions{DryRun: dryRun})
+			}
+			if jsonFlag && rep != nil {
+				printJSON(rep)
+			}
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				return
+			}
+			if undo {
+				fmt.Printf("%s↩️  Restored %d file(s) from dedupe run %s%s\n", colorGreen, len(rep.Links), rep.ID, colorReset)
+				if len(rep.Skipped) > 0 {
+					fmt.Printf("%s%d file(s) changed since the run were left alone%s\n", colorYellow, len(rep.Skipped), colorReset)
+				}
+				return
+			}
+			if dryRun {
+				for _, l := range rep.Links {
+					fmt.Printf("  %s -> %s\n", l.Path, l.Target)
+				}
+			}
+			verb, saved := "Linked", "saved"
+			if dryRun {
+				verb, saved = "Would link", "would save"
+			}
+			fmt.Printf("%s🔗 %s %d file(s) in %d group(s), %s %s (%d files scanned)%s\n",
+				colorGreen, verb, len(rep.Links), rep.Groups, saved, utils.FormatSize(rep.SavedBytes), rep.FilesScanned, colorReset)
+			if len(rep.Skipped) > 0 {
+				fmt.Printf("%s⚠️  Skipped %d file(s) that changed or could not be linked%s\n", colorYellow, len(rep.Skipped), colorReset)
+			}
+			if rep.ID != "" {
+				fmt.Printf("Undo with: venv-manager dedupe --undo %s\n", rep.ID)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be linked without changing files")
+	cmd.Flags().BoolVar(&undo, "undo", false, "Undo a dedupe run (the newest unless an id is given)")
+	return cmd
+}
+
+func repairCmd() *cobra.Command {
+	var opts manager.RepairOptions
+	cmd := &cobra.Command{
+		Use:   "repair [name]",
+		Short: "Rebuild venvs whose base Python is gone",
+		Long: `Finds venvs that can no longer run: bin/python missing or dangling, or the
+pyvenv.cfg home removed (typically after Homebrew or apt upgraded the base
+Python). Each is rebuilt on an interpreter with the same major.minor version
+(or --python) and its packages are reinstalled from the site-packages
+metadata, or from the newest snapshot when that is unreadable. Snapshots and
+settings are kept. Healthy venvs are skipped unless --force is given.`,
+		Args: cobra.MaximumNArgs(1),
 		Run: func(_ *cobra.Command, args []string) {
 			mgr.SetGlobal(globalFlag)
 			name := ""
 			if len(args) > 0 && !globalFlag {
 				name = args[0]
 			}
-			if err := mgr.Clean(name); err != nil {
+			rep, err := mgr.Repair(name, opts)
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(rep)
+				if rep.Failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+			if len(rep.Results) > 1 {
+				printBulkReport(rep)
+			} else {
+				for _, res := range rep.Results {
+					if res.Status == manager.StatusSkipped {
+						fmt.Printf("%s✨ '%s' is healthy; nothing to repair (use --force to rebuild anyway)%s\n", colorGreen, res.Venv, colorReset)
+					} else if res.Error == "" {
+						fmt.Printf("%s🔧 Repaired '%s': %s%s\n", colorGreen, res.Venv, res.Note, colorReset)
+					}
+				}
+			}
+			if err := rep.Err(); err != nil {
 				die(err)
 			}
-			fmt.Printf("%s🧹 Environment cleaned successfully%s\n", colorGreen, colorReset)
 		},
 	}
+	cmd.Flags().StringVar(&opts.Python, "python", "", "Interpreter to rebuild on (default: same major.minor as before)")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Rebuild even if the venv looks healthy")
+	return cmd
+}
+
+func repythonCmd() *cobra.Command {
+	var (
+		verify          string
+		confirm, revert bool
+		yes             bool
+	)
+	cmd := &cobra.Command{
+		Use:   "repython <name> <python> | <name> --confirm | <name> --revert",
+		Short: "Rebuild a venv on another Python version",
+		Long: `Rebuilds a venv on another interpreter: the current freeze is installed into
+a new venv built in a staging directory, optionally verified with --verify
+(after pip check), and only then swapped in place of the old one. If anything
+fails the original venv is left untouched.
+
+The old venv is kept as a backup until "repython <name> --confirm" deletes it
+or "repython <name> --revert" swaps it back. <python> takes the same values
+as "create --python", e.g. 3.12 or ">=3.12".`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(_ *cobra.Command, args []string) {
+			name := args[0]
+			if confirm || revert {
+				if len(args) > 1 || confirm && revert {
+					die(fmt.Errorf("--confirm and --revert take only a venv name"))
+				}
+				if confirm {
+					if err := mgr.ConfirmRepython(name); err != nil {
+						die(err)
+					}
+					fmt.Printf("%s🗑️  Deleted the repython backup of '%s'%s\n", colorGreen, name, colorReset)
+					return
+				}
+				if err := mgr.RevertRepython(name); err != nil {
+					die(err)
+				}
+				fmt.Printf("%s↩️  Restored '%s' from its repython backup%s\n", colorGreen, name, colorReset)
+				return
+			}
+			if len(args) < 2 {
+				die(fmt.Errorf("a target python is required, e.g. 'venv-manager repython %s 3.12'", name))
+			}
+			rep, err := mgr.Repython(name, manager.RepythonOptions{Python: args[1], Verify: verify})
+			if err != nil {
+				die(err)
+			}
+			if yes {
+				if err := mgr.ConfirmRepython(name); err != nil {
+					die(err)
+				}
+				rep.Backup = ""
+			}
+			if jsonFlag {
+				printJSON(rep)
+				return
+			}
+			fmt.Printf("%s🐍 Rebuilt '%s' on Python %s (was %s), %d package(s) reinstalled%s\n", colorGreen, name, rep.To, rep.From, rep.Packages, colorReset)
+			if rep.Backup != "" {
+				fmt.Printf("Old venv kept at %s\n", rep.Backup)
+				fmt.Printf("Delete it with 'venv-manager repython %s --confirm' or restore it with 'venv-manager repython %s --revert'\n", name, name)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&verify, "verify", "", "Shell command that must succeed in the rebuilt venv before it is swapped in")
+	cmd.Flags().BoolVar(&confirm, "confirm", false, "Delete the backup kept by a previous repython")
+	cmd.Flags().BoolVar(&revert, "revert", false, "Swap the backup kept by a previous repython back in")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Delete the old venv right after a successful rebuild")
+	return cmd
+}
+
+func wheelhouseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wheelhouse",
+		Short: "Manage the local package directory used by --offline",
+		Long: `The wheelhouse is a local directory of wheels and sdists. With --offline
+(or config "offline": true), create, install, import, clone, exec and
+rollback install only from it (pip --no-index --find-links), never from PyPI.`,
+	}
+	var build bool
+	add := &cobra.Command{
+		Use:   "add <venv>",
+		Short: "Fetch every package pinned in a venv's freeze into the wheelhouse",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			rep, err := mgr.WheelhouseAdd(args[0], manager.WheelhouseAddOptions{Build: build})
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(rep)
+				return
+			}
+			for _, f := range rep.Added {
+				fmt.Printf("  + %s\n", f)
+			}
+			for _, s := range rep.Skipped {
+				fmt.Printf("%s  skipped (not on an index): %s%s\n", colorYellow, s, colorReset)
+			}
+			fmt.Printf("%s📦 %d requirement(s) from '%s' in %s (%d new file(s))%s\n",
+				colorGreen, rep.Requirements, rep.Venv, rep.Wheelhouse, len(rep.Added), colorReset)
+		},
+	}
+	add.Flags().BoolVar(&build, "build", false, "Use pip wheel, building wheels for sdist-only packages")
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List files in the wheelhouse",
+		Run: func(_ *cobra.Command, _ []string) {
+			files, err := mgr.WheelhouseList()
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				if files == nil {
+					files = []manager.WheelhouseFile{}
+				}
+				printJSON(files)
+				return
+			}
+			if len(files) == 0 {
+				fmt.Printf("Wheelhouse %s is empty\n", mgr.Wheelhouse())
+				return
+			}
+			for _, f := range files {
+				fmt.Printf("  %-60s %10s\n", f.Name, utils.FormatSize(f.Size))
+			}
+		},
+	}
+	path := &cobra.Command{
+		Use:   "path",
+		Short: "Print the wheelhouse directory",
+		Run:   func(_ *cobra.Command, _ []string) { fmt.Println(mgr.Wheelhouse()) },
+	}
+	cmd.AddCommand(add, list, path)
+	return cmd
+}
+
+func indexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index [name]",
+		Short: "Show package index settings (global, or effective for a venv)",
+		Long: `Index settings (index URL, extra index URLs, trusted hosts, CA bundle,
+client certificate, extra pip/uv environment) come from the config file and
+are passed to every pip and uv call. A venv can override them with
+"index set"; its override is stored in the venv's .venv-manager/index.json.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if len(args) == 0 {
+				printIndexConfig("global", mgr.IndexConfig())
+				return
+			}
+			override, effective, err := mgr.VenvIndexConfig(args[0])
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(map[string]manager.IndexConfig{"override": override.Redacted(), "effective": effective.Redacted()})
+				return
+			}
+			printIndexConfig("override", override)
+			printIndexConfig("effective", effective)
+		},
+	}
+	var (
+		override                 manager.IndexConfig
+		extra, host              string
+		clearExtra, clearTrusted bool
+		env                      []string
+	)
+	set := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Set a venv's index override (replaces any previous override)",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if clearExtra && extra != "" || clearTrusted && host != "" {
+				die(fmt.Errorf("--clear-extra-index-urls and --clear-trusted-hosts can't be combined with the lists they clear"))
+			}
+			override.ExtraIndexURLs = splitList(extra)
+			override.TrustedHosts = splitList(host)
+			if clearExtra {
+				override.ExtraIndexURLs = []string{}
+			}
+			if clearTrusted {
+				override.TrustedHosts = []string{}
+			}
+			for _, kv := range env {
+				k, v, ok := strings.Cut(kv, "=")
+				if !ok || k == "" {
+					die(fmt.Errorf("invalid --env %q: want KEY=VALUE", kv))
+				}
+				if override.Env == nil {
+					override.Env = map[string]string{}
+				}
+				override.Env[k] = v
+			}
+			if override.IsZero() {
+				die(fmt.Errorf("nothing to set; use 'index unset %s' to remove the override", args[0]))
+			}
+			if err := mgr.SetVenvIndexConfig(args[0], override); err != nil {
+				die(err)
+			}
+			fmt.Printf("%s✅ Index override saved for '%s'%s\n", colorGreen, args[0], colorReset)
+		},
+	}
+	set.Flags().StringVar(&override.IndexURL, "index-url", "", "Primary package index URL")
+	set.Flags().StringVar(&extra, "extra-index-url", "", "Comma-separated extra index URLs")
+	set.Flags().StringVar(&host, "trusted-host", "", "Comma-separated hosts to trust without valid HTTPS")
+	set.Flags().BoolVar(&clearExtra, "clear-extra-index-urls", false, "Use no extra index URLs, ignoring the global ones")
+	set.Flags().BoolVar(&clearTrusted, "clear-trusted-hosts", false, "Trust no hosts, ignoring the global ones")
+	set.Flags().StringVar(&override.Cert, "cert", "", "CA bundle file")
+	set.Flags().StringVar(&override.ClientCert, "client-cert", "", "Client certificate (PEM with key)")
+	set.Flags().StringArrayVar(&env, "env", nil, "Extra KEY=VALUE for pip/uv (repeatable)")
+	unset := &cobra.Command{
+		Use:   "unset <name>",
+		Short: "Remove a venv's index override",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := mgr.SetVenvIndexConfig(args[0], manager.IndexConfig{}); err != nil {
+				die(err)
+			}
+			fmt.Printf("%s✅ Index override removed for '%s'%s\n", colorGreen, args[0], colorReset)
+		},
+	}
+	cmd.AddCommand(set, unset)
+	return cmd
+}
+
+func printIndexConfig(title string, c manager.IndexConfig) {
+	c = c.Redacted()
+	if jsonFlag {
+		printJSON(c)
+		return
+	}
+	fmt.Printf("%s🌐 %s:%s\n", colorYellow, title, colorReset)
+	if c.IsZero() {
+		fmt.Println("  (pip defaults)")
+		return
+	}
+	row := func(k, v string) {
+		if v != "" {
+			fmt.Printf("  %-16s %s\n", k, v)
+		}
+	}
+	row("index_url", c.IndexURL)
+	list := func(k string, l []string) {
+		if l != nil && len(l) == 0 {
+			row(k, "(none)")
+			return
+		}
+		row(k, strings.Join(l, ", "))
+	}
+	list("extra_index_urls", c.ExtraIndexURLs)
+	list("trusted_hosts", c.TrustedHosts)
+	row("cert", c.Cert)
+	row("client_cert", c.ClientCert)
+	keys := make([]string, 0, len(c.Env))
+	for k := range c.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		row("env "+k, c.Env[k])
+	}
+}
+
+func printSlimResult(res manager.VenvResult, dryRun bool) {
+	if res.Error != "" {
+		return
+	}
+	verb := "Reclaimed"
+	if dryRun {
+		verb = "Reclaimable"
+	}
+	fmt.Printf("%s🪶 %s in '%s': %s%s\n", colorYellow, verb, res.Venv, utils.FormatSize(res.ReclaimedBytes), colorReset)
+	for _, p := range res.Reclaimed {
+		fmt.Printf("  %-30s %10s  %d paths\n", p.Name, utils.FormatSize(p.Bytes), len(p.Paths))
+		if dryRun {
+			for _, path := range p.Paths {
+				fmt.Printf("    - %s\n", path)
+			}
+		}
+	}
+}
+
+// printBulkReport prints one row per venv followed by a summary line.
+func printBulkReport(r *manager.BulkReport) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VENV\tSTATUS\tTIME\tPACKAGES\tDETAIL")
+	for _, res := range r.Results {
+		detail := res.Note
+		if res.Error != "" {
+			detail = strings.SplitN(res.Error, "\n", 2)[0]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", res.Venv, res.Status,
+			(time.Duration(res.DurationMS) * time.Millisecond).String(), len(res.Packages), detail)
+	}
+	tw.Flush()
+	color := colorGreen
+	if r.Failed > 0 {
+		color = colorRed
+	}
+	fmt.Printf("%s%s: %d ok, %d failed, %d skipped%s\n", color, r.Operation, r.OK, r.Failed, r.Skipped, colorReset)
 }
 
 func activateCmd() *cobra.Command {
@@ -267,12 +1084,15 @@ func sizeCmd() *cobra.Command {
 			if len(args) > 0 && !globalFlag {
 				name = args[0]
 			}
-			sizes, err := mgr.GetSize(name)
+			rep, err := mgr.GetSize(name)
 			if err != nil {
 				die(err)
 			}
 			if jsonFlag {
-				printJSON(sizes)
+				printJSON(rep)
+				if rep.Failed > 0 {
+					os.Exit(1)
+				}
 				return
 			}
 			if name != "" {
@@ -280,8 +1100,15 @@ func sizeCmd() *cobra.Command {
 			} else {
 				fmt.Printf("%s📊 Sizes of all virtual environments:%s\n", colorYellow, colorReset)
 			}
-			for venvName, size := range sizes {
-				fmt.Printf("- %s: %s\n", venvName, utils.FormatSize(size))
+			for _, res := range rep.Results {
+				if res.Error != "" {
+					fmt.Printf("- %s: %s%s%s\n", res.Venv, colorRed, res.Error, colorReset)
+					continue
+				}
+				fmt.Printf("- %s: %s\n", res.Venv, utils.FormatSize(res.SizeBytes))
+			}
+			if err := rep.Err(); err != nil {
+				die(err)
 			}
 		},
 	}
@@ -300,6 +1127,84 @@ func runCmd() *cobra.Command {
 	}
 }
 
+func pythonsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pythons",
+		Short: "List Python interpreters found on this machine",
+		Long: `Scans PATH, pyenv and asdf installs, uv-managed Pythons, /usr/bin,
+/usr/local/bin and the config's python_dirs. "create --python 3.12" picks the
+newest CPython 3.12 listed here that has the venv module.`,
+		Run: func(_ *cobra.Command, _ []string) {
+			pys := mgr.Pythons()
+			if jsonFlag {
+				if pys == nil {
+					pys = []manager.Interpreter{}
+				}
+				printJSON(pys)
+				return
+			}
+			if len(pys) == 0 {
+				fmt.Println("No Python interpreters found")
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tIMPL\tARCH\tVENV\tSOURCE\tPATH")
+			for _, p := range pys {
+				venv := "yes"
+				if !p.HasVenv {
+					venv = "no"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Version, p.Implementation, p.Arch, venv, p.Source, p.Path)
+			}
+			w.Flush()
+		},
+	}
+}
+
+func locksCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "locks",
+		Short: "Show which venvs are locked and by whom",
+		Long: `Mutating commands, watch and the MCP server lock the venv they change, and
+create, rename and remove also lock the base directory. Lists every lock
+with the pid and operation of its holder.`,
+		Run: func(_ *cobra.Command, _ []string) {
+			locks, err := mgr.Locks()
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				if locks == nil {
+					locks = []manager.LockInfo{}
+				}
+				printJSON(locks)
+				return
+			}
+			if len(locks) == 0 {
+				fmt.Println("No locks")
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "LOCK\tSTATE\tPID\tOPERATION\tSINCE")
+			for _, l := range locks {
+				name := l.Venv
+				if name == "" {
+					name = "(base dir)"
+				}
+				switch {
+				case !l.Held:
+					fmt.Fprintf(w, "%s\tfree\t-\t-\t-\n", name)
+				case l.Holder == nil:
+					fmt.Fprintf(w, "%s\theld\t?\t?\t?\n", name)
+				default:
+					fmt.Fprintf(w, "%s\theld\t%d\t%s\t%s\n", name, l.Holder.PID, l.Holder.Operation, l.Holder.Since.Format("2006-01-02 15:04:05"))
+				}
+			}
+			w.Flush()
+		},
+	}
+}
+
 func doctorCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "doctor",
@@ -318,6 +1223,12 @@ func doctorCmd() *cobra.Command {
 			fmt.Printf("  Venvs          : %d\n", r.VenvCount)
 			if len(r.Broken) > 0 {
 				fmt.Printf("  %sBroken venvs   : %v%s\n", colorRed, r.Broken, colorReset)
+				for _, v := range r.Broken {
+					for _, p := range r.Problems[v] {
+						fmt.Printf("    %s: %s\n", v, p)
+					}
+				}
+				fmt.Printf("  Fix with 'venv-manager repair <name>' or 'venv-manager repair --global'\n")
 			}
 		},
 	}
@@ -333,31 +1244,25 @@ func pruneCmd() *cobra.Command {
 			if days == 0 {
 				days = cfg.PruneAfterDays
 			}
-			stale, err := mgr.FindStale(days)
+			rep, err := mgr.PruneStale(days, dryRun)
 			if err != nil {
 				die(err)
 			}
 			if jsonFlag {
-				printJSON(stale)
+				printJSON(rep)
+				if rep.Failed > 0 {
+					os.Exit(1)
+				}
 				return
 			}
-			if len(stale) == 0 {
+			if len(rep.Results) == 0 {
 				fmt.Printf("%s✨ No stale venvs (older than %d days)%s\n", colorGreen, days, colorReset)
 				return
 			}
 			fmt.Printf("%s🗑️  Stale venvs (older than %d days):%s\n", colorYellow, days, colorReset)
-			for _, s := range stale {
-				fmt.Printf("- %s (last modified %s)\n", s.Name, s.ModTime.Format("2006-01-02"))
-			}
-			if dryRun {
-				return
-			}
-			for _, s := range stale {
-				if err := mgr.Remove(s.Name); err != nil {
-					fmt.Fprintf(os.Stderr, "%sfailed to remove %s: %v%s\n", colorRed, s.Name, err, colorReset)
-					continue
-				}
-				fmt.Printf("%s✅ Removed %s%s\n", colorGreen, s.Name, colorReset)
+			printBulkReport(rep)
+			if err := rep.Err(); err != nil {
+				die(err)
 			}
 		},
 	}
@@ -412,7 +1317,10 @@ func configCmd() *cobra.Command {
 		Use:   "show",
 		Short: "Print the current config",
 		Run: func(_ *cobra.Command, _ []string) {
-			printJSON(cfg)
+			shown := *cfg
+			index := mgr.IndexConfig().Redacted()
+			shown.IndexURL, shown.ExtraIndexURLs, shown.PipEnv = index.IndexURL, index.ExtraIndexURLs, index.Env
+			printJSON(shown)
 			fmt.Fprintf(os.Stderr, "config file: %s\n", config.Path())
 		},
 	})
@@ -530,13 +1438,19 @@ func tuiCmd() *cobra.Command {
 }
 
 func snapshotCmd() *cobra.Command {
-	var label string
+	var (
+		label string
+		full  bool
+	)
 	cmd := &cobra.Command{
 		Use:   "snapshot <name>",
 		Short: "Capture the current pip freeze state of a venv",
-		Args:  cobra.ExactArgs(1),
+		Long: `Captures the current pip freeze state of a venv. With --full, site-packages
+and the bin/ entry points are also archived, so 'rollback' can restore the
+venv without pip or network access.`,
+		Args: cobra.ExactArgs(1),
 		Run: func(_ *cobra.Command, args []string) {
-			s, err := mgr.CreateSnapshot(args[0], label)
+			s, err := mgr.CreateSnapshotWithOptions(args[0], manager.SnapshotOptions{Label: label, Full: full})
 			if err != nil {
 				die(err)
 			}
@@ -544,15 +1458,81 @@ func snapshotCmd() *cobra.Command {
 				printJSON(s)
 				return
 			}
+			if s.Full {
+				fmt.Printf("%s📸 Full snapshot %s created (%d packages, %s archive)%s\n", colorGreen, s.ID, s.PackageCount, utils.FormatSize(s.ArchiveBytes), colorReset)
+				return
+			}
 			fmt.Printf("%s📸 Snapshot %s created (%d packages)%s\n", colorGreen, s.ID, s.PackageCount, colorReset)
 		},
 	}
 	cmd.Flags().StringVarP(&label, "label", "l", "", "Optional label (e.g. 'pre-upgrade')")
+	cmd.Flags().BoolVar(&full, "full", false, "Also archive site-packages and entry points for offline rollback")
+	cmd.AddCommand(snapshotExportCmd(), snapshotImportCmd())
 	return cmd
 }
 
-func snapshotsCmd() *cobra.Command {
+func snapshotExportCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "export <name> <snapshot-id>",
+		Short: "Write a portable snapshot file for use on another machine",
+		Args:  cobra.ExactArgs(2),
+		Run: func(_ *cobra.Command, args []string) {
+			exp, err := mgr.ExportSnapshot(args[0], args[1])
+			if err != nil {
+				die(err)
+			}
+			if output == "" || output == "-" {
+				printJSON(exp)
+				return
+			}
+			data, err := json.MarshalIndent(exp, "", "  ")
+			if err != nil {
+				die(err)
+			}
+			if err := os.WriteFile(output, append(data, '\n'), 0o644); err != nil {
+				die(err)
+			}
+			fmt.Printf("%s📤 Exported snapshot %s of '%s' to %s%s\n", colorGreen, exp.ID, args[0], output, colorReset)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default stdout)")
+	return cmd
+}
+
+func snapshotImportCmd() *cobra.Command {
 	return &cobra.Command{
+		Use:   "import <name> <file>",
+		Short: "Add an exported snapshot to a venv, ready for rollback",
+		Args:  cobra.ExactArgs(2),
+		Run: func(_ *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[1])
+			if err != nil {
+				die(err)
+			}
+			var exp manager.SnapshotExport
+			if err := json.Unmarshal(data, &exp); err != nil {
+				die(fmt.Errorf("%s: not a snapshot export: %v", args[1], err))
+			}
+			s, warnings, err := mgr.ImportSnapshot(args[0], &exp)
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(map[string]any{"snapshot": s, "warnings": warnings})
+				return
+			}
+			for _, w := range warnings {
+				fmt.Fprintf(os.Stderr, "%s⚠️  %s%s\n", colorYellow, w, colorReset)
+			}
+			fmt.Printf("%s📥 Imported snapshot %s into '%s' (%d packages, from %s)%s\n", colorGreen, s.ID, args[0], s.PackageCount, s.ImportedFrom, colorReset)
+			fmt.Printf("   Roll back to it with: venv-manager rollback %s %s\n", args[0], s.ID)
+		},
+	}
+}
+
+func snapshotsCmd() *cobra.Command {
+	cmd := &cobra.Command{
 		Use:   "snapshots <name>",
 		Short: "List snapshots for a venv (newest first)",
 		Args:  cobra.ExactArgs(1),
@@ -571,22 +1551,111 @@ func snapshotsCmd() *cobra.Command {
 			}
 			fmt.Printf("%s📸 Snapshots of '%s':%s\n", colorYellow, args[0], colorReset)
 			for _, s := range snaps {
-				fmt.Printf("- %s  (%d pkgs)  %s\n", s.ID, s.PackageCount, s.CreatedAt.Format("2006-01-02 15:04:05"))
+				fmt.Printf("- %s  (%d pkgs)  %s", s.ID, s.PackageCount, s.CreatedAt.Format("2006-01-02 15:04:05"))
+				if s.Full {
+					fmt.Print("  [full]")
+				}
+				if s.Operation != "" && s.Operation != "manual" {
+					fmt.Printf("  [%s]", s.Operation)
+				}
+				fmt.Println()
 			}
 		},
 	}
+	cmd.AddCommand(snapshotsPruneCmd())
+	return cmd
+}
+
+func snapshotsPruneCmd() *cobra.Command {
+	var (
+		policy manager.RetentionPolicy
+		dryRun bool
+	)
+	cmd := &cobra.Command{
+		Use:   "prune <name>",
+		Short: "Delete snapshots outside the retention policy",
+		Long: `Deletes snapshots beyond the newest --keep or older than --days. Both default
+to the config's snapshot_keep_last / snapshot_max_age_days. The newest
+snapshot is always kept.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(c *cobra.Command, args []string) {
+			if !c.Flags().Changed("keep") {
+				policy.KeepLast = mgr.RetentionPolicy().KeepLast
+			}
+			if !c.Flags().Changed("days") {
+				policy.MaxAgeDays = mgr.RetentionPolicy().MaxAgeDays
+			}
+			if policy.IsZero() {
+				die(fmt.Errorf("no retention policy: pass --keep/--days or set snapshot_keep_last/snapshot_max_age_days in the config"))
+			}
+			pruned, err := mgr.PruneSnapshots(args[0], policy, dryRun)
+			if err != nil {
+				die(err)
+			}
+			if jsonFlag {
+				printJSON(pruned)
+				return
+			}
+			if len(pruned) == 0 {
+				fmt.Printf("%s✨ Nothing to prune for '%s'%s\n", colorGreen, args[0], colorReset)
+				return
+			}
+			verb := "Deleted"
+			if dryRun {
+				verb = "Would delete"
+			}
+			fmt.Printf("%s🗑️  %s %d snapshot(s) of '%s':%s\n", colorYellow, verb, len(pruned), args[0], colorReset)
+			for _, s := range pruned {
+				fmt.Printf("- %s  %s\n", s.ID, s.CreatedAt.Format("2006-01-02 15:04:05"))
+			}
+		},
+	}
+	cmd.Flags().IntVar(&policy.KeepLast, "keep", 0, "Keep at most this many snapshots")
+	cmd.Flags().IntVar(&policy.MaxAgeDays, "days", 0, "Delete snapshots older than this many days")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report; do not delete")
+	return cmd
 }
 
 func rollbackCmd() *cobra.Command {
-	return &cobra.Command{
+	var planOnly bool
+	cmd := &cobra.Command{
 		Use:   "rollback <name> [snapshot-id]",
 		Short: "Restore a venv from a snapshot (defaults to newest)",
-		Args:  cobra.RangeArgs(1, 2),
+		Long: `Restores a venv to a snapshot by uninstalling only the packages the snapshot
+lacks and reinstalling only those missing or at another version. If applying
+the delta fails, the venv is returned to its pre-rollback state.`,
+		Args: cobra.RangeArgs(1, 2),
 		Run: func(_ *cobra.Command, args []string) {
 			id := ""
 			if len(args) == 2 {
 				id = args[1]
 			}
+			if planOnly {
+				plan, err := mgr.PlanRollback(args[0], id)
+				if err != nil {
+					die(err)
+				}
+				if jsonFlag {
+					printJSON(plan)
+					return
+				}
+				if plan.Empty() {
+					fmt.Printf("%s✅ '%s' already matches snapshot %s%s\n", colorGreen, args[0], plan.Snapshot.ID, colorReset)
+					return
+				}
+				fmt.Printf("%s↩️  Rollback plan for '%s' → %s:%s\n", colorYellow, args[0], plan.Snapshot.ID, colorReset)
+				for _, c := range plan.Remove {
+					fmt.Printf("%s- uninstall %s==%s%s\n", colorRed, c.Name, c.From, colorReset)
+				}
+				for _, c := range plan.Install {
+					if c.From != "" {
+						fmt.Printf("~ reinstall %s (now %s)\n", c.To, c.From)
+					} else {
+						fmt.Printf("%s+ install   %s%s\n", colorGreen, c.To, colorReset)
+					}
+				}
+				return
+			}
 			s, err := mgr.Rollback(args[0], id)
 			if err != nil {
 				die(err)
@@ -594,6 +1663,8 @@ func rollbackCmd() *cobra.Command {
 			fmt.Printf("%s↩️  Restored '%s' from snapshot %s%s\n", colorGreen, args[0], s.ID, colorReset)
 		},
 	}
+	cmd.Flags().BoolVar(&planOnly, "plan", false, "Only print the packages that would be uninstalled and reinstalled")
+	return cmd
 }
 
 func scanCmd() *cobra.Command {
@@ -672,6 +1743,15 @@ func completionCmd() *cobra.Command {
 }
 
 func main() {
+	// An interrupted create, clone, import or repython must not leave its
+	// staging directory behind.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		mgr.CleanupStaging()
+		os.Exit(130)
+	}()
 	if err := rootCmd.Execute(); err != nil {
 		die(err)
 	}
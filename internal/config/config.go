@@ -16,6 +16,38 @@ type Config struct {
 	UseUv bool `json:"use_uv,omitempty"`
 	// PruneAfterDays: how many days of inactivity mark a venv as stale.
 	PruneAfterDays int `json:"prune_after_days,omitempty"`
+	// AutoSnapshot: snapshot a venv before install, upgrade, clone, rollback
+	// and watch syncs change it.
+	AutoSnapshot bool `json:"auto_snapshot,omitempty"`
+	// SnapshotKeepLast: keep at most this many snapshots per venv (0 = all).
+	SnapshotKeepLast int `json:"snapshot_keep_last,omitempty"`
+	// SnapshotMaxAgeDays: drop snapshots older than this (0 = never).
+	SnapshotMaxAgeDays int `json:"snapshot_max_age_days,omitempty"`
+	// Jobs: how many venvs --global operations process at once (0 = one
+	// per CPU).
+	Jobs int `json:"jobs,omitempty"`
+	// Wheelhouse: local package dir used by offline installs. Defaults to
+	// <base_dir>/.venv-manager/wheelhouse.
+	Wheelhouse string `json:"wheelhouse,omitempty"`
+	// Offline: always install from the wheelhouse, never from an index.
+	Offline bool `json:"offline,omitempty"`
+	// LockTimeoutSeconds: how long an operation waits for a venv another
+	// process is changing (0 = 30s, negative = fail at once).
+	LockTimeoutSeconds int `json:"lock_timeout_seconds,omitempty"`
+	// PythonDirs: extra directories searched for Python interpreters.
+	PythonDirs []string `json:"python_dirs,omitempty"`
+	// Installer: "pip" or "uv" for every package operation. Empty means uv
+	// when use_uv is set, else pip.
+	Installer string `json:"installer,omitempty"`
+	// IndexURL, ExtraIndexURLs, TrustedHosts, Cert and ClientCert are passed
+	// to every pip and uv call. Venvs can override them (see `index set`).
+	IndexURL       string   `json:"index_url,omitempty"`
+	ExtraIndexURLs []string `json:"extra_index_urls,omitempty"`
+	TrustedHosts   []string `json:"trusted_hosts,omitempty"`
+	Cert           string   `json:"cert,omitempty"`
+	ClientCert     string   `json:"client_cert,omitempty"`
+	// PipEnv: extra environment for pip and uv (e.g. PIP_TIMEOUT).
+	PipEnv map[string]string `json:"pip_env,omitempty"`
 }
 
 // Path returns the config file path (respects $XDG_CONFIG_HOME).
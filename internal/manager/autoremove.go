@@ -0,0 +1,204 @@
+package manager
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// seedPackages are the installer tooling `python -m venv` seeds a venv with.
+// Nothing depends on them, but removing them would break the venv.
+var seedPackages = map[string]bool{"pip": true, "setuptools": true, "wheel": true}
+
+// AutoremoveOptions configures orphan removal.
+type AutoremoveOptions struct {
+	// Requirements is a requirements file whose entries are the declared
+	// top-level packages. When empty, packages carrying pip's REQUESTED
+	// marker (i.e. installed by name, not as a dependency) are used.
+	Requirements string
+	// DryRun reports orphans without uninstalling or snapshotting.
+	DryRun bool
+}
+
+// AutoremoveReport lists what autoremove found (and removed).
+type AutoremoveReport struct {
+	Venv     string   `json:"venv"`
+	Declared []string `json:"declared"`
+	// Missing lists declared packages that are not installed. They keep
+	// nothing installed, so the caller should warn about them.
+	Missing  []string  `json:"missing,omitempty"`
+	Orphans  []string  `json:"orphans"`
+	DryRun   bool      `json:"dry_run"`
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+}
+
+// Autoremove uninstalls packages that no declared top-level requirement
+// reaches through the dependency graph. A snapshot labelled "autoremove" is
+// taken first so the removal can be rolled back.
+func (m *Manager) Autoremove(name string, opts AutoremoveOptions) (*AutoremoveReport, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := m.lock(name, "autoremove")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	pkgs, err := m.InstalledPackages(name)
+	if err != nil {
+		return nil, err
+	}
+	env := markerEnvFor(venvPath)
+	// Extras of the declared packages keep their extra-guarded dependencies:
+	// "uvicorn[standard]" keeps uvloop.
+	var declared []string
+	rootExtras := map[string][]string{}
+	if opts.Requirements != "" {
+		reqs, err := readDeclaredRequirements(opts.Requirements)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range reqs {
+			declared = append(declared, r.Name)
+			key := normalizePkgName(r.Name)
+			rootExtras[key] = append(rootExtras[key], r.Extras...)
+		}
+	} else {
+		installed := map[string]bool{}
+		for _, p := range pkgs {
+			installed[normalizePkgName(p.Name)] = true
+		}
+		for _, p := range pkgs {
+			key := normalizePkgName(p.Name)
+			if p.Requested && !seedPackages[key] {
+				declared = append(declared, p.Name)
+				rootExtras[key] = installedExtras(p, installed, env)
+			}
+		}
+	}
+	g := buildDepGraph(name, pkgs, env, rootExtras)
+	if len(declared) == 0 {
+		return nil, fmt.Errorf("no declared top-level packages found in %q; pass a requirements file to say what to keep", name)
+	}
+
+	rep := &AutoremoveReport{Venv: name, Declared: declared, Orphans: []string{}, DryRun: opts.DryRun}
+	for _, d := range declared {
+		if g.Node(d) == nil {
+			rep.Missing = append(rep.Missing, d)
+		}
+	}
+	if len(rep.Missing) == len(declared) {
+		return nil, fmt.Errorf("none of the packages declared in %s is installed in '%s' (%s); refusing to remove everything", opts.Requirements, name, strings.Join(rep.Missing, ", "))
+	}
+	for _, n := range findOrphans(g, declared) {
+		rep.Orphans = append(rep.Orphans, n.Name+"=="+n.Version)
+	}
+	if opts.DryRun || len(rep.Orphans) == 0 {
+		return rep, nil
+	}
+
+	snap, err := m.snapshotAt(venvPath, name, SnapshotOptions{Label: "autoremove", Operation: "autoremove"})
+	if err != nil {
+		return nil, fmt.Errorf("pre-autoremove snapshot failed: %v", err)
+	}
+	rep.Snapshot = snap
+	var names []string
+	for _, o := range rep.Orphans {
+		names = append(names, strings.SplitN(o, "==", 2)[0])
+	}
+	if out, err := m.pkg(venvPath).uninstall(names...).CombinedOutput(); err != nil {
+		return rep, fmt.Errorf("uninstall failed (rollback with snapshot %s): %v\n%s", snap.ID, err, out)
+	}
+	return rep, nil
+}
+
+// findOrphans returns installed packages unreachable from declared, leaving
+// the seed packages alone.
+func findOrphans(g *DepGraph, declared []string) []*DepNode {
+	reached := map[string]bool{}
+	for _, d := range declared {
+		if g.Node(d) != nil {
+			g.walk(d, reached)
+		}
+	}
+	var orphans []*DepNode
+	for _, n := range g.Packages {
+		key := normalizePkgName(n.Name)
+		if !reached[key] && !seedPackages[key] {
+			orphans = append(orphans, n)
+		}
+	}
+	return orphans
+}
+
+// installedExtras returns the extras of p that have an installed guarded
+// dependency. pip does not record which extras a package was requested
+// with, so an extra whose dependencies are present is taken to be in use.
+func installedExtras(p InstalledPackage, installed map[string]bool, env markerEnv) []string {
+	on := map[string]bool{}
+	for _, raw := range p.RequiresDist {
+		r, err := parseRequirement(raw)
+		if err != nil || !installed[normalizePkgName(r.Name)] {
+			continue
+		}
+		for _, x := range markerExtras(r.Marker) {
+			if ok, _ := evalMarker(r.Marker, env, x); ok {
+				on[x] = true
+			}
+		}
+	}
+	return sortedKeys(on)
+}
+
+// markerExtras returns the extras a marker compares against, e.g. "socks"
+// for `extra == "socks"`.
+func markerExtras(marker string) []string {
+	toks, err := tokenizeMarker(marker)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for i := 0; i+2 < len(toks); i++ {
+		a, op, b := toks[i], toks[i+1], toks[i+2]
+		if op.kind != "op" || op.val != "==" {
+			continue
+		}
+		switch {
+		case a.kind == "ident" && a.val == "extra" && b.kind == "str":
+			out = append(out, normalizePkgName(b.val))
+		case b.kind == "ident" && b.val == "extra" && a.kind == "str":
+			out = append(out, normalizePkgName(a.val))
+		}
+	}
+	return out
+}
+
+// readDeclaredRequirements returns the requirements listed in a requirements
+// file. Options (-r, -e, --index-url, ...) and comments are skipped.
+func readDeclaredRequirements(path string) ([]requirement, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var reqs []requirement
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		ln := sc.Text()
+		if i := strings.Index(ln, " #"); i >= 0 {
+			ln = ln[:i]
+		}
+		ln = strings.TrimSpace(ln)
+		if ln == "" || strings.HasPrefix(ln, "#") || strings.HasPrefix(ln, "-") {
+			continue
+		}
+		r, err := parseRequirement(ln)
+		if err != nil {
+			continue
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs, sc.Err()
+}
@@ -0,0 +1,127 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindOrphans(t *testing.T) {
+	g := testGraph()
+	var got []string
+	for _, n := range findOrphans(g, []string{"boto3"}) {
+		got = append(got, n.Name)
+	}
+	if strings.Join(got, ",") != "leftover" {
+		t.Fatalf("orphans=%v", got)
+	}
+	got = nil
+	for _, n := range findOrphans(g, []string{"requests"}) {
+		got = append(got, n.Name)
+	}
+	if strings.Join(got, ",") != "botocore,boto3,leftover" {
+		t.Fatalf("orphans=%v", got)
+	}
+}
+
+func TestReadDeclaredRequirements(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "requirements.txt")
+	os.WriteFile(p, []byte("# deps\n-r base.txt\n--index-url https://x\nrequests>=2  # http\nnumpy[extra]==1.26; python_version>'3.8'\n\n"), 0o644)
+	reqs, err := readDeclaredRequirements(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 2 || reqs[0].Name != "requests" || reqs[1].Name != "numpy" || strings.Join(reqs[1].Extras, ",") != "extra" {
+		t.Fatalf("reqs=%+v", reqs)
+	}
+}
+
+func TestAutoremoveDryRun(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	writeDistInfo(t, sp, "pip", "24.0", nil, true)
+	writeDistInfo(t, sp, "app", "1.0", []string{"dep"}, true)
+	writeDistInfo(t, sp, "dep", "1.0", nil, false)
+	writeDistInfo(t, sp, "stray", "0.1", nil, false)
+
+	rep, err := m.Autoremove("v", AutoremoveOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rep.Declared, ",") != "app" || strings.Join(rep.Orphans, ",") != "stray==0.1" {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	if rep.Snapshot != nil {
+		t.Fatal("dry run must not snapshot")
+	}
+}
+
+func TestAutoremoveRequiresDeclaredPackages(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	writeDistInfo(t, sp, "stray", "0.1", nil, false)
+	if _, err := m.Autoremove("v", AutoremoveOptions{DryRun: true}); err == nil {
+		t.Fatal("expected error when nothing is declared")
+	}
+}
+
+func TestAutoremoveReportsMissingDeclared(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	writeDistInfo(t, sp, "app", "1.0", []string{"dep"}, true)
+	writeDistInfo(t, sp, "dep", "1.0", nil, false)
+	writeDistInfo(t, sp, "stray", "0.1", nil, false)
+	req := filepath.Join(t.TempDir(), "requirements.txt")
+
+	os.WriteFile(req, []byte("app\nreqeusts\n"), 0o644)
+	rep, err := m.Autoremove("v", AutoremoveOptions{Requirements: req, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rep.Missing, ",") != "reqeusts" || strings.Join(rep.Orphans, ",") != "stray==0.1" {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+
+	// Nothing declared is installed: every package would be an orphan.
+	os.WriteFile(req, []byte("reqeusts\n"), 0o644)
+	if _, err := m.Autoremove("v", AutoremoveOptions{Requirements: req, DryRun: true}); err == nil || !strings.Contains(err.Error(), "reqeusts") {
+		t.Fatalf("expected error naming the missing package, got %v", err)
+	}
+}
+
+func TestAutoremoveKeepsExtraDependencies(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	writeDistInfo(t, sp, "uvicorn", "0.30.0", []string{"click>=7", `uvloop>=0.14; extra == "standard"`, `watchfiles; extra == "standard"`}, true)
+	writeDistInfo(t, sp, "click", "8.1.7", nil, false)
+	writeDistInfo(t, sp, "uvloop", "0.19.0", nil, false)
+	writeDistInfo(t, sp, "stray", "0.1", nil, false)
+
+	// REQUESTED mode: the installed extra dependency is kept even though
+	// the extra's other dependency is not installed.
+	rep, err := m.Autoremove("v", AutoremoveOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rep.Orphans, ",") != "stray==0.1" {
+		t.Fatalf("REQUESTED mode orphans=%v", rep.Orphans)
+	}
+
+	req := filepath.Join(t.TempDir(), "requirements.txt")
+	os.WriteFile(req, []byte("uvicorn[standard]\n"), 0o644)
+	if rep, err = m.Autoremove("v", AutoremoveOptions{Requirements: req, DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rep.Orphans, ",") != "stray==0.1" {
+		t.Fatalf("-r uvicorn[standard] orphans=%v", rep.Orphans)
+	}
+
+	os.WriteFile(req, []byte("uvicorn\n"), 0o644)
+	if rep, err = m.Autoremove("v", AutoremoveOptions{Requirements: req, DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rep.Orphans, ",") != "stray==0.1,uvloop==0.19.0" {
+		t.Fatalf("-r uvicorn orphans=%v", rep.Orphans)
+	}
+}
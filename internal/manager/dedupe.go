@@ -0,0 +1,427 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// dedupeMinSize skips files too small for a link to save a filesystem block.
+const dedupeMinSize = 4096
+
+// DedupeOptions controls Dedupe.
+type DedupeOptions struct {
+	// DryRun reports what would be linked without touching any file.
+	DryRun bool
+}
+
+// DedupeLink is one file replaced by a hardlink to an identical file.
+type DedupeLink struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Mode and ModTime are the original file's, restored by UndoDedupe.
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+}
+
+// DedupeReport is the outcome of Dedupe. In dry-run mode Links lists the
+// planned links and nothing is recorded.
+type DedupeReport struct {
+	ID           string       `json:"id,omitempty"`
+	DryRun       bool         `json:"dry_run"`
+	FilesScanned int          `json:"files_scanned"`
+	Groups       int          `json:"groups"`
+	SavedBytes   int64        `json:"saved_bytes"`
+	Links        []DedupeLink `json:"links"`
+	// Skipped lists files left alone, e.g. content changed since hashing or
+	// a link across filesystems was refused.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// dedupeFile is a scanned inode and every scanned path pointing at it.
+type dedupeFile struct {
+	id      utils.FileID
+	nlink   uint64
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	paths   []string
+}
+
+func dedupeDir(baseDir string) string {
+	return filepath.Join(baseDir, ".venv-manager", "dedupe")
+}
+
+// Dedupe replaces identical regular files in the site-packages of every venv
+// with hardlinks to one copy. Files are grouped by size, mode, content hash
+// and device; content is compared byte for byte again right before linking.
+// Each run writes an undo record under baseDir, see UndoDedupe.
+func (m *Manager) Dedupe(opts DedupeOptions) (*DedupeReport, error) {
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("dedupe is not supported on windows")
+	}
+	venvs, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		unlock, err := m.lockVenvs(venvs, "dedupe")
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	files, err := scanDedupeFiles(m.baseDir, venvs)
+	if err != nil {
+		return nil, err
+	}
+	rep := &DedupeReport{DryRun: opts.DryRun, Links: []DedupeLink{}}
+	for _, f := range files {
+		rep.FilesScanned += len(f.paths)
+	}
+	groups, err := dedupeGroups(files)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		rep.Groups++
+		keep := g.files[0]
+		for _, f := range g.files[1:] {
+			if !opts.DryRun {
+				same, err := sameContent(keep.paths[0], f.paths[0])
+				if err != nil || !same {
+					rep.Skipped = append(rep.Skipped, f.paths...)
+					continue
+				}
+			}
+			linked := 0
+			for _, p := range f.paths {
+				if !opts.DryRun {
+					if err := replaceWithLink(keep.paths[0], p); err != nil {
+						rep.Skipped = append(rep.Skipped, p)
+						continue
+					}
+				}
+				linked++
+				rep.Links = append(rep.Links, DedupeLink{
+					Path: p, Target: keep.paths[0], Size: f.size, SHA256: g.hash,
+					Mode: f.mode, ModTime: f.modTime,
+				})
+			}
+			// The data is only freed once no link outside the scan remains.
+			if linked == len(f.paths) && f.nlink == uint64(len(f.paths)) {
+				rep.SavedBytes += f.size
+			}
+		}
+	}
+	if opts.DryRun || len(rep.Links) == 0 {
+		return rep, nil
+	}
+	if err := writeDedupeRecord(m.baseDir, rep); err != nil {
+		return rep, fmt.Errorf("files were linked but the undo record could not be written: %v", err)
+	}
+	return rep, nil
+}
+
+// scanDedupeFiles collects regular files of at least dedupeMinSize from the
+// site-packages of each venv, one entry per inode. .dist-info/.pth files are
+// left out: pip rewrites them per venv.
+func scanDedupeFiles(baseDir string, venvs []string) ([]*dedupeFile, error) {
+	byID := map[utils.FileID]*dedupeFile{}
+	var order []*dedupeFile
+	for _, name := range venvs {
+		for _, sp := range sitePackagesDirs(filepath.Join(baseDir, name)) {
+			err := filepath.Walk(sp, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() {
+					if strings.HasSuffix(info.Name(), ".dist-info") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !info.Mode().IsRegular() || info.Size() < dedupeMinSize || strings.HasSuffix(path, ".pth") {
+					return nil
+				}
+				id, nlink, ok := utils.StatFileID(info)
+				if !ok {
+					return nil
+				}
+				f := byID[id]
+				if f == nil {
+					f = &dedupeFile{id: id, nlink: nlink, size: info.Size(), mode: info.Mode(), modTime: info.ModTime()}
+					byID[id] = f
+					order = append(order, f)
+				}
+				f.paths = append(f.paths, path)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan %s: %v", sp, err)
+			}
+		}
+	}
+	return order, nil
+}
+
+// dedupeGroup is a set of distinct inodes with identical content on one
+// device. files[0] is the copy the others are linked to.
+type dedupeGroup struct {
+	hash  string
+	files []*dedupeFile
+}
+
+func dedupeGroups(files []*dedupeFile) ([]dedupeGroup, error) {
+	type sizeKey struct {
+		size int64
+		mode os.FileMode
+	}
+	bySize := map[sizeKey][]*dedupeFile{}
+	for _, f := range files {
+		k := sizeKey{f.size, f.mode.Perm()}
+		bySize[k] = append(bySize[k], f)
+	}
+	type hashKey struct {
+		dev  uint64
+		hash string
+	}
+	byHash := map[hashKey][]*dedupeFile{}
+	for _, cands := range bySize {
+		if len(cands) < 2 {
+			continue
+		}
+		for _, f := range cands {
+			h, err := hashFileSHA256(f.paths[0])
+			if err != nil {
+				return nil, err
+			}
+			k := hashKey{f.id.Dev, h}
+			byHash[k] = append(byHash[k], f)
+		}
+	}
+	var groups []dedupeGroup
+	for k, fs := range byHash {
+		if len(fs) < 2 {
+			continue
+		}
+		for _, f := range fs {
+			sort.Strings(f.paths)
+		}
+		// Keep the inode that already has the most links, so fewer files move.
+		sort.Slice(fs, func(i, j int) bool {
+			if len(fs[i].paths) != len(fs[j].paths) {
+				return len(fs[i].paths) > len(fs[j].paths)
+			}
+			return fs[i].paths[0] < fs[j].paths[0]
+		})
+		groups = append(groups, dedupeGroup{hash: k.hash, files: fs})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].files[0].paths[0] < groups[j].files[0].paths[0] })
+	return groups, nil
+}
+
+func hashFileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sameContent compares two files byte for byte.
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+	bufA, bufB := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// replaceWithLink atomically swaps path for a hardlink to target. The link is
+// made next to path first, so a failure (e.g. EXDEV) leaves path untouched.
+func replaceWithLink(target, path string) error {
+	tmp := path + ".vm-dedupe"
+	os.Remove(tmp)
+	if err := os.Link(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// replaceWithCopy atomically swaps path for a private copy of its content.
+func replaceWithCopy(path string, mode os.FileMode, modTime time.Time) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := path + ".vm-dedupe"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, mode.Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, modTime, modTime)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func writeDedupeRecord(baseDir string, rep *DedupeReport) error {
+	dir := dedupeDir(baseDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	base := time.Now().Format(snapshotIDLayout)
+	id := base
+	for i := 2; fileExists(filepath.Join(dir, id+".json")); i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	rep.ID = id
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, id+".json"), b, 0o644)
+}
+
+// DedupeRecords returns the IDs of undo records, newest first.
+func (m *Manager) DedupeRecords() ([]string, error) {
+	entries, err := os.ReadDir(dedupeDir(m.baseDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".json") {
+			ids = append(ids, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// UndoDedupe gives every file linked by the run id (the newest run if id is
+// empty) its own copy again. Files replaced since the run are left alone.
+// The record is removed once every link is undone.
+func (m *Manager) UndoDedupe(id string) (*DedupeReport, error) {
+	if id == "" {
+		ids, err := m.DedupeRecords()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("no dedupe runs to undo")
+		}
+		id = ids[0]
+	}
+	if strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid dedupe id %q", id)
+	}
+	recPath := filepath.Join(dedupeDir(m.baseDir), id+".json")
+	b, err := os.ReadFile(recPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("dedupe run %q not found", id)
+		}
+		return nil, err
+	}
+	var rec DedupeReport
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("dedupe record %s is corrupt: %v", id, err)
+	}
+	venvs, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := m.lockVenvs(venvs, "dedupe undo")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	rep := &DedupeReport{ID: id, Links: []DedupeLink{}}
+	var failed []DedupeLink
+	for _, l := range rec.Links {
+		pi, err1 := os.Stat(l.Path)
+		ti, err2 := os.Stat(l.Target)
+		if err1 != nil || err2 != nil || !os.SameFile(pi, ti) {
+			// Reinstalled or removed since; nothing to restore.
+			rep.Skipped = append(rep.Skipped, l.Path)
+			continue
+		}
+		if err := replaceWithCopy(l.Path, l.Mode, l.ModTime); err != nil {
+			failed = append(failed, l)
+			rep.Skipped = append(rep.Skipped, l.Path)
+			continue
+		}
+		rep.Links = append(rep.Links, l)
+	}
+	if len(failed) > 0 {
+		rec.Links = failed
+		if b, err := json.MarshalIndent(&rec, "", "  "); err == nil {
+			os.WriteFile(recPath, b, 0o644)
+		}
+		return rep, fmt.Errorf("%d file(s) could not be restored; run dedupe --undo %s again", len(failed), id)
+	}
+	return rep, os.Remove(recPath)
+}
@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeSitePackage writes rel under the site-packages of venv name.
+func writeSitePackage(t *testing.T, dir, name, rel string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(dir, name, "lib", "python3.12", "site-packages", rel)
+	os.MkdirAll(filepath.Dir(p), 0o755)
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDedupeLinksAndUndo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("dedupe needs inode info")
+	}
+	m, dir := newTestMgr(t)
+	m.SetGlobal(true)
+	shared := bytes.Repeat([]byte("x"), 8192)
+	a := writeSitePackage(t, dir, "a", "pkg/core.py", shared)
+	b := writeSitePackage(t, dir, "b", "pkg/core.py", shared)
+	other := writeSitePackage(t, dir, "b", "pkg/other.py", bytes.Repeat([]byte("y"), 8192))
+	small := writeSitePackage(t, dir, "a", "pkg/tiny.py", []byte("x"))
+	writeSitePackage(t, dir, "b", "pkg/tiny.py", []byte("x"))
+	writeSitePackage(t, dir, "a", "pkg-1.0.dist-info/RECORD", shared)
+
+	before := sizesOf(t, m, "")
+	dry, err := m.Dedupe(DedupeOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dry.ID != "" || len(dry.Links) != 1 || dry.SavedBytes != 8192 {
+		t.Fatalf("dry run: %+v", dry)
+	}
+	if fa, fb := mustStat(t, a), mustStat(t, b); os.SameFile(fa, fb) {
+		t.Fatal("dry run linked files")
+	}
+
+	rep, err := m.Dedupe(DedupeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.ID == "" || len(rep.Links) != 1 || rep.SavedBytes != 8192 {
+		t.Fatalf("report: %+v", rep)
+	}
+	if !os.SameFile(mustStat(t, a), mustStat(t, b)) {
+		t.Fatal("identical files not linked")
+	}
+	if os.SameFile(mustStat(t, other), mustStat(t, a)) || os.SameFile(mustStat(t, small), mustStat(t, b)) {
+		t.Fatal("linked a file that differs or is below the size threshold")
+	}
+	after := sizesOf(t, m, "")
+	if after["a"]+after["b"] != before["a"]+before["b"]-8192 {
+		t.Fatalf("shared inode counted twice: before=%v after=%v", before, after)
+	}
+	if size := sizesOf(t, m, "b"); size["b"] != before["b"] {
+		t.Fatalf("single venv size changed: %d != %d", size["b"], before["b"])
+	}
+	if venvs, _ := m.List(); len(venvs) != 2 {
+		t.Fatalf("undo records listed as venvs: %v", venvs)
+	}
+
+	again, err := m.Dedupe(DedupeOptions{})
+	if err != nil || len(again.Links) != 0 {
+		t.Fatalf("second run should be a no-op: %+v %v", again, err)
+	}
+
+	if _, err := m.UndoDedupe(""); err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(mustStat(t, a), mustStat(t, b)) {
+		t.Fatal("undo left files linked")
+	}
+	if got, _ := os.ReadFile(b); !bytes.Equal(got, shared) {
+		t.Fatal("undo changed content")
+	}
+	if ids, _ := m.DedupeRecords(); len(ids) != 0 {
+		t.Fatalf("undo record not removed: %v", ids)
+	}
+}
+
+func mustStat(t *testing.T, p string) os.FileInfo {
+	t.Helper()
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi
+}
+
+func TestSameContent(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	data := bytes.Repeat([]byte("z"), 200000)
+	os.WriteFile(a, data, 0o644)
+	os.WriteFile(b, data, 0o644)
+	os.WriteFile(c, append(data[:len(data)-1:len(data)-1], 'q'), 0o644)
+	if same, err := sameContent(a, b); err != nil || !same {
+		t.Fatalf("same=%v err=%v", same, err)
+	}
+	if same, _ := sameContent(a, c); same {
+		t.Fatal("differing files reported equal")
+	}
+}
@@ -0,0 +1,370 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Dependency is one Requires-Dist edge that applies to the venv after
+// evaluating environment markers.
+type Dependency struct {
+	Name      string   `json:"name"`
+	Specifier string   `json:"specifier,omitempty"`
+	Extras    []string `json:"extras,omitempty"`
+	// Installed is the installed version of the dependency; empty when missing.
+	Installed string `json:"installed_version,omitempty"`
+	Missing   bool   `json:"missing,omitempty"`
+	// Conflict is set when the installed version falls outside Specifier.
+	Conflict bool `json:"conflict,omitempty"`
+}
+
+// DepNode is an installed package and its resolved edges.
+type DepNode struct {
+	Name       string       `json:"name"`
+	Version    string       `json:"version"`
+	Requested  bool         `json:"requested,omitempty"`
+	Requires   []Dependency `json:"requires"`
+	RequiredBy []string     `json:"required_by,omitempty"`
+}
+
+// DepGraph is the installed dependency graph of a venv. Roots are the
+// top-level packages: nothing installed depends on them.
+type DepGraph struct {
+	Venv     string     `json:"venv"`
+	Packages []*DepNode `json:"packages"`
+	Roots    []string   `json:"roots"`
+	index    map[string]*DepNode
+}
+
+// Node looks a package up by any spelling of its name.
+func (g *DepGraph) Node(name string) *DepNode {
+	return g.index[normalizePkgName(name)]
+}
+
+// DependencyGraph builds the dependency graph of a venv from dist-info
+// Requires-Dist metadata, with environment markers and extras evaluated.
+func (m *Manager) DependencyGraph(name string) (*DepGraph, error) {
+	pkgs, err := m.InstalledPackages(name)
+	if err != nil {
+		return nil, err
+	}
+	return buildDepGraph(name, pkgs, markerEnvFor(m.VenvPath(name)), nil), nil
+}
+
+// buildDepGraph resolves the edges of pkgs. rootExtras switches on extras of
+// packages that were asked for from outside the venv, e.g. "uvicorn[standard]"
+// in a requirements file, keyed by normalized name.
+func buildDepGraph(venv string, pkgs []InstalledPackage, env markerEnv, rootExtras map[string][]string) *DepGraph {
+	g := &DepGraph{Venv: venv, index: map[string]*DepNode{}}
+	byName := map[string]InstalledPackage{}
+	for _, p := range pkgs {
+		key := normalizePkgName(p.Name)
+		byName[key] = p
+		n := &DepNode{Name: p.Name, Version: p.Version, Requested: p.Requested, Requires: []Dependency{}}
+		g.Packages = append(g.Packages, n)
+		g.index[key] = n
+	}
+
+	// Extras requested by one package (e.g. "requests[socks]") switch on
+	// extra-guarded requirements of another, which can in turn request more
+	// extras: iterate until the set stops growing.
+	extras := map[string]map[string]bool{}
+	for key, xs := range rootExtras {
+		extras[key] = map[string]bool{}
+		for _, x := range xs {
+			extras[key][normalizePkgName(x)] = true
+		}
+	}
+	active := func(pkgKey string) []requirement {
+		var out []requirement
+		seen := map[string]bool{}
+		for _, raw := range byName[pkgKey].RequiresDist {
+			r, err := parseRequirement(raw)
+			if err != nil {
+				continue
+			}
+			on := false
+			for _, x := range append([]string{""}, sortedKeys(extras[pkgKey])...) {
+				// An unparseable marker keeps the edge: over-reporting a
+				// dependency is safer than hiding one.
+				if ok, err := evalMarker(r.Marker, env, x); ok || err != nil {
+					on = true
+					break
+				}
+			}
+			if on && !seen[raw] {
+				seen[raw] = true
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+	for changed := true; changed; {
+		changed = false
+		for key := range byName {
+			for _, r := range active(key) {
+				dep := normalizePkgName(r.Name)
+				for _, x := range r.Extras {
+					x = normalizePkgName(x)
+					if extras[dep] == nil {
+						extras[dep] = map[string]bool{}
+					}
+					if !extras[dep][x] {
+						extras[dep][x] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, n := range g.Packages {
+		key := normalizePkgName(n.Name)
+		edges := map[string]*Dependency{}
+		var order []string
+		for _, r := range active(key) {
+			dk := normalizePkgName(r.Name)
+			if dk == key {
+				continue // self-references via extras, e.g. "pkg[all]"
+			}
+			d, ok := edges[dk]
+			if !ok {
+				d = &Dependency{Name: r.Name}
+				edges[dk] = d
+				order = append(order, dk)
+			}
+			if r.Specifier != "" {
+				if d.Specifier != "" {
+					d.Specifier += ","
+				}
+				d.Specifier += r.Specifier
+			}
+			d.Extras = append(d.Extras, r.Extras...)
+		}
+		sort.Strings(order)
+		for _, dk := range order {
+			d := edges[dk]
+			if target, ok := g.index[dk]; ok {
+				d.Name = target.Name
+				d.Installed = target.Version
+				target.RequiredBy = append(target.RequiredBy, n.Name)
+				if set, err := parseSpecifierSet(d.Specifier); err == nil {
+					if v, err := parseVersion(target.Version); err == nil && !set.Contains(v) {
+						d.Conflict = true
+					}
+				}
+			} else {
+				d.Missing = true
+			}
+			n.Requires = append(n.Requires, *d)
+		}
+	}
+	for _, n := range g.Packages {
+		sort.Strings(n.RequiredBy)
+	}
+	g.Roots = g.findRoots()
+	return g
+}
+
+// findRoots returns packages nothing depends on, plus one representative
+// of every dependency cycle that is otherwise unreachable from a root.
+func (g *DepGraph) findRoots() []string {
+	roots := []string{}
+	reached := map[string]bool{}
+	for _, n := range g.Packages {
+		if len(n.RequiredBy) == 0 {
+			roots = append(roots, n.Name)
+			g.walk(n.Name, reached)
+		}
+	}
+	for _, n := range g.Packages {
+		if !reached[normalizePkgName(n.Name)] {
+			roots = append(roots, n.Name)
+			g.walk(n.Name, reached)
+		}
+	}
+	return roots
+}
+
+// walk marks every installed package reachable from name.
+func (g *DepGraph) walk(name string, seen map[string]bool) {
+	key := normalizePkgName(name)
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+	n := g.index[key]
+	if n == nil {
+		return
+	}
+	for _, d := range n.Requires {
+		if !d.Missing {
+			g.walk(d.Name, seen)
+		}
+	}
+}
+
+// WriteTree renders the graph as an indented tree rooted at the top-level
+// packages. Packages already expanded elsewhere are marked with "(*)".
+func (g *DepGraph) WriteTree(w io.Writer) {
+	expanded := map[string]bool{}
+	for _, r := range g.Roots {
+		n := g.Node(r)
+		fmt.Fprintf(w, "%s==%s\n", n.Name, n.Version)
+		expanded[normalizePkgName(n.Name)] = true
+		g.writeChildren(w, n, "", expanded, map[string]bool{normalizePkgName(n.Name): true})
+	}
+}
+
+func (g *DepGraph) writeChildren(w io.Writer, n *DepNode, prefix string, expanded, path map[string]bool) {
+	for i, d := range n.Requires {
+		branch, next := "├── ", "│   "
+		if i == len(n.Requires)-1 {
+			branch, next = "└── ", "    "
+		}
+		spec := d.Specifier
+		if spec == "" {
+			spec = "any"
+		}
+		key := normalizePkgName(d.Name)
+		switch {
+		case d.Missing:
+			fmt.Fprintf(w, "%s%s%s [required: %s, MISSING]\n", prefix, branch, d.Name, spec)
+			continue
+		case d.Conflict:
+			fmt.Fprintf(w, "%s%s%s==%s [required: %s, CONFLICT]\n", prefix, branch, d.Name, d.Installed, spec)
+		default:
+			fmt.Fprintf(w, "%s%s%s==%s [required: %s]", prefix, branch, d.Name, d.Installed, spec)
+			if expanded[key] && len(g.index[key].Requires) > 0 {
+				fmt.Fprint(w, " (*)\n")
+				continue
+			}
+			fmt.Fprintln(w)
+		}
+		if path[key] || expanded[key] {
+			continue
+		}
+		expanded[key] = true
+		path[key] = true
+		g.writeChildren(w, g.index[key], prefix+next, expanded, path)
+		delete(path, key)
+	}
+}
+
+// WhyEdge is a direct dependent of the queried package and the constraint
+// it places on it.
+type WhyEdge struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Specifier string `json:"specifier,omitempty"`
+	Conflict  bool   `json:"conflict,omitempty"`
+}
+
+// WhyReport explains why a package is installed: who requires it directly,
+// and the chains through which top-level packages pull it in.
+type WhyReport struct {
+	Venv       string     `json:"venv"`
+	Package    string     `json:"package"`
+	Version    string     `json:"version"`
+	TopLevel   bool       `json:"top_level"`
+	RequiredBy []WhyEdge  `json:"required_by"`
+	Paths      [][]string `json:"paths"`
+}
+
+// Why reports which top-level packages pull pkg into the venv.
+func (m *Manager) Why(name, pkg string) (*WhyReport, error) {
+	g, err := m.DependencyGraph(name)
+	if err != nil {
+		return nil, err
+	}
+	return g.Why(pkg)
+}
+
+// Why reports the direct dependents of pkg and the shortest chain from each
+// top-level package that reaches it.
+func (g *DepGraph) Why(pkg string) (*WhyReport, error) {
+	target := g.Node(pkg)
+	if target == nil {
+		return nil, fmt.Errorf("package %q is not installed in venv %q", pkg, g.Venv)
+	}
+	rep := &WhyReport{Venv: g.Venv, Package: target.Name, Version: target.Version, RequiredBy: []WhyEdge{}, Paths: [][]string{}}
+	for _, parent := range target.RequiredBy {
+		pn := g.Node(parent)
+		for _, d := range pn.Requires {
+			if normalizePkgName(d.Name) == normalizePkgName(target.Name) {
+				rep.RequiredBy = append(rep.RequiredBy, WhyEdge{Name: pn.Name, Version: pn.Version, Specifier: d.Specifier, Conflict: d.Conflict})
+			}
+		}
+	}
+	for _, r := range g.Roots {
+		if normalizePkgName(r) == normalizePkgName(target.Name) {
+			rep.TopLevel = true
+			rep.Paths = append(rep.Paths, []string{target.Name})
+			continue
+		}
+		if p := g.shortestPath(r, target.Name); p != nil {
+			rep.Paths = append(rep.Paths, p)
+		}
+	}
+	return rep, nil
+}
+
+// shortestPath is a BFS over installed edges from one package to another.
+func (g *DepGraph) shortestPath(from, to string) []string {
+	start, goal := normalizePkgName(from), normalizePkgName(to)
+	prev := map[string]string{start: ""}
+	queue := []string{start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur == goal {
+			var path []string
+			for k := cur; k != ""; k = prev[k] {
+				path = append([]string{g.index[k].Name}, path...)
+			}
+			return path
+		}
+		for _, d := range g.index[cur].Requires {
+			dk := normalizePkgName(d.Name)
+			if _, seen := prev[dk]; seen || d.Missing {
+				continue
+			}
+			prev[dk] = cur
+			queue = append(queue, dk)
+		}
+	}
+	return nil
+}
+
+// String renders the report for the CLI.
+func (r *WhyReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s==%s", r.Package, r.Version)
+	if r.TopLevel {
+		b.WriteString(" is a top-level package")
+	}
+	b.WriteString("\n")
+	if len(r.RequiredBy) > 0 {
+		b.WriteString("required by:\n")
+		for _, e := range r.RequiredBy {
+			spec := e.Specifier
+			if spec == "" {
+				spec = "any"
+			}
+			fmt.Fprintf(&b, "  %s==%s requires %s", e.Name, e.Version, spec)
+			if e.Conflict {
+				b.WriteString(" (CONFLICT)")
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(r.Paths) > 0 && !(r.TopLevel && len(r.Paths) == 1) {
+		b.WriteString("pulled in by:\n")
+		for _, p := range r.Paths {
+			fmt.Fprintf(&b, "  %s\n", strings.Join(p, " → "))
+		}
+	}
+	return b.String()
+}
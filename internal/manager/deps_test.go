@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseRequirement(t *testing.T) {
+	r, err := parseRequirement(`requests[socks, security] (>=2.0,<3) ; python_version >= "3.8"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "requests" || strings.Join(r.Extras, ",") != "socks,security" ||
+		r.Specifier != ">=2.0,<3" || r.Marker != `python_version >= "3.8"` {
+		t.Fatalf("unexpected: %+v", r)
+	}
+	r, err = parseRequirement("pkg @ https://example.com/pkg.whl")
+	if err != nil || r.Name != "pkg" || r.URL != "https://example.com/pkg.whl" {
+		t.Fatalf("url requirement: %+v %v", r, err)
+	}
+	if _, err := parseRequirement(">=1.0"); err == nil {
+		t.Fatal("requirement without name must fail")
+	}
+}
+
+func TestEvalMarker(t *testing.T) {
+	env := markerEnv{
+		"python_version": "3.11", "python_full_version": "3.11.7",
+		"sys_platform": "linux", "platform_system": "Linux",
+		"implementation_name": "cpython", "os_name": "posix",
+	}
+	cases := []struct {
+		marker, extra string
+		want          bool
+	}{
+		{``, "", true},
+		{`python_version >= "3.8"`, "", true},
+		{`python_version < "3.10"`, "", false},
+		{`python_version > "3.9"`, "", true}, // would be false as a string compare
+		{`sys_platform == "win32" or os_name == "posix"`, "", true},
+		{`sys_platform == "win32" and os_name == "posix"`, "", false},
+		{`(sys_platform == "darwin" or sys_platform == "linux") and python_full_version != "3.11.7"`, "", false},
+		{`extra == "socks"`, "", false},
+		{`extra == "socks"`, "socks", true},
+		{`extra == "Test_Utils"`, "test-utils", true},
+		{`"linux" in sys_platform`, "", true},
+		{`platform_system not in "Windows Darwin"`, "", true},
+	}
+	for _, c := range cases {
+		got, err := evalMarker(c.marker, env, c.extra)
+		if err != nil {
+			t.Errorf("evalMarker(%q): %v", c.marker, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("evalMarker(%q, extra=%q)=%v want %v", c.marker, c.extra, got, c.want)
+		}
+	}
+	if _, err := evalMarker(`bogus_var == "x"`, env, ""); err == nil {
+		t.Error("unknown variable must error")
+	}
+}
+
+func testGraph() *DepGraph {
+	env := markerEnv{"python_version": "3.11", "python_full_version": "3.11.7", "sys_platform": "linux", "os_name": "posix"}
+	pkgs := []InstalledPackage{
+		{Name: "requests", Version: "2.31.0", Requested: true, RequiresDist: []string{
+			"urllib3<3,>=1.21.1", "idna<4,>=2.5", `PySocks!=1.5.7,>=1.5.6; extra == "socks"`,
+		}},
+		{Name: "urllib3", Version: "1.26.0"},
+		{Name: "idna", Version: "3.6"},
+		{Name: "PySocks", Version: "1.7.1"},
+		{Name: "botocore", Version: "1.34.0", RequiresDist: []string{
+			"urllib3<1.27,>=1.25.4; python_version < \"3.10\"",
+			"urllib3<2.1,>=1.25.4; python_version >= \"3.10\"",
+			"jmespath<2.0.0,>=0.7.1",
+		}},
+		{Name: "boto3", Version: "1.34.0", Requested: true, RequiresDist: []string{"botocore<1.35.0,>=1.34.0", "requests[socks]"}},
+		{Name: "leftover", Version: "0.1"},
+	}
+	return buildDepGraph("v", pkgs, env, nil)
+}
+
+func TestBuildDepGraph(t *testing.T) {
+	g := testGraph()
+	if got := strings.Join(g.Roots, ","); got != "boto3,leftover" {
+		t.Fatalf("roots=%s", got)
+	}
+	bc := g.Node("botocore")
+	if len(bc.Requires) != 2 {
+		t.Fatalf("botocore edges=%+v", bc.Requires)
+	}
+	if !bc.Requires[0].Missing || bc.Requires[0].Name != "jmespath" {
+		t.Fatalf("expected missing jmespath: %+v", bc.Requires[0])
+	}
+	if bc.Requires[1].Specifier != "<2.1,>=1.25.4" {
+		t.Fatalf("marker-selected urllib3 spec wrong: %+v", bc.Requires[1])
+	}
+	// requests[socks] via boto3 activates the PySocks edge.
+	if n := g.Node("pysocks"); n == nil || strings.Join(n.RequiredBy, ",") != "requests" {
+		t.Fatalf("extra-activated edge missing: %+v", n)
+	}
+	if got := strings.Join(g.Node("URLLIB3").RequiredBy, ","); got != "botocore,requests" {
+		t.Fatalf("urllib3 required_by=%s", got)
+	}
+}
+
+func TestDepGraphCycleStillHasRoot(t *testing.T) {
+	g := buildDepGraph("v", []InstalledPackage{
+		{Name: "a", Version: "1", RequiresDist: []string{"b"}},
+		{Name: "b", Version: "1", RequiresDist: []string{"a"}},
+	}, markerEnv{}, nil)
+	if len(g.Roots) != 1 || g.Roots[0] != "a" {
+		t.Fatalf("roots=%v", g.Roots)
+	}
+	var buf bytes.Buffer
+	g.WriteTree(&buf) // must terminate
+	if !strings.Contains(buf.String(), "b==1") {
+		t.Fatalf("tree=%q", buf.String())
+	}
+}
+
+func TestDepGraphWhy(t *testing.T) {
+	g := testGraph()
+	rep, err := g.Why("urllib3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.TopLevel || len(rep.RequiredBy) != 2 || len(rep.Paths) != 1 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	if got := strings.Join(rep.Paths[0], ">"); got != "boto3>botocore>urllib3" {
+		t.Fatalf("path=%s", got)
+	}
+	if _, err := g.Why("nope"); err == nil {
+		t.Fatal("expected error for uninstalled package")
+	}
+	rep, _ = g.Why("leftover")
+	if !rep.TopLevel {
+		t.Fatalf("leftover is a root: %+v", rep)
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	var buf bytes.Buffer
+	testGraph().WriteTree(&buf)
+	out := buf.String()
+	for _, want := range []string{"boto3==1.34.0", "└── requests==2.31.0 [required: any]", "jmespath [required: <2.0.0,>=0.7.1, MISSING]", "leftover==0.1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("tree missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -15,18 +15,21 @@ import (
 // endpoint an AI (or script) should call to get everything it needs to reason
 // about an environment.
 type Description struct {
-	Name          string            `json:"name"`
-	Path          string            `json:"path"`
-	PythonVersion string            `json:"python_version,omitempty"`
-	PythonPath    string            `json:"python_path"`
-	PipPath       string            `json:"pip_path"`
-	Packages      []string          `json:"packages"`
-	PackageCount  int               `json:"package_count"`
-	SizeBytes     int64             `json:"size_bytes"`
-	SizeHuman     string            `json:"size_human"`
-	ModifiedAt    time.Time         `json:"modified_at"`
-	FreezeHash    string            `json:"freeze_hash"`
-	Activation    map[string]string `json:"activation"`
+	Name          string `json:"name"`
+	Path          string `json:"path"`
+	PythonVersion string `json:"python_version,omitempty"`
+	// Interpreter is how the python was chosen at create time; nil for
+	// venvs created before this was recorded.
+	Interpreter  *PythonChoice     `json:"interpreter,omitempty"`
+	PythonPath   string            `json:"python_path"`
+	PipPath      string            `json:"pip_path"`
+	Packages     []string          `json:"packages"`
+	PackageCount int               `json:"package_count"`
+	SizeBytes    int64             `json:"size_bytes"`
+	SizeHuman    string            `json:"size_human"`
+	ModifiedAt   time.Time         `json:"modified_at"`
+	FreezeHash   string            `json:"freeze_hash"`
+	Activation   map[string]string `json:"activation"`
 }
 
 // Describe returns a full Description of a venv.
@@ -39,7 +42,10 @@ func (m *Manager) Describe(name string) (*Description, error) {
 	if err != nil {
 		return nil, err
 	}
-	sizes, _ := m.GetSize(name)
+	var size int64
+	if rep, err := m.GetSize(name); err == nil {
+		size = rep.Results[0].SizeBytes
+	}
 
 	verOut, _ := exec.Command(utils.PythonPath(venvPath), "-c", "import sys;print('%d.%d.%d'%sys.version_info[:3])").Output()
 	ver := strings.TrimSpace(string(verOut))
@@ -63,12 +69,13 @@ func (m *Manager) Describe(name string) (*Description, error) {
 		Name:          name,
 		Path:          venvPath,
 		PythonVersion: ver,
+		Interpreter:   readPythonChoice(venvPath),
 		PythonPath:    utils.PythonPath(venvPath),
 		PipPath:       utils.PipPath(venvPath),
 		Packages:      pkgs,
 		PackageCount:  len(pkgs),
-		SizeBytes:     sizes[name],
-		SizeHuman:     utils.FormatSize(sizes[name]),
+		SizeBytes:     size,
+		SizeHuman:     utils.FormatSize(size),
 		ModifiedAt:    mtime,
 		FreezeHash:    hex.EncodeToString(h[:]),
 		Activation:    activation,
@@ -0,0 +1,239 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// freezeEntry is one package pinned in a freeze/manifest. Version holds the
+// "==" pin, or the "@ url" / "-e ..." reference for non-index installs.
+type freezeEntry struct {
+	Name    string
+	Version string
+}
+
+// requirement renders the entry back into a requirement line.
+func (e freezeEntry) requirement() string {
+	switch {
+	case strings.HasPrefix(e.Version, "-e "):
+		return e.Version
+	case strings.HasPrefix(e.Version, "@ "):
+		return e.Name + " " + e.Version
+	}
+	return e.Name + "==" + e.Version
+}
+
+// parseFreeze parses `pip freeze` output (or manifest requirements) into a
+// map keyed by normalized package name.
+func parseFreeze(lines []string) map[string]freezeEntry {
+	out := map[string]freezeEntry{}
+	editable := ""
+	for _, ln := range lines {
+		ln = strings.TrimSpace(ln)
+		if rest, ok := strings.CutPrefix(ln, "# Editable install"); ok {
+			// pip names a plain-directory editable only in the comment before
+			// it: "# Editable install with no version control (name==1.0)".
+			if _, pin, ok := strings.Cut(rest, "("); ok {
+				editable, _, _ = strings.Cut(pin, "==")
+			}
+			continue
+		}
+		if ln == "" || strings.HasPrefix(ln, "#") {
+			continue
+		}
+		var e freezeEntry
+		switch {
+		case strings.HasPrefix(ln, "-e "):
+			// "-e git+https://...#egg=name": the name lives in the fragment.
+			ref := strings.TrimSpace(ln[3:])
+			name := editable
+			if _, egg, ok := strings.Cut(ref, "#egg="); ok {
+				name = strings.SplitN(egg, "&", 2)[0]
+			}
+			if name == "" {
+				name = filepath.Base(ref)
+			}
+			e = freezeEntry{Name: strings.TrimSpace(name), Version: "-e " + ref}
+		case strings.Contains(ln, " @ "):
+			name, url, _ := strings.Cut(ln, " @ ")
+			e = freezeEntry{Name: strings.TrimSpace(name), Version: "@ " + strings.TrimSpace(url)}
+		case strings.Contains(ln, "==="):
+			name, ver, _ := strings.Cut(ln, "===")
+			e = freezeEntry{Name: strings.TrimSpace(name), Version: strings.TrimSpace(ver)}
+		case strings.Contains(ln, "=="):
+			name, ver, _ := strings.Cut(ln, "==")
+			e = freezeEntry{Name: strings.TrimSpace(name), Version: strings.TrimSpace(ver)}
+		default:
+			continue
+		}
+		editable = ""
+		out[normalizePkgName(e.Name)] = e
+	}
+	return out
+}
+
+// envState is one side of a diff.
+type envState struct {
+	Label    string
+	Python   string
+	Packages map[string]freezeEntry
+}
+
+// PackageChange is a package that differs between two environments.
+type PackageChange struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// EnvDiff is the difference between two environments, from Left to Right.
+type EnvDiff struct {
+	Left          string          `json:"left"`
+	Right         string          `json:"right"`
+	LeftPython    string          `json:"left_python,omitempty"`
+	RightPython   string          `json:"right_python,omitempty"`
+	PythonChanged bool            `json:"python_changed"`
+	Added         []PackageChange `json:"added"`
+	Removed       []PackageChange `json:"removed"`
+	Upgraded      []PackageChange `json:"upgraded"`
+	Downgraded    []PackageChange `json:"downgraded"`
+	// Changed holds packages whose pins are not comparable as versions,
+	// e.g. a switch between an index release and a VCS/URL install.
+	Changed []PackageChange `json:"changed"`
+}
+
+// Identical reports whether the two sides have the same packages and Python.
+func (d *EnvDiff) Identical() bool {
+	return !d.PythonChanged && len(d.Added)+len(d.Removed)+len(d.Upgraded)+len(d.Downgraded)+len(d.Changed) == 0
+}
+
+// Diff compares two environments. Each reference can be a venv name, a
+// "snapshot:<venv>/<id>" reference, or the path of an exported Manifest.
+func (m *Manager) Diff(a, b string) (*EnvDiff, error) {
+	left, err := m.resolveEnvRef(a)
+	if err != nil {
+		return nil, err
+	}
+	right, err := m.resolveEnvRef(b)
+	if err != nil {
+		return nil, err
+	}
+	return diffStates(left, right), nil
+}
+
+func (m *Manager) resolveEnvRef(ref string) (*envState, error) {
+	if rest, ok := strings.CutPrefix(ref, "snapshot:"); ok {
+		venv, id, ok := strings.Cut(rest, "/")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid snapshot reference %q: want snapshot:<venv>/<id>", ref)
+		}
+		snap, err := m.findSnapshot(venv, id)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(snap.Path)
+		if err != nil {
+			return nil, err
+		}
+		return newEnvState(ref, snap.PythonVersion, strings.Split(string(data), "\n")), nil
+	}
+	if ValidateName(ref) == nil && m.fs.Exists(m.VenvPath(ref)) {
+		pkgs, err := m.InstalledPackages(ref)
+		if err != nil {
+			return nil, err
+		}
+		// Render each package the way pip freeze would, so a venv compares
+		// cleanly against a snapshot of itself.
+		lines := make([]string, len(pkgs))
+		for i, p := range pkgs {
+			lines[i], _ = repairSpec(p)
+		}
+		return newEnvState(ref, venvPythonVersion(m.VenvPath(ref)), lines), nil
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%q is neither a venv, a snapshot:<venv>/<id> reference nor a manifest file", ref)
+		}
+		return nil, err
+	}
+	var mf Manifest
+	if err := json.Unmarshal(data, &mf); err != nil {
+		return nil, fmt.Errorf("%s: not a manifest: %v", ref, err)
+	}
+	return newEnvState(ref, mf.PythonVersion, mf.Requirements), nil
+}
+
+// newEnvState parses requirement lines into an envState. The seed packages
+// are dropped: pip freeze leaves them out, so a snapshot never has them while
+// a venv or an exported manifest does.
+func newEnvState(label, python string, lines []string) *envState {
+	pkgs := parseFreeze(lines)
+	for name := range seedPackages {
+		delete(pkgs, name)
+	}
+	return &envState{Label: label, Python: python, Packages: pkgs}
+}
+
+func diffStates(a, b *envState) *EnvDiff {
+	d := &EnvDiff{
+		Left: a.Label, Right: b.Label,
+		LeftPython: a.Python, RightPython: b.Python,
+		PythonChanged: !samePythonVersion(a.Python, b.Python),
+		Added:         []PackageChange{}, Removed: []PackageChange{},
+		Upgraded: []PackageChange{}, Downgraded: []PackageChange{}, Changed: []PackageChange{},
+	}
+	keys := map[string]bool{}
+	for k := range a.Packages {
+		keys[k] = true
+	}
+	for k := range b.Packages {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		from, inA := a.Packages[k]
+		to, inB := b.Packages[k]
+		switch {
+		case !inA:
+			d.Added = append(d.Added, PackageChange{Name: to.Name, To: to.Version})
+		case !inB:
+			d.Removed = append(d.Removed, PackageChange{Name: from.Name, From: from.Version})
+		case from.Version == to.Version:
+		default:
+			c := PackageChange{Name: to.Name, From: from.Version, To: to.Version}
+			fv, ferr := parseVersion(from.Version)
+			tv, terr := parseVersion(to.Version)
+			switch {
+			case ferr != nil || terr != nil:
+				d.Changed = append(d.Changed, c)
+			case compareVersions(fv, tv) < 0:
+				d.Upgraded = append(d.Upgraded, c)
+			case compareVersions(fv, tv) > 0:
+				d.Downgraded = append(d.Downgraded, c)
+			}
+		}
+	}
+	return d
+}
+
+// samePythonVersion compares two versions on the components both specify,
+// so a manifest's "3.12" matches a venv's "3.12.4". Unknown versions match.
+func samePythonVersion(a, b string) bool {
+	if a == "" || b == "" {
+		return true
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	n := len(as)
+	if len(bs) < n {
+		n = len(bs)
+	}
+	for i := 0; i < n; i++ {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package manager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+func TestParseFreeze(t *testing.T) {
+	got := parseFreeze([]string{
+		"Requests==2.31.0",
+		"mypkg @ file:///tmp/mypkg-1.0-py3-none-any.whl",
+		"-e git+https://example.com/repo.git@abc123#egg=editable_pkg",
+		"# comment",
+		"odd===1.0-custom",
+		"",
+	})
+	if len(got) != 4 {
+		t.Fatalf("expected 4 entries, got %v", got)
+	}
+	if e := got["requests"]; e.Name != "Requests" || e.Version != "2.31.0" {
+		t.Fatalf("requests=%+v", e)
+	}
+	if e := got["mypkg"]; e.Version != "@ file:///tmp/mypkg-1.0-py3-none-any.whl" {
+		t.Fatalf("mypkg=%+v", e)
+	}
+	if e := got["editable-pkg"]; e.Name != "editable_pkg" {
+		t.Fatalf("editable=%+v", e)
+	}
+}
+
+func TestDiffStates(t *testing.T) {
+	a := &envState{Label: "a", Python: "3.11.7", Packages: parseFreeze([]string{
+		"requests==2.31.0", "urllib3==2.2.1", "six==1.16.0", "mypkg==1.0",
+	})}
+	b := &envState{Label: "b", Python: "3.12", Packages: parseFreeze([]string{
+		"requests==2.32.0", "urllib3==1.26.18", "rich==13.0", "mypkg @ file:///x.whl",
+	})}
+	d := diffStates(a, b)
+	if !d.PythonChanged {
+		t.Error("3.11.7 vs 3.12 must be a python change")
+	}
+	if len(d.Added) != 1 || d.Added[0].Name != "rich" {
+		t.Errorf("added=%+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Name != "six" {
+		t.Errorf("removed=%+v", d.Removed)
+	}
+	if len(d.Upgraded) != 1 || d.Upgraded[0].Name != "requests" {
+		t.Errorf("upgraded=%+v", d.Upgraded)
+	}
+	if len(d.Downgraded) != 1 || d.Downgraded[0].From != "2.2.1" {
+		t.Errorf("downgraded=%+v", d.Downgraded)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Name != "mypkg" {
+		t.Errorf("changed=%+v", d.Changed)
+	}
+	if diffStates(a, a).Identical() != true {
+		t.Error("a vs a must be identical")
+	}
+}
+
+func TestSamePythonVersion(t *testing.T) {
+	if !samePythonVersion("3.12", "3.12.4") || !samePythonVersion("", "3.11") {
+		t.Error("expected match")
+	}
+	if samePythonVersion("3.11.7", "3.12") {
+		t.Error("expected mismatch")
+	}
+}
+
+func TestDiffVenvAgainstManifest(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	writeDistInfo(t, sp, "requests", "2.31.0", nil, true)
+	mf := filepath.Join(t.TempDir(), "m.json")
+	data, _ := json.Marshal(Manifest{Name: "x", Requirements: []string{"requests==2.30.0", "idna==3.6"}})
+	os.WriteFile(mf, data, 0o644)
+
+	d, err := m.Diff(mf, "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Upgraded) != 1 || len(d.Removed) != 1 || d.Removed[0].Name != "idna" {
+		t.Fatalf("unexpected diff: %+v", d)
+	}
+	if _, err := m.Diff("missing-venv", "v"); err == nil {
+		t.Fatal("expected error for unknown reference")
+	}
+	if _, err := m.Diff("snapshot:v", "v"); err == nil {
+		t.Fatal("expected error for malformed snapshot reference")
+	}
+}
+
+func TestParseFreezeNamesPlainEditables(t *testing.T) {
+	got := parseFreeze([]string{
+		"# Editable install with no version control (proj==1.0)",
+		"-e /src/proj",
+		"-e /src/other",
+	})
+	if e := got["proj"]; e.Version != "-e /src/proj" {
+		t.Fatalf("proj = %+v", e)
+	}
+	if e := got["other"]; e.Version != "-e /src/other" {
+		t.Fatalf("other = %+v", e)
+	}
+}
+
+func TestDiffVenvAgainstOwnSnapshot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pip is a shell script")
+	}
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	writeDistInfo(t, sp, "pip", "24.0", nil, false)
+	writeDistInfo(t, sp, "setuptools", "69.0.0", nil, false)
+	writeDistInfo(t, sp, "requests", "2.31.0", nil, true)
+	writeDistInfo(t, sp, "proj", "1.0", nil, true)
+	src := filepath.Join(dir, "src", "proj")
+	os.WriteFile(filepath.Join(sp, "proj-1.0.dist-info", "direct_url.json"),
+		[]byte(`{"url": "file://`+filepath.ToSlash(src)+`", "dir_info": {"editable": true}}`), 0o644)
+	// What pip freeze prints for that venv: no seed packages, and the
+	// editable named only in the comment above it.
+	bin := utils.VenvBinDir(filepath.Join(dir, "v"))
+	os.MkdirAll(bin, 0o755)
+	script := "#!/bin/sh\n[ \"$1\" = freeze ] || exit 1\n" +
+		"echo requests==2.31.0\n" +
+		"echo '# Editable install with no version control (proj==1.0)'\n" +
+		"echo '-e " + src + "'\n"
+	os.WriteFile(filepath.Join(bin, "pip"), []byte(script), 0o755)
+
+	snap, err := m.CreateSnapshot("v", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := m.Diff("v", "snapshot:v/"+snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Identical() {
+		t.Fatalf("venv differs from its own snapshot: %+v", d)
+	}
+}
@@ -0,0 +1,273 @@
+package manager
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// InstalledPackage is one distribution found in a venv's site-packages, read
+// straight from its *.dist-info / *.egg-info metadata.
+type InstalledPackage struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Summary      string   `json:"summary,omitempty"`
+	RequiresDist []string `json:"requires_dist,omitempty"`
+	Installer    string   `json:"installer,omitempty"`
+	// Requested is true when the distribution was asked for explicitly
+	// (dist-info REQUESTED marker) rather than pulled in as a dependency.
+	Requested bool `json:"requested,omitempty"`
+	// MetadataPath is the *.dist-info / *.egg-info entry it was read from.
+	MetadataPath string `json:"metadata_path"`
+}
+
+// Spec returns the "name==version" form used by ListPackages.
+func (p InstalledPackage) Spec() string { return p.Name + "==" + p.Version }
+
+// sitePackagesDirs returns the site-packages directories of a venv. POSIX
+// venvs use lib/pythonX.Y/site-packages (lib64 is usually a symlink to lib,
+// so it is not globbed separately); Windows uses Lib/site-packages.
+func sitePackagesDirs(venvPath string) []string {
+	if runtime.GOOS == "windows" {
+		p := filepath.Join(venvPath, "Lib", "site-packages")
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			return []string{p}
+		}
+		return nil
+	}
+	matches, _ := filepath.Glob(filepath.Join(venvPath, "lib", "python*", "site-packages"))
+	var dirs []string
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+			dirs = append(dirs, m)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// readInstalled walks every site-packages dir of a venv and parses the
+// metadata of each installed distribution. Returns an error when the venv has
+// no site-packages at all, so callers can fall back to pip.
+func readInstalled(venvPath string) ([]InstalledPackage, error) {
+	dirs := sitePackagesDirs(venvPath)
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no site-packages found in %s", venvPath)
+	}
+	seen := map[string]bool{}
+	var pkgs []InstalledPackage
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			var (
+				pkg *InstalledPackage
+				err error
+			)
+			p := filepath.Join(dir, e.Name())
+			switch {
+			case strings.HasSuffix(e.Name(), ".dist-info") && e.IsDir():
+				pkg, err = readDistInfo(p)
+			case strings.HasSuffix(e.Name(), ".egg-info"):
+				pkg, err = readEggInfo(p, e.IsDir())
+			default:
+				continue
+			}
+			// A half-removed or corrupt metadata dir shouldn't hide the
+			// rest of the venv; pip skips these too.
+			if err != nil || pkg.Name == "" {
+				continue
+			}
+			key := normalizePkgName(pkg.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			pkgs = append(pkgs, *pkg)
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return strings.ToLower(pkgs[i].Name) < strings.ToLower(pkgs[j].Name)
+	})
+	return pkgs, nil
+}
+
+func readDistInfo(dir string) (*InstalledPackage, error) {
+	f, err := os.Open(filepath.Join(dir, "METADATA"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pkg, err := parseMetadata(f)
+	if err != nil {
+		return nil, err
+	}
+	pkg.MetadataPath = dir
+	if b, err := os.ReadFile(filepath.Join(dir, "INSTALLER")); err == nil {
+		pkg.Installer = strings.TrimSpace(string(b))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "REQUESTED")); err == nil {
+		pkg.Requested = true
+	}
+	return pkg, nil
+}
+
+// readRecord returns the paths listed in a dist-info RECORD, slash-separated
+// and relative to the site-packages dir holding the dist-info.
+func readRecord(distInfo string) ([]string, error) {
+	f, err := os.Open(filepath.Join(distInfo, "RECORD"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	var paths []string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF || (err != nil && len(rec) == 0) {
+			break
+		}
+		if rec[0] != "" {
+			paths = append(paths, filepath.ToSlash(rec[0]))
+		}
+	}
+	return paths, nil
+}
+
+// readEggInfo handles legacy setuptools installs: either a directory holding
+// PKG-INFO (+ requires.txt) or a single PKG-INFO-formatted file.
+func readEggInfo(path string, isDir bool) (*InstalledPackage, error) {
+	metaPath := path
+	if isDir {
+		metaPath = filepath.Join(path, "PKG-INFO")
+	}
+	f, err := os.Open(metaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pkg, err := parseMetadata(f)
+	if err != nil {
+		return nil, err
+	}
+	pkg.MetadataPath = path
+	if isDir && len(pkg.RequiresDist) == 0 {
+		if b, err := os.ReadFile(filepath.Join(path, "requires.txt")); err == nil {
+			pkg.RequiresDist = parseEggRequires(string(b))
+		}
+	}
+	return pkg, nil
+}
+
+// parseMetadata reads the RFC 822-style header block of a METADATA/PKG-INFO
+// file. The body (long description) after the first blank line is ignored.
+func parseMetadata(r io.Reader) (*InstalledPackage, error) {
+	pkg := &InstalledPackage{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lastKey string
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			break
+		}
+		// Continuation lines only matter for folded Summary values.
+		if line[0] == ' ' || line[0] == '\t' {
+			if lastKey == "summary" {
+				pkg.Summary += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		lastKey = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		switch lastKey {
+		case "name":
+			pkg.Name = val
+		case "version":
+			pkg.Version = val
+		case "summary":
+			pkg.Summary = val
+		case "requires-dist":
+			pkg.RequiresDist = append(pkg.RequiresDist, val)
+		}
+	}
+	return pkg, sc.Err()
+}
+
+// parseEggRequires converts an egg-info requires.txt into Requires-Dist
+// strings. Sections look like "[extra]", "[:marker]" or "[extra:marker]".
+func parseEggRequires(s string) []string {
+	var out []string
+	section := ""
+	for _, ln := range strings.Split(s, "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" || strings.HasPrefix(ln, "#") {
+			continue
+		}
+		if strings.HasPrefix(ln, "[") && strings.HasSuffix(ln, "]") {
+			section = ln[1 : len(ln)-1]
+			continue
+		}
+		if section == "" {
+			out = append(out, ln)
+			continue
+		}
+		extra, marker, _ := strings.Cut(section, ":")
+		var conds []string
+		if extra != "" {
+			conds = append(conds, fmt.Sprintf("extra == %q", extra))
+		}
+		if marker != "" {
+			conds = append(conds, "("+marker+")")
+		}
+		out = append(out, ln+"; "+strings.Join(conds, " and "))
+	}
+	return out
+}
+
+// InstalledPackages returns the distributions installed in a venv, read from
+// site-packages metadata. Falls back to `pip list` when site-packages cannot
+// be located (unusual layouts); the fallback only fills Name and Version.
+func (m *Manager) InstalledPackages(name string) ([]InstalledPackage, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	if pkgs, err := readInstalled(venvPath); err == nil {
+		return pkgs, nil
+	}
+	return m.pipListPackages(venvPath)
+}
+
+func (m *Manager) pipListPackages(venvPath string) ([]InstalledPackage, error) {
+	output, err := m.pkg(venvPath).list().Output()
+	if err != nil {
+		return nil, err
+	}
+	var packages []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(output, &packages); err != nil {
+		return nil, err
+	}
+	out := make([]InstalledPackage, len(packages))
+	for i, p := range packages {
+		out[i] = InstalledPackage{Name: p.Name, Version: p.Version}
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSitePackages creates an empty site-packages layout for venv "v" and
+// returns its path.
+func fakeSitePackages(t *testing.T, dir string) string {
+	t.Helper()
+	sp := filepath.Join(dir, "v", "lib", "python3.12", "site-packages")
+	if runtime.GOOS == "windows" {
+		sp = filepath.Join(dir, "v", "Lib", "site-packages")
+	}
+	if err := os.MkdirAll(sp, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return sp
+}
+
+func writeDistInfo(t *testing.T, sp, name, version string, requires []string, requested bool) {
+	t.Helper()
+	di := filepath.Join(sp, name+"-"+version+".dist-info")
+	if err := os.MkdirAll(di, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	b.WriteString("Metadata-Version: 2.1\nName: " + name + "\nVersion: " + version + "\nSummary: the " + name + " package\n")
+	for _, r := range requires {
+		b.WriteString("Requires-Dist: " + r + "\n")
+	}
+	b.WriteString("\nLong description.\nName: not-a-header\n")
+	os.WriteFile(filepath.Join(di, "METADATA"), []byte(b.String()), 0o644)
+	os.WriteFile(filepath.Join(di, "INSTALLER"), []byte("pip\n"), 0o644)
+	if requested {
+		os.WriteFile(filepath.Join(di, "REQUESTED"), nil, 0o644)
+	}
+}
+
+func TestInstalledPackagesReadsDistInfo(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	writeDistInfo(t, sp, "requests", "2.31.0", []string{"urllib3<3,>=1.21.1", "PySocks!=1.5.7; extra == \"socks\""}, true)
+	writeDistInfo(t, sp, "urllib3", "2.2.1", nil, false)
+	os.MkdirAll(filepath.Join(sp, "broken-1.0.dist-info"), 0o755) // no METADATA
+
+	pkgs, err := m.InstalledPackages("v")
+	if err != nil {
+		t.Fatalf("InstalledPackages: %v", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("expected 2 packages, got %+v", pkgs)
+	}
+	req := pkgs[0]
+	if req.Name != "requests" || req.Version != "2.31.0" || req.Summary != "the requests package" {
+		t.Fatalf("unexpected metadata: %+v", req)
+	}
+	if len(req.RequiresDist) != 2 || req.Installer != "pip" || !req.Requested {
+		t.Fatalf("unexpected requires/installer/requested: %+v", req)
+	}
+	if pkgs[1].Requested {
+		t.Fatalf("urllib3 should not be marked requested: %+v", pkgs[1])
+	}
+
+	specs, err := m.ListPackages("v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(specs, ",") != "requests==2.31.0,urllib3==2.2.1" {
+		t.Fatalf("ListPackages=%v", specs)
+	}
+}
+
+func TestInstalledPackagesReadsEggInfo(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	egg := filepath.Join(sp, "legacy.egg-info")
+	os.MkdirAll(egg, 0o755)
+	os.WriteFile(filepath.Join(egg, "PKG-INFO"), []byte("Metadata-Version: 1.0\nName: legacy\nVersion: 0.3\n"), 0o644)
+	os.WriteFile(filepath.Join(egg, "requires.txt"), []byte("six\n\n[tests]\npytest\n\n[:python_version < \"3.8\"]\nimportlib-metadata\n"), 0o644)
+	os.WriteFile(filepath.Join(sp, "single-1.0-py3.12.egg-info"), []byte("Name: single\nVersion: 1.0\n"), 0o644)
+
+	pkgs, err := m.InstalledPackages("v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 2 || pkgs[0].Name != "legacy" || pkgs[1].Name != "single" {
+		t.Fatalf("unexpected packages: %+v", pkgs)
+	}
+	want := []string{"six", `pytest; extra == "tests"`, `importlib-metadata; (python_version < "3.8")`}
+	if strings.Join(pkgs[0].RequiresDist, "|") != strings.Join(want, "|") {
+		t.Fatalf("RequiresDist=%q want %q", pkgs[0].RequiresDist, want)
+	}
+}
+
+func TestParseMetadataFoldedSummary(t *testing.T) {
+	pkg, err := parseMetadata(strings.NewReader("Name: x\nSummary: first\n  second\nVersion: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Summary != "first second" || pkg.Version != "1" {
+		t.Fatalf("unexpected: %+v", pkg)
+	}
+}
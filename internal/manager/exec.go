@@ -37,6 +37,11 @@ func (m *Manager) Exec(opts ExecOptions, argv []string) error {
 	if _, err := rand.Read(buf); err != nil {
 		return err
 	}
+	if opts.RequirementsFile != "" || len(opts.Packages) > 0 {
+		if err := m.checkOffline(); err != nil {
+			return err
+		}
+	}
 	tempName := "eph-" + hex.EncodeToString(buf)
 	venvPath := m.VenvPath(tempName)
 
@@ -44,7 +49,7 @@ func (m *Manager) Exec(opts ExecOptions, argv []string) error {
 		return err
 	}
 	if !opts.Keep {
-		defer m.fs.RemoveAll(venvPath)
+		defer m.removeEphemeral(tempName)
 	} else {
 		defer fmt.Fprintf(os.Stderr, "kept ephemeral venv at %s\n", venvPath)
 	}
@@ -55,8 +60,7 @@ func (m *Manager) Exec(opts ExecOptions, argv []string) error {
 		}
 	}
 	if len(opts.Packages) > 0 {
-		args := append([]string{"install"}, opts.Packages...)
-		if out, err := exec.Command(utils.PipPath(venvPath), args...).CombinedOutput(); err != nil {
+		if out, err := m.runInstall(venvPath, opts.Packages...); err != nil {
 			return fmt.Errorf("failed to install packages: %v\n%s", err, out)
 		}
 	}
@@ -94,6 +98,21 @@ func (m *Manager) Exec(opts ExecOptions, argv []string) error {
 	return cmd.Run()
 }
 
+// removeEphemeral deletes an exec venv and its lock file, under its lock.
+func (m *Manager) removeEphemeral(name string) {
+	unlock, err := m.lock(name, "exec")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to remove ephemeral venv %s: %v\n", name, err)
+		return
+	}
+	defer unlock()
+	if err := m.fs.RemoveAll(m.VenvPath(name)); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to remove ephemeral venv %s: %v\n", name, err)
+		return
+	}
+	m.dropLock(name)
+}
+
 // sandboxWrap returns the wrapper binary + its args for sandboxed execution.
 // The final argv (command to run) is appended by the caller.
 //
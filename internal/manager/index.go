@@ -0,0 +1,268 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// IndexConfig is where pip and uv fetch packages from. Fields left empty
+// fall back to pip's own configuration (pip.conf, PIP_* variables).
+type IndexConfig struct {
+	IndexURL       string   `json:"index_url,omitempty"`
+	ExtraIndexURLs []string `json:"extra_index_urls,omitempty"`
+	TrustedHosts   []string `json:"trusted_hosts,omitempty"`
+	// Cert is a CA bundle; ClientCert a PEM file with client cert and key.
+	Cert       string `json:"cert,omitempty"`
+	ClientCert string `json:"client_cert,omitempty"`
+	// Env holds extra variables for pip/uv (e.g. PIP_TIMEOUT, UV_HTTP_TIMEOUT).
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// IsZero reports whether c sets nothing. An empty but non-nil
+// ExtraIndexURLs or TrustedHosts is set: in an override it clears the
+// global list.
+func (c IndexConfig) IsZero() bool {
+	return c.IndexURL == "" && c.ExtraIndexURLs == nil && c.TrustedHosts == nil &&
+		c.Cert == "" && c.ClientCert == "" && len(c.Env) == 0
+}
+
+// MarshalJSON writes an empty ExtraIndexURLs or TrustedHosts as [] instead
+// of dropping it, so a saved override that clears a list still clears it
+// when read back.
+func (c IndexConfig) MarshalJSON() ([]byte, error) {
+	type plain IndexConfig
+	return json.Marshal(struct {
+		plain
+		ExtraIndexURLs *[]string `json:"extra_index_urls,omitempty"`
+		TrustedHosts   *[]string `json:"trusted_hosts,omitempty"`
+	}{plain(c), setList(c.ExtraIndexURLs), setList(c.TrustedHosts)})
+}
+
+func setList(l []string) *[]string {
+	if l == nil {
+		return nil
+	}
+	return &l
+}
+
+// Merge returns c with every field set in o taking precedence; an empty
+// non-nil list in o clears c's. Env is merged key by key.
+func (c IndexConfig) Merge(o IndexConfig) IndexConfig {
+	out := c
+	if o.IndexURL != "" {
+		out.IndexURL = o.IndexURL
+	}
+	if o.ExtraIndexURLs != nil {
+		out.ExtraIndexURLs = o.ExtraIndexURLs
+	}
+	if o.TrustedHosts != nil {
+		out.TrustedHosts = o.TrustedHosts
+	}
+	if o.Cert != "" {
+		out.Cert = o.Cert
+	}
+	if o.ClientCert != "" {
+		out.ClientCert = o.ClientCert
+	}
+	if len(c.Env)+len(o.Env) > 0 {
+		out.Env = map[string]string{}
+		for k, v := range c.Env {
+			out.Env[k] = v
+		}
+		for k, v := range o.Env {
+			out.Env[k] = v
+		}
+	}
+	return out
+}
+
+// Environ returns the variables that make pip and uv use c, in a stable
+// order. Env entries come last so they can override the derived ones.
+func (c IndexConfig) Environ() []string {
+	var env []string
+	set := func(k, v string) {
+		if v != "" {
+			env = append(env, k+"="+v)
+		}
+	}
+	set("PIP_INDEX_URL", c.IndexURL)
+	set("UV_INDEX_URL", c.IndexURL)
+	set("PIP_EXTRA_INDEX_URL", strings.Join(c.ExtraIndexURLs, " "))
+	set("UV_EXTRA_INDEX_URL", strings.Join(c.ExtraIndexURLs, " "))
+	set("PIP_TRUSTED_HOST", strings.Join(c.TrustedHosts, " "))
+	set("UV_INSECURE_HOST", strings.Join(c.TrustedHosts, " "))
+	set("PIP_CERT", c.Cert)
+	set("SSL_CERT_FILE", c.Cert)
+	set("PIP_CLIENT_CERT", c.ClientCert)
+	set("SSL_CLIENT_CERT", c.ClientCert)
+	keys := make([]string, 0, len(c.Env))
+	for k := range c.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+c.Env[k])
+	}
+	return env
+}
+
+// Redacted returns c with the credentials in its URLs masked, for display:
+// "user:pass@" becomes "user:****@" and a bare token "tok@" becomes "****@",
+// as pip shows them. Env values are masked the same way, and the whole value
+// is masked when the variable's name looks like a credential.
+func (c IndexConfig) Redacted() IndexConfig {
+	out := c
+	out.IndexURL = redactURLs(c.IndexURL)
+	if c.ExtraIndexURLs != nil {
+		out.ExtraIndexURLs = make([]string, len(c.ExtraIndexURLs))
+		for i, u := range c.ExtraIndexURLs {
+			out.ExtraIndexURLs[i] = redactURLs(u)
+		}
+	}
+	if c.Env != nil {
+		out.Env = make(map[string]string, len(c.Env))
+		for k, v := range c.Env {
+			if v != "" && secretEnvKey.MatchString(k) {
+				out.Env[k] = "****"
+			} else {
+				out.Env[k] = redactURLs(v)
+			}
+		}
+	}
+	return out
+}
+
+// secretEnvKey matches env variable names whose values are credentials
+// rather than URLs, e.g. UV_INDEX_TOKEN or PIP_PASSWORD.
+var secretEnvKey = regexp.MustCompile(`(?i)token|password|secret|auth|key`)
+
+// redactURLs masks the credentials of every URL in a space-separated list,
+// leaving anything else as it is.
+func redactURLs(s string) string {
+	parts := strings.Split(s, " ")
+	for i, p := range parts {
+		parts[i] = redactURL(p)
+	}
+	return strings.Join(parts, " ")
+}
+
+func redactURL(s string) string {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok {
+		return s
+	}
+	host := rest
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		host = rest[:i]
+	}
+	at := strings.LastIndex(host, "@")
+	if at < 0 {
+		return s
+	}
+	user := "****"
+	if name, _, hasPass := strings.Cut(host[:at], ":"); hasPass {
+		user = name + ":****"
+	}
+	return scheme + "://" + user + rest[at:]
+}
+
+func indexOverridePath(venvPath string) string {
+	return filepath.Join(venvPath, ".venv-manager", "index.json")
+}
+
+func readIndexOverride(venvPath string) (IndexConfig, error) {
+	var c IndexConfig
+	b, err := os.ReadFile(indexOverridePath(venvPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("invalid index override %s: %v", indexOverridePath(venvPath), err)
+	}
+	return c, nil
+}
+
+// IndexConfig returns the global index settings.
+func (m *Manager) IndexConfig() IndexConfig { return m.index }
+
+// VenvIndexConfig returns a venv's own index override and the effective
+// settings (global merged with the override).
+func (m *Manager) VenvIndexConfig(name string) (override, effective IndexConfig, err error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return override, effective, err
+	}
+	override, err = readIndexOverride(venvPath)
+	if err != nil {
+		return override, effective, err
+	}
+	return override, m.index.Merge(override), nil
+}
+
+// SetVenvIndexConfig stores a venv's index override. A zero override
+// removes it.
+func (m *Manager) SetVenvIndexConfig(name string, override IndexConfig) error {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return err
+	}
+	unlock, err := m.lock(name, "index set")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	p := indexOverridePath(venvPath)
+	if override.IsZero() {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(override, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, b, 0o644)
+}
+
+// toolEnv is the environment for pip/uv run against venvPath, or nil (inherit
+// ours) when no index settings apply. A broken override falls back to the
+// global settings rather than failing every pip call.
+func (m *Manager) toolEnv(venvPath string) []string {
+	cfg := m.index
+	if override, err := readIndexOverride(venvPath); err == nil {
+		cfg = cfg.Merge(override)
+	}
+	if cfg.IsZero() {
+		return nil
+	}
+	env := os.Environ()
+	for _, kv := range cfg.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		env = utils.SetEnv(env, k, v)
+	}
+	return env
+}
+
+// pipCmd builds a raw pip invocation for a venv with its index settings
+// applied, for pip-only commands (download, wheel). Package operations go
+// through the Installer, see pkg.
+func (m *Manager) pipCmd(venvPath string, args ...string) *exec.Cmd {
+	cmd := exec.Command(utils.PipPath(venvPath), args...)
+	cmd.Env = m.toolEnv(venvPath)
+	return cmd
+}
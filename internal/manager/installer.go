@@ -0,0 +1,156 @@
+package manager
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// Installer names accepted by Options.Installer.
+const (
+	InstallerPip = "pip"
+	InstallerUv  = "uv"
+)
+
+// Installer builds the package commands run against a venv. Output formats
+// match pip's: freeze lines for Freeze, pip's JSON for List and Outdated.
+type Installer interface {
+	Name() string
+	// Install takes requirement specs and install flags (-r, --no-deps,
+	// --upgrade, --no-index, --find-links).
+	Install(venvPath string, args ...string) *exec.Cmd
+	Uninstall(venvPath string, names ...string) *exec.Cmd
+	Freeze(venvPath string) *exec.Cmd
+	List(venvPath string) *exec.Cmd
+	Outdated(venvPath string) *exec.Cmd
+	Check(venvPath string) *exec.Cmd
+	CachePurge(venvPath string) *exec.Cmd
+}
+
+// pipInstaller runs the venv's own pip.
+type pipInstaller struct{}
+
+func (pipInstaller) Name() string { return InstallerPip }
+
+func (pipInstaller) cmd(venvPath string, args ...string) *exec.Cmd {
+	return exec.Command(utils.PipPath(venvPath), args...)
+}
+
+func (p pipInstaller) Install(venvPath string, args ...string) *exec.Cmd {
+	return p.cmd(venvPath, append([]string{"install"}, args...)...)
+}
+
+func (p pipInstaller) Uninstall(venvPath string, names ...string) *exec.Cmd {
+	return p.cmd(venvPath, append([]string{"uninstall", "-y"}, names...)...)
+}
+
+func (p pipInstaller) Freeze(venvPath string) *exec.Cmd { return p.cmd(venvPath, "freeze") }
+
+func (p pipInstaller) List(venvPath string) *exec.Cmd {
+	return p.cmd(venvPath, "list", "--format=json")
+}
+
+func (p pipInstaller) Outdated(venvPath string) *exec.Cmd {
+	return p.cmd(venvPath, "list", "--outdated", "--format=json")
+}
+
+func (p pipInstaller) Check(venvPath string) *exec.Cmd { return p.cmd(venvPath, "check") }
+
+func (p pipInstaller) CachePurge(venvPath string) *exec.Cmd {
+	return p.cmd(venvPath, "cache", "purge")
+}
+
+// uvInstaller runs `uv pip` pointed at the venv's interpreter, so it works
+// on venvs without pip (uv venv does not install it).
+type uvInstaller struct{}
+
+func (uvInstaller) Name() string { return InstallerUv }
+
+func (uvInstaller) cmd(venvPath, sub string, args ...string) *exec.Cmd {
+	argv := append([]string{"pip", sub, "--python", utils.PythonPath(venvPath)}, args...)
+	return exec.Command("uv", argv...)
+}
+
+func (u uvInstaller) Install(venvPath string, args ...string) *exec.Cmd {
+	return u.cmd(venvPath, "install", args...)
+}
+
+func (u uvInstaller) Uninstall(venvPath string, names ...string) *exec.Cmd {
+	return u.cmd(venvPath, "uninstall", names...)
+}
+
+func (u uvInstaller) Freeze(venvPath string) *exec.Cmd { return u.cmd(venvPath, "freeze") }
+
+func (u uvInstaller) List(venvPath string) *exec.Cmd {
+	return u.cmd(venvPath, "list", "--format=json")
+}
+
+func (u uvInstaller) Outdated(venvPath string) *exec.Cmd {
+	return u.cmd(venvPath, "list", "--outdated", "--format=json")
+}
+
+func (u uvInstaller) Check(venvPath string) *exec.Cmd { return u.cmd(venvPath, "check") }
+
+// CachePurge clears uv's cache; it is shared by every venv, like pip's.
+func (uvInstaller) CachePurge(string) *exec.Cmd { return exec.Command("uv", "cache", "clean") }
+
+// ValidateInstaller rejects unknown Options.Installer values.
+func ValidateInstaller(name string) error {
+	switch name {
+	case "", InstallerPip, InstallerUv:
+		return nil
+	}
+	return fmt.Errorf("unknown installer %q (want %q or %q)", name, InstallerPip, InstallerUv)
+}
+
+// newInstaller resolves Options.Installer. Empty picks uv when useUv is set.
+// uv falls back to pip when the binary is missing, like Create does, and so
+// does an unknown name (see ValidateInstaller).
+func newInstaller(name string, useUv bool) Installer {
+	if name == InstallerUv && uvAvailable() || name == "" && useUv {
+		return uvInstaller{}
+	}
+	return pipInstaller{}
+}
+
+// Installer returns the configured installer backend.
+func (m *Manager) Installer() Installer { return m.installer }
+
+// installerFor picks the backend for one venv: the configured one, except
+// that a venv without pip is driven through uv when uv is available.
+func (m *Manager) installerFor(venvPath string) Installer {
+	if _, ok := m.installer.(pipInstaller); ok && !fileExists(utils.PipPath(venvPath)) && uvAvailable() {
+		return uvInstaller{}
+	}
+	return m.installer
+}
+
+// pkgTool is the installer for one venv, with its index settings applied to
+// every command.
+type pkgTool struct {
+	Installer
+	venvPath string
+	env      []string
+}
+
+func (m *Manager) pkg(venvPath string) pkgTool {
+	return pkgTool{Installer: m.installerFor(venvPath), venvPath: venvPath, env: m.toolEnv(venvPath)}
+}
+
+func (t pkgTool) with(cmd *exec.Cmd) *exec.Cmd {
+	cmd.Env = t.env
+	return cmd
+}
+
+func (t pkgTool) install(args ...string) *exec.Cmd {
+	return t.with(t.Install(t.venvPath, args...))
+}
+func (t pkgTool) uninstall(names ...string) *exec.Cmd {
+	return t.with(t.Uninstall(t.venvPath, names...))
+}
+func (t pkgTool) freeze() *exec.Cmd     { return t.with(t.Freeze(t.venvPath)) }
+func (t pkgTool) list() *exec.Cmd       { return t.with(t.List(t.venvPath)) }
+func (t pkgTool) outdated() *exec.Cmd   { return t.with(t.Outdated(t.venvPath)) }
+func (t pkgTool) check() *exec.Cmd      { return t.with(t.Check(t.venvPath)) }
+func (t pkgTool) cachePurge() *exec.Cmd { return t.with(t.CachePurge(t.venvPath)) }
@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+func TestInstallerArgs(t *testing.T) {
+	venv := filepath.Join("base", "a")
+	py := utils.PythonPath(venv)
+	cases := []struct {
+		got  []string
+		want []string
+	}{
+		{pipInstaller{}.Install(venv, "-r", "req.txt").Args[1:], []string{"install", "-r", "req.txt"}},
+		{pipInstaller{}.Uninstall(venv, "x", "y").Args[1:], []string{"uninstall", "-y", "x", "y"}},
+		{pipInstaller{}.Outdated(venv).Args[1:], []string{"list", "--outdated", "--format=json"}},
+		{uvInstaller{}.Install(venv, "--no-deps", "x==1").Args, []string{"uv", "pip", "install", "--python", py, "--no-deps", "x==1"}},
+		{uvInstaller{}.Uninstall(venv, "x").Args, []string{"uv", "pip", "uninstall", "--python", py, "x"}},
+		{uvInstaller{}.Freeze(venv).Args, []string{"uv", "pip", "freeze", "--python", py}},
+		{uvInstaller{}.List(venv).Args, []string{"uv", "pip", "list", "--python", py, "--format=json"}},
+		{uvInstaller{}.Check(venv).Args, []string{"uv", "pip", "check", "--python", py}},
+	}
+	for _, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("args=%v, want %v", c.got, c.want)
+		}
+	}
+	if err := ValidateInstaller("conda"); err == nil {
+		t.Error("unknown installer accepted")
+	}
+	if newInstaller("", false).Name() != InstallerPip {
+		t.Error("default installer should be pip")
+	}
+}
+
+// fakeUv puts a uv on PATH that logs its argv and answers `pip freeze`.
+func fakeUv(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for uv")
+	}
+	bin := t.TempDir()
+	log := filepath.Join(bin, "uv.log")
+	script := "#!/bin/sh\necho \"$@\" >> " + log + "\nif [ \"$2\" = freeze ]; then echo alpha==1.0; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "uv"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestVenvWithoutPipUsesUv(t *testing.T) {
+	log := fakeUv(t)
+	m, dir := newTestMgr(t)
+	os.MkdirAll(filepath.Join(dir, "a", "bin"), 0o755)
+
+	snap, err := m.CreateSnapshot("a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.PackageCount != 1 {
+		t.Fatalf("freeze via uv: %+v", snap)
+	}
+	if out, err := m.runInstall(filepath.Join(dir, "a"), "beta==2.0"); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	b, _ := os.ReadFile(log)
+	calls := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(calls) != 2 || !strings.HasPrefix(calls[0], "pip freeze --python ") || !strings.HasPrefix(calls[1], "pip install --python ") {
+		t.Fatalf("uv calls=%q", calls)
+	}
+
+	// A venv that has pip keeps using it under the default installer.
+	os.WriteFile(utils.PipPath(filepath.Join(dir, "a")), nil, 0o755)
+	if name := m.pkg(filepath.Join(dir, "a")).Name(); name != InstallerPip {
+		t.Fatalf("installer=%s", name)
+	}
+}
@@ -58,6 +58,9 @@ func TestIntegration_InstallAndDescribe(t *testing.T) {
 	if desc.PackageCount == 0 {
 		t.Fatalf("expected packages, got %+v", desc)
 	}
+	if c := desc.Interpreter; c == nil || c.Source != PythonSourceDefault || c.Version != desc.PythonVersion {
+		t.Fatalf("expected the default interpreter choice to be recorded, got %+v", c)
+	}
 	found := false
 	for _, p := range desc.Packages {
 		if strings.HasPrefix(strings.ToLower(p), "wheel==") {
@@ -0,0 +1,356 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// Mutating operations take advisory file locks so that the CLI, watch and
+// the MCP server never change the same venv at once. Each venv has its own
+// lock file under <baseDir>/.venv-manager/locks; create, rename and remove
+// also take the base lock while they change baseDir itself. Venv locks are
+// always taken before the base lock, and several venv locks in name order,
+// so two processes never end up waiting on each other.
+//
+// A file lock only excludes other processes, so each key also has an
+// in-process semaphore: goroutines sharing a Manager (the MCP server, bulk
+// operations) wait on each other like separate processes do. Locks are not
+// reentrant. A public method takes its locks once, on entry, and calls
+// *Locked helpers that expect them held.
+
+// DefaultLockTimeout is how long an operation waits for a lock held by
+// another process before giving up.
+const DefaultLockTimeout = 30 * time.Second
+
+const lockPollInterval = 100 * time.Millisecond
+
+// baseLockKey names the base lock's file. Venv names never start with a dot.
+const baseLockKey = ".base"
+
+// LockHolder says who holds a lock. The holder writes it into the lock file
+// once the lock is taken and clears it on release.
+type LockHolder struct {
+	PID       int       `json:"pid"`
+	Operation string    `json:"operation"`
+	Since     time.Time `json:"since"`
+}
+
+// LockInfo is one lock as reported by Locks.
+type LockInfo struct {
+	// Venv is the venv the lock guards; empty for the base lock.
+	Venv string `json:"venv,omitempty"`
+	Path string `json:"path"`
+	Held bool   `json:"held"`
+	// Holder is set when the lock is held.
+	Holder *LockHolder `json:"holder,omitempty"`
+}
+
+// keyLock is the state of one lock key within a Manager.
+type keyLock struct {
+	// sem admits one caller of the Manager at a time.
+	sem chan struct{}
+	// f is the locked file while the lock is held.
+	f *os.File
+	// drop deletes the lock file on release, see dropLock.
+	drop bool
+}
+
+// lockSet tracks the locks of a Manager.
+type lockSet struct {
+	mu   sync.Mutex
+	keys map[string]*keyLock
+}
+
+// key returns the state of a lock key, creating it on first use.
+func (s *lockSet) key(key string) *keyLock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil {
+		s.keys = map[string]*keyLock{}
+	}
+	k := s.keys[key]
+	if k == nil {
+		k = &keyLock{sem: make(chan struct{}, 1)}
+		s.keys[key] = k
+	}
+	return k
+}
+
+// SetLockTimeout changes how long operations wait for a lock. Zero restores
+// DefaultLockTimeout; a negative timeout fails at once when a lock is held.
+func (m *Manager) SetLockTimeout(d time.Duration) {
+	if d == 0 {
+		d = DefaultLockTimeout
+	}
+	m.lockTimeout = d
+}
+
+func (m *Manager) locksDir() string {
+	return filepath.Join(m.baseDir, ".venv-manager", "locks")
+}
+
+func (m *Manager) lockPath(key string) string {
+	return filepath.Join(m.locksDir(), key+".lock")
+}
+
+// LockVenv takes the lock of a venv for operation and returns the function
+// that releases it. Exported for callers outside the package (MCP layer)
+// that mutate a venv without going through a Manager method.
+func (m *Manager) LockVenv(name, operation string) (func(), error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	return m.lock(name, operation)
+}
+
+// lockVenvs takes the locks of several venvs, in name order.
+func (m *Manager) lockVenvs(names []string, operation string) (func(), error) {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	var unlocks []func()
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for i, n := range names {
+		if i > 0 && n == names[i-1] {
+			continue
+		}
+		unlock, err := m.lock(n, operation)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
+
+// lockBase takes the base lock. Callers take it after any venv locks.
+func (m *Manager) lockBase(operation string) (func(), error) {
+	return m.lock(baseLockKey, operation)
+}
+
+// lock takes the lock for key, waiting up to the lock timeout while another
+// caller of this Manager or another process holds it.
+func (m *Manager) lock(key, operation string) (func(), error) {
+	k := m.locks.key(key)
+	path := m.lockPath(key)
+	deadline := time.Now().Add(m.lockTimeout)
+	if !acquire(k.sem, deadline) {
+		return nil, lockBusyError(key, readLockHolder(path), m.lockTimeout)
+	}
+	f, err := m.lockFile(key, path, deadline)
+	if err != nil {
+		<-k.sem
+		return nil, err
+	}
+	// The holder record is informational; a failed write does not matter.
+	if b, err := json.Marshal(LockHolder{PID: os.Getpid(), Operation: operation, Since: time.Now()}); err == nil {
+		f.Truncate(0)
+		f.WriteAt(b, 0)
+	}
+	m.locks.mu.Lock()
+	k.f = f
+	m.locks.mu.Unlock()
+	return func() { m.unlock(key) }, nil
+}
+
+// acquire takes sem, waiting until deadline at most.
+func acquire(sem chan struct{}, deadline time.Time) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return false
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// lockFile takes the file lock at path, polling until deadline while
+// another process holds it.
+func (m *Manager) lockFile(key, path string, deadline time.Time) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %v", err)
+	}
+	var f *os.File
+	for {
+		var err error
+		f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lock file: %v", err)
+		}
+		ok, err := utils.TryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %v", lockSubject(key), err)
+		}
+		if ok {
+			if isLockFile(f, path) {
+				break
+			}
+			// The holder deleted the file (see dropLock); lock the new one.
+			utils.UnlockFile(f)
+			f.Close()
+			continue
+		}
+		f.Close()
+		if !time.Now().Before(deadline) {
+			return nil, lockBusyError(key, readLockHolder(path), m.lockTimeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+	return f, nil
+}
+
+func (m *Manager) unlock(key string) {
+	k := m.locks.key(key)
+	m.locks.mu.Lock()
+	f, drop := k.f, k.drop
+	k.f, k.drop = nil, false
+	m.locks.mu.Unlock()
+	if f == nil {
+		return
+	}
+	// Unix unlinks the file while still holding it. Windows cannot delete
+	// an open file, and refuses to while any waiter has it open.
+	removed := drop && os.Remove(m.lockPath(key)) == nil
+	f.Truncate(0)
+	utils.UnlockFile(f)
+	f.Close()
+	if drop && !removed && runtime.GOOS == "windows" {
+		os.Remove(m.lockPath(key))
+	}
+	<-k.sem
+}
+
+// dropLock deletes the lock file of key when the caller's lock is released,
+// once the venv it guards is gone. It does nothing unless the lock is held.
+func (m *Manager) dropLock(key string) {
+	k := m.locks.key(key)
+	m.locks.mu.Lock()
+	defer m.locks.mu.Unlock()
+	if k.f != nil {
+		k.drop = true
+	}
+}
+
+// isLockFile reports whether f, just locked, is still the file at path. A
+// process that was waiting on a file its holder then deleted must not count
+// the lock as taken.
+func isLockFile(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	cur, err := os.Stat(path)
+	return err == nil && os.SameFile(fi, cur)
+}
+
+func lockSubject(key string) string {
+	if key == baseLockKey {
+		return "the base directory"
+	}
+	return fmt.Sprintf("venv '%s'", key)
+}
+
+func lockBusyError(key string, h *LockHolder, timeout time.Duration) error {
+	waited := ""
+	if timeout > 0 {
+		waited = fmt.Sprintf("; gave up after %s", timeout)
+	}
+	if h == nil {
+		return fmt.Errorf("%s is locked by another process%s", lockSubject(key), waited)
+	}
+	return fmt.Errorf("%s is locked by pid %d (%s since %s)%s", lockSubject(key), h.PID, h.Operation, h.Since.Format("15:04:05"), waited)
+}
+
+// readLockHolder reads the holder record of a lock file, or nil when there
+// is none (the lock is free, or its holder has not written it yet).
+func readLockHolder(path string) *LockHolder {
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	var h LockHolder
+	if json.Unmarshal(b, &h) != nil {
+		return nil
+	}
+	return &h
+}
+
+// Locks reports every lock file under baseDir, the base lock first, and
+// whether and by whom it is held. Probing a lock takes it for an instant.
+func (m *Manager) Locks() ([]LockInfo, error) {
+	entries, err := os.ReadDir(m.locksDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list locks: %v", err)
+	}
+	var out []LockInfo
+	for _, e := range entries {
+		key, ok := strings.CutSuffix(e.Name(), ".lock")
+		if !ok || e.IsDir() {
+			continue
+		}
+		info := LockInfo{Path: filepath.Join(m.locksDir(), e.Name())}
+		if key != baseLockKey {
+			info.Venv = key
+		}
+		info.Held = m.lockHeld(key, info.Path)
+		if info.Held {
+			info.Holder = readLockHolder(info.Path)
+		}
+		out = append(out, info)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Venv == "" && out[j].Venv != ""
+	})
+	return out, nil
+}
+
+// lockHeld reports whether anyone, this Manager included, holds a lock.
+func (m *Manager) lockHeld(key, path string) bool {
+	k := m.locks.key(key)
+	m.locks.mu.Lock()
+	mine := k.f != nil
+	m.locks.mu.Unlock()
+	if mine {
+		return true
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	ok, err := utils.TryLockFile(f)
+	if err != nil {
+		return false
+	}
+	if ok {
+		utils.UnlockFile(f)
+	}
+	return !ok
+}
@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockExcludesGoroutinesSharingAManager(t *testing.T) {
+	m, _ := newTestMgr(t)
+	unlock, err := m.LockVenv("app", "install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locks, err := m.Locks()
+	if err != nil || len(locks) != 1 {
+		t.Fatalf("Locks: %+v %v", locks, err)
+	}
+	l := locks[0]
+	if l.Venv != "app" || !l.Held || l.Holder == nil || l.Holder.PID != os.Getpid() || l.Holder.Operation != "install" {
+		t.Fatalf("lock = %+v, holder %+v", l, l.Holder)
+	}
+
+	// Not reentrant: a second caller of the same Manager waits, then gives
+	// up, even though this process already holds the file lock.
+	m.SetLockTimeout(100 * time.Millisecond)
+	if _, err := m.LockVenv("app", "snapshot"); err == nil || !strings.Contains(err.Error(), "(install since") {
+		t.Fatalf("second lock through the same Manager: %v", err)
+	}
+
+	m.SetLockTimeout(5 * time.Second)
+	got := make(chan error, 1)
+	go func() {
+		unlock2, err := m.LockVenv("app", "snapshot")
+		if err == nil {
+			unlock2()
+		}
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		t.Fatalf("goroutine took a held lock: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	if err := <-got; err != nil {
+		t.Fatalf("waiting for release: %v", err)
+	}
+	if locks, _ := m.Locks(); locks[0].Held || locks[0].Holder != nil {
+		t.Fatalf("lock still held after release: %+v", locks[0])
+	}
+}
+
+func TestLockContention(t *testing.T) {
+	holder, dir := newTestMgr(t)
+	// A second Manager opens its own lock files, like another process.
+	waiter := NewWithOptions(Options{BaseDir: dir, LockTimeout: 200 * time.Millisecond})
+	unlock, err := holder.LockVenv("app", "upgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = waiter.LockVenv("app", "install")
+	if err == nil {
+		t.Fatal("lock held elsewhere was taken")
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Fatalf("gave up after %s, before the timeout", time.Since(start))
+	}
+	want := fmt.Sprintf("pid %d (upgrade since", os.Getpid())
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("error %q does not name the holder", err)
+	}
+	if _, err := waiter.LockVenv("other", "install"); err != nil {
+		t.Fatalf("other venvs must stay unlocked: %v", err)
+	}
+
+	waiter.SetLockTimeout(5 * time.Second)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		unlock()
+	}()
+	unlock2, err := waiter.LockVenv("app", "install")
+	if err != nil {
+		t.Fatalf("waiting for release: %v", err)
+	}
+	unlock2()
+}
+
+func TestRemoveWaitsForLock(t *testing.T) {
+	holder, dir := newTestMgr(t)
+	if err := os.MkdirAll(holder.VenvPath("app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := holder.LockVenv("app", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	other := NewWithOptions(Options{BaseDir: dir, LockTimeout: -1})
+	if err := other.Remove("app"); err == nil || !strings.Contains(err.Error(), "locked by pid") {
+		t.Fatalf("Remove of a locked venv: %v", err)
+	}
+	if !fileExists(holder.VenvPath("app")) {
+		t.Fatal("locked venv was removed")
+	}
+	unlock()
+	if err := other.Remove("app"); err != nil {
+		t.Fatalf("Remove after release: %v", err)
+	}
+	// The removed venv's lock file goes with it.
+	if locks, _ := other.Locks(); len(locks) != 1 || locks[0].Venv != "" || locks[0].Held {
+		t.Fatalf("want only the free base lock, got %+v", locks)
+	}
+}
+
+func TestDroppedLockFileIsNotShared(t *testing.T) {
+	holder, dir := newTestMgr(t)
+	waiter := NewWithOptions(Options{BaseDir: dir, LockTimeout: 5 * time.Second})
+	unlock, err := holder.LockVenv("app", "remove")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		holder.dropLock("app")
+		unlock()
+	}()
+	// The waiter opened the file before it was deleted; it must end up
+	// holding the lock on a file a third process would find.
+	unlock2, err := waiter.LockVenv("app", "install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock2()
+	third := NewWithOptions(Options{BaseDir: dir, LockTimeout: -1})
+	if _, err := third.LockVenv("app", "install"); err == nil {
+		t.Fatal("lock taken twice after its file was deleted")
+	}
+}
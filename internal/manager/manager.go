@@ -2,13 +2,13 @@ package manager
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -23,6 +23,17 @@ type Manager struct {
 	useUv         bool
 	fs            utils.FileSystem
 	global        bool
+	autoSnapshot  bool
+	retention     RetentionPolicy
+	jobs          int
+	wheelhouse    string
+	offline       bool
+	index         IndexConfig
+	installer     Installer
+	pythonDirs    []string
+	lockTimeout   time.Duration
+	locks         lockSet
+	staging       stagingUsers
 }
 
 // Options configures Manager construction.
@@ -30,6 +41,29 @@ type Options struct {
 	BaseDir       string
 	DefaultPython string
 	UseUv         bool
+	// AutoSnapshot snapshots a venv before every mutating operation.
+	AutoSnapshot bool
+	// Retention is applied after each automatic snapshot.
+	Retention RetentionPolicy
+	// Jobs bounds how many venvs --global operations process at once.
+	// Zero means one per CPU.
+	Jobs int
+	// Wheelhouse is the local package directory offline installs resolve
+	// from. Defaults to <BaseDir>/.venv-manager/wheelhouse.
+	Wheelhouse string
+	// Offline restricts installs to Wheelhouse (pip --no-index).
+	Offline bool
+	// Index applies to every pip and uv call; venvs may override it.
+	Index IndexConfig
+	// Installer is "pip" or "uv" (uv pip). Empty means uv when UseUv is set
+	// and uv is available, else pip.
+	Installer string
+	// PythonDirs are extra directories searched for interpreters.
+	PythonDirs []string
+	// LockTimeout bounds how long an operation waits for a venv locked by
+	// another process. Zero means DefaultLockTimeout; negative means fail
+	// at once.
+	LockTimeout time.Duration
 }
 
 // New constructs a Manager. Empty BaseDir defaults to ~/.venvs.
@@ -46,12 +80,26 @@ func NewWithOptions(opts Options) *Manager {
 		}
 		opts.BaseDir = filepath.Join(homeDir, ".venvs")
 	}
-	return &Manager{
+	if opts.Wheelhouse == "" {
+		opts.Wheelhouse = defaultWheelhouse(opts.BaseDir)
+	}
+	useUv := opts.UseUv && uvAvailable()
+	m := &Manager{
 		baseDir:       opts.BaseDir,
 		defaultPython: opts.DefaultPython,
-		useUv:         opts.UseUv && uvAvailable(),
+		useUv:         useUv,
 		fs:            utils.NewFileSystem(),
-	}
+		autoSnapshot:  opts.AutoSnapshot,
+		retention:     opts.Retention,
+		jobs:          opts.Jobs,
+		wheelhouse:    opts.Wheelhouse,
+		offline:       opts.Offline,
+		index:         opts.Index,
+		installer:     newInstaller(opts.Installer, useUv),
+		pythonDirs:    opts.PythonDirs,
+	}
+	m.SetLockTimeout(opts.LockTimeout)
+	return m
 }
 
 // SetFileSystem swaps the filesystem implementation (used in tests).
@@ -124,6 +172,25 @@ func (m *Manager) resolveTargets(name string) ([]string, error) {
 
 // Create makes a new venv. Uses uv when enabled+available, else python -m venv.
 func (m *Manager) Create(name, pythonVersion string) error {
+	return m.CreateWithOptions(name, CreateOptions{Python: pythonVersion})
+}
+
+// CreateOptions configures CreateWithOptions.
+type CreateOptions struct {
+	// Python is the interpreter request, see ResolvePython. Empty falls
+	// back to default_python, then to the system python.
+	Python string
+	// PythonSource says where Python came from (PythonSource*); empty
+	// means the --python flag.
+	PythonSource string
+	// PythonSourcePath is the project file Python was read from, if any.
+	PythonSourcePath string
+}
+
+// CreateWithOptions creates a venv and records the interpreter choice in
+// its metadata, see PythonChoice. The venv is built in a staging directory
+// and appears in baseDir only once complete.
+func (m *Manager) CreateWithOptions(name string, opts CreateOptions) error {
 	if err := ValidateName(name); err != nil {
 		return err
 	}
@@ -134,24 +201,64 @@ func (m *Manager) Create(name, pythonVersion string) error {
 	if err := m.fs.CreateDir(m.baseDir); err != nil {
 		return fmt.Errorf("failed to create base directory: %v", err)
 	}
+	return m.stageVenv(name, "create", opts, nil)
+}
 
-	if pythonVersion == "" {
-		pythonVersion = m.defaultPython
+// createVenv builds a venv at venvPath, which need not be under baseDir.
+func (m *Manager) createVenv(venvPath string, opts CreateOptions) error {
+	choice := &PythonChoice{Requested: opts.Python, Source: opts.PythonSource, SourcePath: opts.PythonSourcePath}
+	switch {
+	case choice.Requested == "" && m.defaultPython != "":
+		choice.Requested, choice.Source, choice.SourcePath = m.defaultPython, PythonSourceConfig, ""
+	case choice.Requested == "":
+		choice.Source = PythonSourceDefault
+	case choice.Source == "":
+		choice.Source = PythonSourceFlag
+	}
+	var it *Interpreter
+	if choice.Requested != "" {
+		var err error
+		// Under uv a miss is not fatal: uv venv resolves the request
+		// itself and may find (or download) what discovery did not.
+		if it, err = m.ResolvePython(choice.Requested); err != nil && !m.useUv {
+			return err
+		}
 	}
 
 	var cmd *exec.Cmd
 	if m.useUv {
 		args := []string{"venv", venvPath}
-		if pythonVersion != "" {
-			args = append(args, "--python", pythonVersion)
+		if it != nil {
+			args = append(args, "--python", it.Path)
+		} else if choice.Requested != "" {
+			args = append(args, "--python", choice.Requested)
+		}
+		if m.offline {
+			// Never download a managed interpreter.
+			args = append(args, "--offline")
 		}
 		cmd = exec.Command("uv", args...)
+		cmd.Env = m.toolEnv(venvPath)
 	} else {
-		cmd = exec.Command(utils.DefaultPythonCmd(pythonVersion), "-m", "venv", venvPath)
+		python := utils.DefaultPythonCmd("")
+		if it != nil {
+			python = it.Path
+		}
+		cmd = exec.Command(python, "-m", "venv", venvPath)
 	}
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to create venv: %v\n%s", err, output)
 	}
+
+	if it != nil {
+		choice.Interpreter, choice.Version, choice.Implementation = it.Path, it.Version, it.Implementation
+	}
+	if choice.Version == "" {
+		choice.Version = venvPythonVersion(venvPath)
+	}
+	if err := writePythonChoice(venvPath, choice); err != nil {
+		return fmt.Errorf("failed to record python choice: %v", err)
+	}
 	return nil
 }
 
@@ -166,7 +273,8 @@ func (m *Manager) List() ([]string, error) {
 	}
 	var venvs []string
 	for _, entry := range entries {
-		if entry.IsDir() {
+		// Skips manager state such as .venv-manager/.
+		if entry.IsDir() && ValidateName(entry.Name()) == nil {
 			venvs = append(venvs, entry.Name())
 		}
 	}
@@ -180,7 +288,24 @@ func (m *Manager) Remove(name string) error {
 	if err != nil {
 		return err
 	}
-	return m.fs.RemoveAll(p)
+	unlock, err := m.lock(name, "remove")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	unlockBase, err := m.lockBase("remove")
+	if err != nil {
+		return err
+	}
+	defer unlockBase()
+	if err := m.fs.RemoveAll(m.repythonBackupPath(name)); err != nil {
+		return err
+	}
+	if err := m.fs.RemoveAll(p); err != nil {
+		return err
+	}
+	m.dropLock(name)
+	return nil
 }
 
 // Rename moves a venv to a new name.
@@ -192,22 +317,127 @@ func (m *Manager) Rename(oldName, newName string) error {
 	if err := ValidateName(newName); err != nil {
 		return err
 	}
+	unlock, err := m.lockVenvs([]string{oldName, newName}, "rename")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	unlockBase, err := m.lockBase("rename")
+	if err != nil {
+		return err
+	}
+	defer unlockBase()
 	dst := m.VenvPath(newName)
 	if m.fs.Exists(dst) {
 		return fmt.Errorf("target venv '%s' already exists", newName)
 	}
+	if fileExists(m.repythonBackupPath(oldName)) {
+		return fmt.Errorf("'%s' has a repython backup pending; confirm or revert it before renaming", oldName)
+	}
 	if err := os.Rename(src, dst); err != nil {
 		return fmt.Errorf("failed to rename venv: %v", err)
 	}
-	// NOTE: internal absolute paths in the venv activate scripts still reference
-	// the old path. `python -m venv` bakes them in. Fix by re-creating scripts
-	// via `python -m venv --upgrade`.
-	if out, err := exec.Command(utils.DefaultPythonCmd(""), "-m", "venv", "--upgrade", dst).CombinedOutput(); err != nil {
-		return fmt.Errorf("renamed but activation scripts may be broken: %v\n%s", err, out)
+	if _, err := relocateVenv(dst, src); err != nil {
+		if uerr := undoMove(src, dst); uerr != nil {
+			return fmt.Errorf("failed to relocate venv: %v\nrestoring '%s' also failed: %v", err, oldName, uerr)
+		}
+		return fmt.Errorf("failed to relocate venv, '%s' left as it was: %v", oldName, err)
 	}
+	m.dropLock(oldName)
 	return nil
 }
 
+// Move moves a venv into another base directory under the same name and
+// rewrites the paths it records, see relocateVenv. Across filesystems the
+// venv is copied into the target's staging directory, relocated, renamed
+// into place, and only then removed from baseDir.
+func (m *Manager) Move(name, dir string) (string, error) {
+	src, err := m.requireVenv(name)
+	if err != nil {
+		return "", err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return "", err
+	}
+	if base, err := filepath.Abs(m.baseDir); err == nil && base == dir {
+		return "", fmt.Errorf("'%s' is already in %s", name, dir)
+	}
+	if fileExists(m.repythonBackupPath(name)) {
+		return "", fmt.Errorf("'%s' has a repython backup pending; confirm or revert it before moving", name)
+	}
+	target := NewWithOptions(Options{BaseDir: dir, LockTimeout: m.lockTimeout})
+	unlock, err := m.lock(name, "move")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	unlockTarget, err := target.lock(name, "move")
+	if err != nil {
+		return "", err
+	}
+	defer unlockTarget()
+	unlockBase, err := m.lockBase("move")
+	if err != nil {
+		return "", err
+	}
+	defer unlockBase()
+	unlockTargetBase, err := target.lockBase("move")
+	if err != nil {
+		return "", err
+	}
+	defer unlockTargetBase()
+
+	dst := target.VenvPath(name)
+	if fileExists(dst) {
+		return "", fmt.Errorf("'%s' already exists in %s", name, dir)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		if _, err := relocateVenv(dst, src); err != nil {
+			if uerr := undoMove(src, dst); uerr != nil {
+				return "", fmt.Errorf("failed to relocate venv: %v\nrestoring '%s' also failed: %v", err, name, uerr)
+			}
+			return "", fmt.Errorf("failed to relocate venv, '%s' left as it was: %v", name, err)
+		}
+		m.dropLock(name)
+		return dst, nil
+	}
+	// Most likely another filesystem: copy instead.
+	staging := target.stagingPath(name)
+	if err := target.holdStaging(); err != nil {
+		return "", err
+	}
+	defer target.releaseStaging()
+	if err := utils.CopyDir(src, staging); err != nil {
+		os.RemoveAll(staging)
+		return "", fmt.Errorf("failed to copy venv: %v", err)
+	}
+	if err := os.Rename(staging, dst); err != nil {
+		os.RemoveAll(staging)
+		return "", fmt.Errorf("failed to move venv into place: %v", err)
+	}
+	if _, err := relocateVenv(dst, src); err != nil {
+		if rerr := os.RemoveAll(dst); rerr != nil {
+			return "", fmt.Errorf("failed to relocate venv, '%s' left as it was: %v\nremoving the copy at %s also failed: %v", name, err, dst, rerr)
+		}
+		return "", fmt.Errorf("failed to relocate venv, '%s' left as it was: %v", name, err)
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return dst, fmt.Errorf("moved to %s but failed to remove the original: %v", dst, err)
+	}
+	m.dropLock(name)
+	return dst, nil
+}
+
+// undoMove puts a venv renamed from src to dst back after its relocation
+// failed, and rewrites the paths relocateVenv already changed.
+func undoMove(src, dst string) error {
+	if err := os.Rename(dst, src); err != nil {
+		return err
+	}
+	_, err := relocateVenv(src, dst)
+	return err
+}
+
 // Install runs pip install -r on a venv.
 func (m *Manager) Install(name, requirementsPath string) error {
 	venvPath, err := m.requireVenv(name)
@@ -217,8 +447,15 @@ func (m *Manager) Install(name, requirementsPath string) error {
 	if !m.fs.Exists(requirementsPath) {
 		return fmt.Errorf("requirements file '%s' not found", requirementsPath)
 	}
-	cmd := exec.Command(utils.PipPath(venvPath), "install", "-r", requirementsPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	unlock, err := m.lock(name, "install")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := m.autoSnapshotLocked(venvPath, name, "install"); err != nil {
+		return err
+	}
+	if output, err := m.runInstall(venvPath, "-r", requirementsPath); err != nil {
 		return fmt.Errorf("failed to install requirements: %v\n%s", err, output)
 	}
 	return nil
@@ -233,100 +470,173 @@ func (m *Manager) Clone(source, target string) error {
 	if m.fs.Exists(m.VenvPath(target)) {
 		return fmt.Errorf("target venv '%s' already exists", target)
 	}
-	if err := m.Create(target, ""); err != nil {
+	if err := m.checkOffline(); err != nil {
 		return err
 	}
-	targetPath := m.VenvPath(target)
-
-	requirements, err := exec.Command(utils.PipPath(sourcePath), "freeze").Output()
+	requirements, err := m.pkg(sourcePath).freeze().Output()
 	if err != nil {
 		return fmt.Errorf("failed to get requirements: %v", err)
 	}
-
-	pipPath := utils.PipPath(targetPath)
-	if runtime.GOOS == "windows" {
-		tmp, err := os.CreateTemp("", "venv-clone-req-*.txt")
-		if err != nil {
+	if err := m.fs.CreateDir(m.baseDir); err != nil {
+		return fmt.Errorf("failed to create base directory: %v", err)
+	}
+	return m.stageVenv(target, "clone", CreateOptions{}, func(targetPath string) error {
+		// Snapshot the fresh target so a bad clone can be rolled back to empty.
+		if _, err := m.autoSnapshotAt(targetPath, target, "clone"); err != nil {
 			return err
 		}
-		defer os.Remove(tmp.Name())
-		if _, err := tmp.Write(requirements); err != nil {
+		if runtime.GOOS == "windows" {
+			tmp, err := os.CreateTemp("", "venv-clone-req-*.txt")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(tmp.Name())
+			if _, err := tmp.Write(requirements); err != nil {
+				tmp.Close()
+				return err
+			}
 			tmp.Close()
+			if output, err := m.runInstall(targetPath, "-r", tmp.Name()); err != nil {
+				return fmt.Errorf("failed to install requirements: %v\n%s", err, output)
+			}
+			return nil
+		}
+		cmd, err := m.InstallCommand(targetPath, "-r", "/dev/stdin")
+		if err != nil {
 			return err
 		}
-		tmp.Close()
-		if output, err := exec.Command(pipPath, "install", "-r", tmp.Name()).CombinedOutput(); err != nil {
+		cmd.Stdin = bytes.NewReader(requirements)
+		if output, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("failed to install requirements: %v\n%s", err, output)
 		}
 		return nil
+	})
+}
+
+// CleanOptions configures CleanWithOptions.
+type CleanOptions struct {
+	// Slim also strips installed packages of test, doc and example dirs,
+	// stale bytecode and .dist-info files not needed at runtime. Valid
+	// bytecode is kept instead of wiping every __pycache__.
+	Slim bool
+	// DryRun reports what Slim would remove without deleting anything.
+	DryRun bool
+	// Keep lists packages Slim must leave untouched.
+	Keep []string
+}
+
+// Clean purges pip cache and __pycache__ dirs. With --global, venvs are
+// cleaned concurrently and every venv is attempted even if some fail.
+func (m *Manager) Clean(name string) error {
+	rep, err := m.CleanWithOptions(name, CleanOptions{})
+	if err != nil {
+		return err
 	}
-	cmd := exec.Command(pipPath, "install", "-r", "/dev/stdin")
-	cmd.Stdin = bytes.NewReader(requirements)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to install requirements: %v\n%s", err, output)
-	}
-	return nil
+	return rep.Err()
 }
 
-// Upgrade upgrades outdated packages in one or all venvs.
-func (m *Manager) Upgrade(name string) error {
+// CleanWithOptions is Clean returning a result for every venv.
+func (m *Manager) CleanWithOptions(name string, opts CleanOptions) (*BulkReport, error) {
+	if opts.DryRun && !opts.Slim {
+		return nil, fmt.Errorf("--dry-run is only supported with --slim")
+	}
 	targets, err := m.resolveTargets(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var errs []string
-	for _, venvPath := range targets {
-		pipPath := utils.PipPath(venvPath)
-		output, err := exec.Command(pipPath, "list", "--outdated", "--format=json").Output()
+	keep := nameSet(opts.Keep)
+	var purged map[string]cachePurge
+	if !opts.DryRun {
+		purged = m.purgeCaches(targets)
+	}
+	return m.forEachVenv("clean", targets, func(venvPath string, res *VenvResult) error {
+		if opts.Slim && opts.DryRun {
+			return m.slimClean(venvPath, res, true, keep)
+		}
+		unlock, err := m.lock(res.Venv, "clean")
 		if err != nil {
-			errs = append(errs, fmt.Sprintf("%s: list failed: %v", venvPath, err))
-			continue
+			return err
 		}
-		var packages []struct {
-			Name string `json:"name"`
+		defer unlock()
+		if err := purged[m.installerFor(venvPath).Name()].report(res); err != nil {
+			return err
 		}
-		if err := json.Unmarshal(output, &packages); err != nil {
-			errs = append(errs, fmt.Sprintf("%s: parse failed: %v", venvPath, err))
-			continue
+		if opts.Slim {
+			return m.slimClean(venvPath, res, false, keep)
 		}
-		for _, pkg := range packages {
-			if out, err := exec.Command(pipPath, "install", "--upgrade", pkg.Name).CombinedOutput(); err != nil {
-				errs = append(errs, fmt.Sprintf("%s/%s: %v\n%s", filepath.Base(venvPath), pkg.Name, err, out))
-			}
+		return m.cleanVenv(venvPath, res)
+	}), nil
+}
+
+// cachePurge is the outcome of purging one installer's package cache.
+type cachePurge struct {
+	out []byte
+	err error
+}
+
+// purgeCaches purges the package cache once for each installer the targets
+// use. The cache is per user and shared by every venv, so purging it from
+// each venv's worker would only race.
+func (m *Manager) purgeCaches(targets []string) map[string]cachePurge {
+	purged := map[string]cachePurge{}
+	for _, venvPath := range targets {
+		t := m.pkg(venvPath)
+		if _, ok := purged[t.Name()]; ok {
+			continue
 		}
+		out, err := t.cachePurge().CombinedOutput()
+		purged[t.Name()] = cachePurge{out: out, err: err}
 	}
-	if len(errs) > 0 {
-		return fmt.Errorf("upgrade completed with errors:\n%s", strings.Join(errs, "\n"))
+	return purged
+}
+
+// report records the purge on a venv's result.
+func (p cachePurge) report(res *VenvResult) error {
+	res.OutputTail = outputTail(p.out, outputTailLines)
+	if p.err != nil {
+		return fmt.Errorf("failed to clean pip cache: %v", p.err)
 	}
 	return nil
 }
 
-// Clean purges pip cache and __pycache__ dirs.
-func (m *Manager) Clean(name string) error {
-	targets, err := m.resolveTargets(name)
+func (m *Manager) slimClean(venvPath string, res *VenvResult, dryRun bool, keep map[string]bool) error {
+	pkgs, err := slimVenv(venvPath, dryRun, keep)
 	if err != nil {
-		return err
+		return fmt.Errorf("slim failed: %v", err)
 	}
-	for _, venvPath := range targets {
-		if out, err := exec.Command(utils.PipPath(venvPath), "cache", "purge").CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to clean pip cache: %v\n%s", err, out)
+	res.Reclaimed = pkgs
+	for _, p := range pkgs {
+		res.ReclaimedBytes += p.Bytes
+		res.Packages = append(res.Packages, p.Name)
+	}
+	if dryRun {
+		res.Status = StatusSkipped
+		res.Note = "dry run: " + utils.FormatSize(res.ReclaimedBytes) + " reclaimable"
+	} else {
+		res.Note = utils.FormatSize(res.ReclaimedBytes) + " reclaimed"
+	}
+	return nil
+}
+
+func (m *Manager) cleanVenv(venvPath string, res *VenvResult) error {
+	removed := 0
+	err := filepath.Walk(venvPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
 		}
-		err := filepath.Walk(venvPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
+		if info.IsDir() && info.Name() == "__pycache__" {
+			if err := os.RemoveAll(path); err != nil {
 				return err
 			}
-			if info.IsDir() && info.Name() == "__pycache__" {
-				if err := os.RemoveAll(path); err != nil {
-					return err
-				}
-				return filepath.SkipDir
-			}
-			return nil
-		})
-		if err != nil {
-			return fmt.Errorf("failed to clean pycache: %v", err)
+			removed++
+			return filepath.SkipDir
 		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clean pycache: %v", err)
 	}
+	res.Note = fmt.Sprintf("removed %d __pycache__ dirs", removed)
 	return nil
 }
 
@@ -353,58 +663,62 @@ func (m *Manager) GetActivationCommand(name, shell string) (string, error) {
 
 // ListPackages returns "name==version" strings for installed packages.
 func (m *Manager) ListPackages(name string) ([]string, error) {
-	venvPath, err := m.requireVenv(name)
-	if err != nil {
-		return nil, err
-	}
-	output, err := exec.Command(utils.PipPath(venvPath), "list", "--format=json").Output()
+	pkgs, err := m.InstalledPackages(name)
 	if err != nil {
 		return nil, err
 	}
-	var packages []struct {
-		Name    string `json:"name"`
-		Version string `json:"version"`
-	}
-	if err := json.Unmarshal(output, &packages); err != nil {
-		return nil, err
-	}
-	result := make([]string, len(packages))
-	for i, pkg := range packages {
-		result[i] = fmt.Sprintf("%s==%s", pkg.Name, pkg.Version)
+	result := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		result[i] = pkg.Spec()
 	}
 	return result, nil
 }
 
-// GetSize returns byte sizes keyed by venv name.
-func (m *Manager) GetSize(name string) (map[string]int64, error) {
-	sizes := make(map[string]int64)
+// GetSize measures one venv, or every venv with --global, recording each
+// size in its result's SizeBytes.
+func (m *Manager) GetSize(name string) (*BulkReport, error) {
+	var targets []string
 	if name != "" {
 		p, err := m.requireVenv(name)
 		if err != nil {
 			return nil, err
 		}
-		size, err := m.fs.GetDirSize(p)
-		if err != nil {
+		targets = []string{p}
+	} else {
+		var err error
+		if targets, err = m.resolveTargets(""); err != nil {
 			return nil, err
 		}
-		sizes[name] = size
-		return sizes, nil
-	}
-	if !m.global {
-		return nil, fmt.Errorf("please specify a venv name or use --global flag")
 	}
-	venvs, err := m.List()
-	if err != nil {
-		return nil, err
-	}
-	for _, v := range venvs {
-		size, err := m.fs.GetDirSize(m.VenvPath(v))
+	// A file hardlinked across venvs (see Dedupe) is counted once, in the
+	// first venv by name that holds it, so the sizes add up to the disk
+	// actually used.
+	shared := make([]map[utils.FileID]int64, len(targets))
+	rep := m.forEachVenv("size", targets, func(venvPath string, res *VenvResult) error {
+		size, ids, err := m.fs.GetDirSize(venvPath)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		res.SizeBytes = size
+		shared[slices.Index(targets, venvPath)] = ids
+		return nil
+	})
+	// Targets are in name order.
+	seen := map[utils.FileID]bool{}
+	for i := range targets {
+		for id, size := range shared[i] {
+			if !seen[id] {
+				seen[id] = true
+				rep.Results[i].SizeBytes += size
+			}
+		}
+	}
+	for i := range rep.Results {
+		if res := &rep.Results[i]; res.Error == "" {
+			res.Note = utils.FormatSize(res.SizeBytes)
 		}
-		sizes[v] = size
 	}
-	return sizes, nil
+	return rep, nil
 }
 
 // Run executes a command inside a venv (PATH-prepended with venv bin dir).
@@ -417,6 +731,18 @@ func (m *Manager) Run(name string, argv []string) error {
 	if err != nil {
 		return err
 	}
+	return runIn(venvPath, argv)
+}
+
+// runIn runs argv with venvPath activated, see Run.
+func runIn(venvPath string, argv []string) error {
+	cmd := venvCommand(venvPath, argv)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// venvCommand builds the command for argv with venvPath activated.
+func venvCommand(venvPath string, argv []string) *exec.Cmd {
 	binDir := utils.VenvBinDir(venvPath)
 
 	// Resolve command: prefer venv-local, fall back to system PATH.
@@ -431,8 +757,6 @@ func (m *Manager) Run(name string, argv []string) error {
 	}
 
 	cmd := exec.Command(resolved, argv[1:]...)
-	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
-
 	env := os.Environ()
 	env = append(env, "VIRTUAL_ENV="+venvPath)
 	sep := string(os.PathListSeparator)
@@ -441,7 +765,7 @@ func (m *Manager) Run(name string, argv []string) error {
 	// Drop PYTHONHOME as venv activate does.
 	env = utils.RemoveEnv(env, "PYTHONHOME")
 	cmd.Env = env
-	return cmd.Run()
+	return cmd
 }
 
 // Export writes a manifest describing a venv (or all when global).
@@ -470,24 +794,38 @@ func (m *Manager) Export(name string) (*Manifest, error) {
 
 // Import creates a venv from a manifest and installs its requirements.
 func (m *Manager) Import(mf *Manifest) error {
-	if err := m.Create(mf.Name, mf.PythonVersion); err != nil {
-		return err
-	}
-	if len(mf.Requirements) == 0 {
-		return nil
+	if len(mf.Requirements) > 0 {
+		if err := m.checkOffline(); err != nil {
+			return err
+		}
 	}
-	tmp, err := os.CreateTemp("", "venv-req-*.txt")
-	if err != nil {
-		return err
+	if err := m.fs.CreateDir(m.baseDir); err != nil {
+		return fmt.Errorf("failed to create base directory: %v", err)
 	}
-	defer os.Remove(tmp.Name())
-	for _, r := range mf.Requirements {
-		if _, err := tmp.WriteString(r + "\n"); err != nil {
+	return m.stageVenv(mf.Name, "import", CreateOptions{Python: mf.PythonVersion}, func(venvPath string) error {
+		if len(mf.Requirements) == 0 {
+			return nil
+		}
+		if _, err := m.autoSnapshotAt(venvPath, mf.Name, "install"); err != nil {
 			return err
 		}
-	}
-	tmp.Close()
-	return m.Install(mf.Name, tmp.Name())
+		tmp, err := os.CreateTemp("", "venv-req-*.txt")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		for _, r := range mf.Requirements {
+			if _, err := tmp.WriteString(r + "\n"); err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+		tmp.Close()
+		if output, err := m.runInstall(venvPath, "-r", tmp.Name()); err != nil {
+			return fmt.Errorf("failed to install requirements: %v\n%s", err, output)
+		}
+		return nil
+	})
 }
 
 // StaleVenv describes a venv unused for a given duration.
@@ -496,6 +834,29 @@ type StaleVenv struct {
 	ModTime time.Time
 }
 
+// PruneStale removes venvs unused for more than days and reports each one.
+// With dryRun, stale venvs are reported as skipped and left in place.
+func (m *Manager) PruneStale(days int, dryRun bool) (*BulkReport, error) {
+	stale, err := m.FindStale(days)
+	if err != nil {
+		return nil, err
+	}
+	modTimes := map[string]time.Time{}
+	targets := make([]string, len(stale))
+	for i, s := range stale {
+		targets[i] = m.VenvPath(s.Name)
+		modTimes[s.Name] = s.ModTime
+	}
+	return m.forEachVenv("prune", targets, func(venvPath string, res *VenvResult) error {
+		res.Note = "last modified " + modTimes[res.Venv].Format("2006-01-02")
+		if dryRun {
+			res.Status = StatusSkipped
+			return nil
+		}
+		return m.Remove(res.Venv)
+	}), nil
+}
+
 // FindStale lists venvs whose mtime is older than `days`.
 func (m *Manager) FindStale(days int) ([]StaleVenv, error) {
 	venvs, err := m.List()
@@ -522,8 +883,12 @@ type DoctorReport struct {
 	BaseDirExists  bool     `json:"base_dir_exists"`
 	UvAvailable    bool     `json:"uv_available"`
 	PythonVersions []string `json:"python_versions"`
-	VenvCount      int      `json:"venv_count"`
-	Broken         []string `json:"broken,omitempty"`
+	// Interpreters are the discovered Pythons, see Pythons.
+	Interpreters []Interpreter `json:"interpreters"`
+	VenvCount    int           `json:"venv_count"`
+	Broken       []string      `json:"broken,omitempty"`
+	// Problems says why each broken venv cannot run, keyed by venv.
+	Problems map[string][]string `json:"problems,omitempty"`
 }
 
 // Doctor inspects the environment and returns a report.
@@ -533,17 +898,24 @@ func (m *Manager) Doctor() *DoctorReport {
 		BaseDirExists: m.fs.Exists(m.baseDir),
 		UvAvailable:   uvAvailable(),
 	}
-	for _, v := range []string{"python3", "python", "python3.8", "python3.9", "python3.10", "python3.11", "python3.12", "python3.13"} {
-		if _, err := exec.LookPath(v); err == nil {
-			out, _ := exec.Command(v, "--version").Output()
-			r.PythonVersions = append(r.PythonVersions, strings.TrimSpace(string(out)))
+	r.Interpreters = m.Pythons()
+	seen := map[string]bool{}
+	for _, it := range r.Interpreters {
+		v := "Python " + it.Version
+		if !seen[v] {
+			seen[v] = true
+			r.PythonVersions = append(r.PythonVersions, v)
 		}
 	}
 	if venvs, err := m.List(); err == nil {
 		r.VenvCount = len(venvs)
 		for _, v := range venvs {
-			if !m.fs.Exists(utils.PythonPath(m.VenvPath(v))) {
+			if problems := venvProblems(m.VenvPath(v)); len(problems) > 0 {
 				r.Broken = append(r.Broken, v)
+				if r.Problems == nil {
+					r.Problems = map[string][]string{}
+				}
+				r.Problems[v] = problems
 			}
 		}
 	}
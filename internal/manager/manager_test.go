@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
 )
 
 func newTestMgr(t *testing.T) (*Manager, string) {
@@ -145,6 +147,23 @@ func TestResolveTargetsGlobal(t *testing.T) {
 	}
 }
 
+// sizesOf runs GetSize and returns the sizes by venv name.
+func sizesOf(t *testing.T, m *Manager, name string) map[string]int64 {
+	t.Helper()
+	rep, err := m.GetSize(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := map[string]int64{}
+	for _, res := range rep.Results {
+		if res.Error != "" {
+			t.Fatalf("size of %s: %s", res.Venv, res.Error)
+		}
+		sizes[res.Venv] = res.SizeBytes
+	}
+	return sizes
+}
+
 func TestGetSizeRequiresNameOrGlobal(t *testing.T) {
 	m, _ := newTestMgr(t)
 	if _, err := m.GetSize(""); err == nil {
@@ -152,6 +171,25 @@ func TestGetSizeRequiresNameOrGlobal(t *testing.T) {
 	}
 }
 
+// fixedSizeFS reports every directory as size bytes.
+type fixedSizeFS struct {
+	utils.RealFileSystem
+	size int64
+}
+
+func (fs *fixedSizeFS) GetDirSize(string) (int64, map[utils.FileID]int64, error) {
+	return fs.size, nil, nil
+}
+
+func TestGetSizeUsesFileSystem(t *testing.T) {
+	m, dir := newTestMgr(t)
+	os.MkdirAll(filepath.Join(dir, "v"), 0o755)
+	m.SetFileSystem(&fixedSizeFS{size: 4096})
+	if got := sizesOf(t, m, "v"); got["v"] != 4096 {
+		t.Fatalf("sizes = %v", got)
+	}
+}
+
 func TestFindStale(t *testing.T) {
 	m, dir := newTestMgr(t)
 	old := filepath.Join(dir, "old")
@@ -170,3 +208,16 @@ func TestFindStale(t *testing.T) {
 		t.Fatalf("expected [old], got %v", stale)
 	}
 }
+
+func TestUndoMoveReportsFailure(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := undoMove(src, dst); err == nil {
+		t.Fatal("undoMove of a venv that is not at dst succeeded")
+	}
+	os.MkdirAll(dst, 0o755)
+	os.WriteFile(filepath.Join(dst, "pyvenv.cfg"), []byte("home = /usr/bin\n"), 0o644)
+	if err := undoMove(src, dst); err != nil || !fileExists(filepath.Join(src, "pyvenv.cfg")) {
+		t.Fatalf("undoMove: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VenvError is the failure of one venv in a multi-venv operation.
+type VenvError struct {
+	Venv string
+	Err  error
+}
+
+func (e VenvError) Error() string { return fmt.Sprintf("%s: %v", e.Venv, e.Err) }
+
+// BatchError aggregates the per-venv failures of a --global operation. The
+// venvs not listed in Failed succeeded; see BulkReport for the full results.
+type BatchError struct {
+	Op     string
+	Total  int
+	Failed []VenvError
+}
+
+func (e *BatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s failed for %d of %d venvs:", e.Op, len(e.Failed), e.Total)
+	for _, f := range e.Failed {
+		b.WriteString("\n- " + f.Error())
+	}
+	return b.String()
+}
+
+// SetJobs sets how many venvs --global operations process at once. Values
+// below 1 mean one per CPU.
+func (m *Manager) SetJobs(n int) { m.jobs = n }
+
+// workers returns the pool size for n items.
+func (m *Manager) workers(n int) int {
+	w := m.jobs
+	if w < 1 {
+		w = runtime.NumCPU()
+	}
+	if w > n {
+		w = n
+	}
+	if w < 1 {
+		w = 1
+	}
+	return w
+}
+
+// parallel calls fn(i) for i in [0, n) on a pool of workers goroutines.
+func parallel(workers, n int, fn func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// forEachVenv runs fn for every venv path on the worker pool and reports
+// each venv's status, duration and error. A failing venv never stops the
+// others. fn may fill in the rest of its result (packages, output, note).
+func (m *Manager) forEachVenv(op string, targets []string, fn func(venvPath string, res *VenvResult) error) *BulkReport {
+	results := make([]VenvResult, len(targets))
+	parallel(m.workers(len(targets)), len(targets), func(i int) {
+		res := &results[i]
+		res.Venv = filepath.Base(targets[i])
+		start := time.Now()
+		if err := fn(targets[i], res); err != nil {
+			res.Error = err.Error()
+		}
+		res.DurationMS = time.Since(start).Milliseconds()
+	})
+	return NewBulkReport(op, results)
+}
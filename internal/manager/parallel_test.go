@@ -0,0 +1,177 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelBoundsConcurrency(t *testing.T) {
+	var cur, peak int32
+	var mu sync.Mutex
+	seen := map[int]bool{}
+	parallel(3, 20, func(i int) {
+		n := atomic.AddInt32(&cur, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		atomic.AddInt32(&cur, -1)
+		mu.Lock()
+		seen[i] = true
+		mu.Unlock()
+	})
+	if peak > 3 {
+		t.Fatalf("peak concurrency %d exceeds 3 workers", peak)
+	}
+	if len(seen) != 20 {
+		t.Fatalf("ran %d of 20 items", len(seen))
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	m, _ := newTestMgr(t)
+	m.SetJobs(8)
+	if got := m.workers(3); got != 3 {
+		t.Fatalf("workers capped by items: got %d", got)
+	}
+	m.SetJobs(2)
+	if got := m.workers(10); got != 2 {
+		t.Fatalf("workers=%d want 2", got)
+	}
+	m.SetJobs(0)
+	if got := m.workers(0); got != 1 {
+		t.Fatalf("workers never below 1, got %d", got)
+	}
+}
+
+func TestForEachVenvIsolatesFailures(t *testing.T) {
+	m, dir := newTestMgr(t)
+	m.SetJobs(2)
+	var targets []string
+	for _, n := range []string{"a", "b", "c", "d"} {
+		targets = append(targets, filepath.Join(dir, n))
+	}
+	var ran int32
+	rep := m.forEachVenv("clean", targets, func(p string, res *VenvResult) error {
+		atomic.AddInt32(&ran, 1)
+		switch b := filepath.Base(p); b {
+		case "b", "d":
+			return errors.New("boom " + b)
+		case "c":
+			res.Status = StatusSkipped
+		}
+		return nil
+	})
+	if ran != 4 {
+		t.Fatalf("ran %d of 4 venvs; a failure must not stop the rest", ran)
+	}
+	if rep.OK != 1 || rep.Failed != 2 || rep.Skipped != 1 {
+		t.Fatalf("counts: %+v", rep)
+	}
+	if rep.Results[1].Venv != "b" || rep.Results[1].Status != StatusFailed || rep.Results[1].Error != "boom b" {
+		t.Fatalf("results not in target order: %+v", rep.Results)
+	}
+	err := rep.Err()
+	var be *BatchError
+	if !errors.As(err, &be) || be.Total != 4 || len(be.Failed) != 2 || be.Failed[1].Venv != "d" {
+		t.Fatalf("err=%v", err)
+	}
+	if !strings.Contains(err.Error(), "clean failed for 2 of 4 venvs") {
+		t.Fatalf("summary=%q", err.Error())
+	}
+
+	single := m.forEachVenv("clean", targets[:1], func(string, *VenvResult) error { return errors.New("plain") })
+	if err := single.Err(); err == nil || err.Error() != "plain" {
+		t.Fatalf("single target error should be unwrapped, got %v", err)
+	}
+}
+
+func TestGetSizeGlobalParallel(t *testing.T) {
+	m, dir := newTestMgr(t)
+	m.SetGlobal(true)
+	m.SetJobs(4)
+	for i, n := range []string{"a", "b", "c"} {
+		os.MkdirAll(filepath.Join(dir, n, "bin"), 0o755)
+		os.WriteFile(filepath.Join(dir, n, "bin", "python"), make([]byte, (i+1)*10), 0o644)
+	}
+	rep, err := m.GetSize("")
+	if err != nil || rep.OK != 3 {
+		t.Fatalf("report %+v, err %v", rep, err)
+	}
+	if sizes := sizesOf(t, m, ""); sizes["a"] != 10 || sizes["b"] != 20 || sizes["c"] != 30 {
+		t.Fatalf("sizes=%v", sizes)
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	out := []byte("1\n2\n3\n4\n5\n\n")
+	if got := outputTail(out, 2); got != "4\n5" {
+		t.Fatalf("tail=%q", got)
+	}
+	if got := outputTail(nil, 3); got != "" {
+		t.Fatalf("empty tail=%q", got)
+	}
+}
+
+func TestPruneStaleDryRun(t *testing.T) {
+	m, dir := newTestMgr(t)
+	old := time.Now().AddDate(0, 0, -200)
+	for _, n := range []string{"old", "new"} {
+		os.MkdirAll(filepath.Join(dir, n, "bin"), 0o755)
+		os.WriteFile(filepath.Join(dir, n, "bin", "python"), nil, 0o755)
+	}
+	os.Chtimes(filepath.Join(dir, "old"), old, old)
+
+	rep, err := m.PruneStale(90, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Results) != 1 || rep.Results[0].Venv != "old" || rep.Results[0].Status != StatusSkipped {
+		t.Fatalf("dry run report=%+v", rep)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); err != nil {
+		t.Fatal("dry run removed the venv")
+	}
+	rep, err = m.PruneStale(90, false)
+	if err != nil || rep.OK != 1 {
+		t.Fatalf("prune report=%+v err=%v", rep, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); !os.IsNotExist(err) {
+		t.Fatal("stale venv not removed")
+	}
+}
+
+func TestCleanPurgesCacheOnce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for pip")
+	}
+	m, dir := newTestMgr(t)
+	log := filepath.Join(t.TempDir(), "pip.log")
+	for _, n := range []string{"a", "b", "c"} {
+		bin := filepath.Join(dir, n, "bin")
+		os.MkdirAll(filepath.Join(bin, "__pycache__"), 0o755)
+		os.WriteFile(filepath.Join(bin, "pip"), []byte("#!/bin/sh\necho \"$@\" >> "+log+"\n"), 0o755)
+	}
+	m.SetGlobal(true)
+	rep, err := m.CleanWithOptions("", CleanOptions{})
+	if err != nil || rep.Err() != nil {
+		t.Fatalf("clean: %v %v", err, rep.Err())
+	}
+	b, _ := os.ReadFile(log)
+	if got := strings.Count(string(b), "cache purge"); got != 1 {
+		t.Fatalf("cache purged %d times for 3 venvs:\n%s", got, b)
+	}
+	if rep.OK != 3 || fileExists(filepath.Join(dir, "a", "bin", "__pycache__")) {
+		t.Fatalf("report %+v, or __pycache__ left behind", rep)
+	}
+}
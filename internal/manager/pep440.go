@@ -0,0 +1,321 @@
+package manager
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pep440Re is the canonical version pattern from PEP 440, appendix B.
+var pep440Re = regexp.MustCompile(`(?i)^\s*v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?P<pre>[-_.]?(?P<pre_l>a|b|c|rc|alpha|beta|pre|preview)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?P<post>(?:-(?P<post_n1>[0-9]+))|(?:[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?))?` +
+	`(?P<dev>[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?\s*$`)
+
+// pyVersion is a parsed PEP 440 version. Missing post/dev segments are -1.
+type pyVersion struct {
+	Epoch   int
+	Release []int
+	PreL    string // "a", "b", "rc" or "" when not a pre-release
+	PreN    int
+	Post    int
+	Dev     int
+	Local   string
+	raw     string
+}
+
+func parseVersion(s string) (pyVersion, error) {
+	m := pep440Re.FindStringSubmatch(s)
+	if m == nil {
+		return pyVersion{}, fmt.Errorf("invalid version %q", s)
+	}
+	g := func(name string) string { return m[pep440Re.SubexpIndex(name)] }
+	v := pyVersion{Post: -1, Dev: -1, raw: strings.TrimSpace(s)}
+	if e := g("epoch"); e != "" {
+		v.Epoch, _ = strconv.Atoi(e)
+	}
+	for _, part := range strings.Split(g("release"), ".") {
+		n, _ := strconv.Atoi(part)
+		v.Release = append(v.Release, n)
+	}
+	if l := strings.ToLower(g("pre_l")); l != "" {
+		switch l {
+		case "alpha":
+			l = "a"
+		case "beta":
+			l = "b"
+		case "c", "pre", "preview":
+			l = "rc"
+		}
+		v.PreL = l
+		v.PreN, _ = strconv.Atoi(g("pre_n"))
+	}
+	if g("post") != "" {
+		n := g("post_n1")
+		if n == "" {
+			n = g("post_n2")
+		}
+		v.Post, _ = strconv.Atoi(n)
+	}
+	if g("dev") != "" {
+		v.Dev, _ = strconv.Atoi(g("dev_n"))
+	}
+	v.Local = strings.ToLower(g("local"))
+	return v, nil
+}
+
+func (v pyVersion) String() string { return v.raw }
+
+// IsPrerelease reports alpha/beta/rc and dev releases.
+func (v pyVersion) IsPrerelease() bool { return v.PreL != "" || v.Dev >= 0 }
+
+// part returns release component i, treating missing components as zero.
+func (v pyVersion) part(i int) int {
+	if i < len(v.Release) {
+		return v.Release[i]
+	}
+	return 0
+}
+
+// public returns v without its local segment.
+func (v pyVersion) public() pyVersion {
+	v.Local = ""
+	return v
+}
+
+// base returns only epoch+release, e.g. 1.2.0rc1.post3 → 1.2.0.
+func (v pyVersion) base() pyVersion {
+	return pyVersion{Epoch: v.Epoch, Release: v.Release, Post: -1, Dev: -1}
+}
+
+// compareVersions orders a and b per PEP 440 (-1, 0, 1).
+func compareVersions(a, b pyVersion) int {
+	if c := cmpInt(a.Epoch, b.Epoch); c != 0 {
+		return c
+	}
+	n := len(a.Release)
+	if len(b.Release) > n {
+		n = len(b.Release)
+	}
+	for i := 0; i < n; i++ {
+		if c := cmpInt(a.part(i), b.part(i)); c != 0 {
+			return c
+		}
+	}
+	if c := cmpInt(preKey(a), preKey(b)); c != 0 {
+		return c
+	}
+	if a.PreL != "" && a.PreL == b.PreL {
+		if c := cmpInt(a.PreN, b.PreN); c != 0 {
+			return c
+		}
+	}
+	if c := cmpInt(a.Post, b.Post); c != 0 {
+		return c
+	}
+	if c := cmpInt(devKey(a), devKey(b)); c != 0 {
+		return c
+	}
+	return compareLocal(a.Local, b.Local)
+}
+
+// preKey ranks the pre-release phase: dev-only releases sort before any
+// pre-release, and final releases after all of them.
+func preKey(v pyVersion) int {
+	switch {
+	case v.PreL == "" && v.Post < 0 && v.Dev >= 0:
+		return -1
+	case v.PreL == "a":
+		return 1
+	case v.PreL == "b":
+		return 2
+	case v.PreL == "rc":
+		return 3
+	case v.PreL == "":
+		return 4
+	}
+	return 0
+}
+
+func devKey(v pyVersion) int {
+	if v.Dev < 0 {
+		return int(^uint(0) >> 1)
+	}
+	return v.Dev
+}
+
+// compareLocal orders local segments: absent < present; numeric parts sort
+// after alphanumeric ones and compare as integers.
+func compareLocal(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+	sep := func(r rune) bool { return r == '.' || r == '-' || r == '_' }
+	as, bs := strings.FieldsFunc(a, sep), strings.FieldsFunc(b, sep)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil:
+			if c := cmpInt(an, bn); c != 0 {
+				return c
+			}
+		case aerr == nil:
+			return 1
+		case berr == nil:
+			return -1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmpInt(len(as), len(bs))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// specifierClause is one comparison of a PEP 440 specifier set, e.g. ">=1.2".
+type specifierClause struct {
+	Op       string
+	Version  string
+	Wildcard bool
+}
+
+// specifierSet is a comma-separated list of clauses, all of which must hold.
+type specifierSet []specifierClause
+
+var specOpRe = regexp.MustCompile(`^\s*(~=|===|==|!=|<=|>=|<|>)\s*(.+?)\s*$`)
+
+// parseSpecifierSet parses ">=1.0,<2", "==3.11.*" and friends. An empty
+// string yields an empty set, which every version satisfies.
+func parseSpecifierSet(s string) (specifierSet, error) {
+	var set specifierSet
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		m := specOpRe.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("invalid version specifier %q", strings.TrimSpace(part))
+		}
+		c := specifierClause{Op: m[1], Version: m[2]}
+		if strings.HasSuffix(c.Version, ".*") {
+			if c.Op != "==" && c.Op != "!=" {
+				return nil, fmt.Errorf("wildcard not allowed with %s in %q", c.Op, strings.TrimSpace(part))
+			}
+			c.Wildcard = true
+			c.Version = strings.TrimSuffix(c.Version, ".*")
+		}
+		if c.Op != "===" {
+			if _, err := parseVersion(c.Version); err != nil {
+				return nil, err
+			}
+		}
+		set = append(set, c)
+	}
+	return set, nil
+}
+
+// Contains reports whether v satisfies every clause.
+func (s specifierSet) Contains(v pyVersion) bool {
+	for _, c := range s {
+		if !c.contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s specifierSet) String() string {
+	parts := make([]string, len(s))
+	for i, c := range s {
+		parts[i] = c.Op + c.Version
+		if c.Wildcard {
+			parts[i] += ".*"
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (c specifierClause) contains(v pyVersion) bool {
+	if c.Op == "===" {
+		return strings.EqualFold(strings.TrimSpace(v.raw), c.Version)
+	}
+	spec, _ := parseVersion(c.Version)
+	switch c.Op {
+	case "==", "!=":
+		var eq bool
+		if c.Wildcard {
+			eq = prefixMatch(v, spec)
+		} else if spec.Local != "" {
+			eq = compareVersions(v, spec) == 0
+		} else {
+			eq = compareVersions(v.public(), spec) == 0
+		}
+		return eq == (c.Op == "==")
+	case "~=":
+		if len(spec.Release) < 2 {
+			return false
+		}
+		prefix := spec.base()
+		prefix.Release = spec.Release[:len(spec.Release)-1]
+		return compareVersions(v.public(), spec) >= 0 && prefixMatch(v, prefix)
+	case "<=":
+		return compareVersions(v.public(), spec) <= 0
+	case ">=":
+		return compareVersions(v.public(), spec) >= 0
+	case "<":
+		if compareVersions(v.public(), spec) >= 0 {
+			return false
+		}
+		// <V excludes pre-releases of V itself unless V is one.
+		if !spec.IsPrerelease() && v.IsPrerelease() && compareVersions(v.base(), spec.base()) == 0 {
+			return false
+		}
+		return true
+	case ">":
+		if compareVersions(v.public(), spec) <= 0 {
+			return false
+		}
+		// >V excludes post-releases and local versions of V unless V is a
+		// post-release itself.
+		if spec.Post < 0 && v.Post >= 0 && compareVersions(v.base(), spec.base()) == 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// prefixMatch implements "==prefix.*": same epoch and v's release starts
+// with prefix's release (v padded with zeros).
+func prefixMatch(v, prefix pyVersion) bool {
+	if v.Epoch != prefix.Epoch {
+		return false
+	}
+	for i, n := range prefix.Release {
+		if v.part(i) != n {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package manager
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	// Each entry must sort strictly before the next.
+	ordered := []string{
+		"1.0.dev0", "1.0a1", "1.0a2.dev1", "1.0a2", "1.0b1", "1.0rc1",
+		"1.0", "1.0+local.1", "1.0.post1.dev0", "1.0.post1", "1.1", "1.10", "1!0.1",
+	}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, err := parseVersion(ordered[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parseVersion(ordered[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compareVersions(a, b) >= 0 {
+			t.Errorf("expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+	a, _ := parseVersion("1.0")
+	b, _ := parseVersion("1.0.0")
+	if compareVersions(a, b) != 0 {
+		t.Errorf("1.0 and 1.0.0 must compare equal")
+	}
+	c, _ := parseVersion("1.0-alpha-1")
+	d, _ := parseVersion("1.0a1")
+	if compareVersions(c, d) != 0 {
+		t.Errorf("alternate pre-release spelling must normalize")
+	}
+}
+
+func TestParseVersionInvalid(t *testing.T) {
+	for _, s := range []string{"", "abc", "1.0..0", "1.0-foo"} {
+		if _, err := parseVersion(s); err == nil {
+			t.Errorf("parseVersion(%q) expected error", s)
+		}
+	}
+}
+
+func TestSpecifierSetContains(t *testing.T) {
+	cases := []struct {
+		spec, ver string
+		want      bool
+	}{
+		{">=1.21.1,<3", "2.2.1", true},
+		{">=1.21.1,<3", "3.0", false},
+		{"<3", "3.0rc1", false},
+		{"<3rc2", "3.0rc1", true},
+		{">1.0", "1.0.post1", false},
+		{">1.0", "1.0.1", true},
+		{"==3.11.*", "3.11.7", true},
+		{"==3.11.*", "3.12.0", false},
+		{"!=1.5.7", "1.5.7", false},
+		{"~=2.2", "2.9", true},
+		{"~=2.2", "3.0", false},
+		{"~=1.4.5", "1.4.9", true},
+		{"~=1.4.5", "1.5.0", false},
+		{"==1.0", "1.0+cpu", true},
+		{"==1.0+cpu", "1.0", false},
+		{"===1.0", "1.0", true},
+		{"", "0.0.1", true},
+	}
+	for _, c := range cases {
+		set, err := parseSpecifierSet(c.spec)
+		if err != nil {
+			t.Fatalf("parseSpecifierSet(%q): %v", c.spec, err)
+		}
+		v, err := parseVersion(c.ver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := set.Contains(v); got != c.want {
+			t.Errorf("%q contains %q = %v, want %v", c.spec, c.ver, got, c.want)
+		}
+	}
+	if _, err := parseSpecifierSet(">=1.*"); err == nil {
+		t.Error("wildcard with >= must be rejected")
+	}
+}
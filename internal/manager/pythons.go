@@ -0,0 +1,303 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Interpreter sources, in discovery order. An interpreter reachable from
+// several is reported under the first.
+const (
+	SourcePath   = "path"
+	SourcePyenv  = "pyenv"
+	SourceAsdf   = "asdf"
+	SourceUv     = "uv"
+	SourceSystem = "system"
+	SourceConfig = "config"
+)
+
+// Interpreter is a Python found on this machine.
+type Interpreter struct {
+	Path string `json:"path"`
+	// Version is the full version, e.g. "3.12.4".
+	Version string `json:"version"`
+	// Implementation is sys.implementation.name ("cpython", "pypy", ...).
+	Implementation string `json:"implementation"`
+	Arch           string `json:"arch"`
+	// HasVenv is true when both venv and ensurepip import, i.e. `-m venv`
+	// can create a venv with pip (Debian splits ensurepip out).
+	HasVenv bool   `json:"has_venv"`
+	Source  string `json:"source"`
+}
+
+// probeTimeout bounds each interpreter probe; a hung shim must not stall
+// discovery.
+const probeTimeout = 5 * time.Second
+
+const probeScript = `import sys, json, platform, importlib.util as u
+print(json.dumps({"version": platform.python_version(), "implementation": sys.implementation.name,
+ "arch": platform.machine(), "venv": u.find_spec("venv") is not None and u.find_spec("ensurepip") is not None}))`
+
+// interpreterNameRe matches executable names worth probing: python,
+// python3, python3.12, pypy3, pypy3.10 (with .exe on Windows).
+var interpreterNameRe = regexp.MustCompile(`^(python|pypy)(\d+(\.\d+)?)?(\.exe)?$`)
+
+// searchDir is a directory scanned for interpreters.
+type searchDir struct {
+	dir    string
+	source string
+}
+
+// pythonSearchDirs lists the directories discovery scans, in priority order:
+// PATH, pyenv and asdf installs, uv-managed Pythons, system dirs, then the
+// configured python_dirs. Venv bin dirs are left out, see isVenvBinDir.
+func (m *Manager) pythonSearchDirs() []searchDir {
+	var dirs []searchDir
+	add := func(source string, paths ...string) {
+		for _, p := range paths {
+			if p != "" && !m.isVenvBinDir(p) {
+				dirs = append(dirs, searchDir{p, source})
+			}
+		}
+	}
+	for _, p := range filepath.SplitList(os.Getenv("PATH")) {
+		// Shims re-dispatch on the current pyenv/asdf version; the
+		// installs behind them are scanned directly below.
+		if filepath.Base(p) != "shims" {
+			add(SourcePath, p)
+		}
+	}
+	home, _ := os.UserHomeDir()
+	pyenvRoot := os.Getenv("PYENV_ROOT")
+	if pyenvRoot == "" && home != "" {
+		pyenvRoot = filepath.Join(home, ".pyenv")
+	}
+	add(SourcePyenv, installBinDirs(filepath.Join(pyenvRoot, "versions"))...)
+	asdfRoot := os.Getenv("ASDF_DATA_DIR")
+	if asdfRoot == "" && home != "" {
+		asdfRoot = filepath.Join(home, ".asdf")
+	}
+	add(SourceAsdf, installBinDirs(filepath.Join(asdfRoot, "installs", "python"))...)
+	add(SourceUv, installBinDirs(uvPythonDir(home))...)
+	if runtime.GOOS != "windows" {
+		add(SourceSystem, "/usr/bin", "/usr/local/bin")
+	}
+	for _, d := range m.pythonDirs {
+		add(SourceConfig, d, filepath.Join(d, "bin"))
+	}
+	return dirs
+}
+
+// isVenvBinDir reports whether dir belongs to a venv: the active one, one
+// under baseDir, or any dir with a pyvenv.cfg one level up. A venv's python
+// would otherwise be taken for the base interpreter it links to, and venvs
+// made from it would depend on that venv.
+func (m *Manager) isVenvBinDir(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for _, root := range []string{os.Getenv("VIRTUAL_ENV"), m.baseDir} {
+		if root == "" {
+			continue
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return fileExists(filepath.Join(filepath.Dir(abs), "pyvenv.cfg"))
+}
+
+// installBinDirs returns the bin dir of every install under root
+// (root/<version>/bin, or the install dir itself on Windows).
+func installBinDirs(root string) []string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if runtime.GOOS == "windows" {
+			out = append(out, filepath.Join(root, e.Name()))
+		} else {
+			out = append(out, filepath.Join(root, e.Name(), "bin"))
+		}
+	}
+	return out
+}
+
+// uvPythonDir is where `uv python install` puts interpreters.
+func uvPythonDir(home string) string {
+	if d := os.Getenv("UV_PYTHON_INSTALL_DIR"); d != "" {
+		return d
+	}
+	if runtime.GOOS == "windows" {
+		if d := os.Getenv("APPDATA"); d != "" {
+			return filepath.Join(d, "uv", "python")
+		}
+		return ""
+	}
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return filepath.Join(d, "uv", "python")
+	}
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "uv", "python")
+}
+
+// Pythons discovers the interpreters on this machine, newest version first.
+func (m *Manager) Pythons() []Interpreter {
+	cands := interpreterCandidates(m.pythonSearchDirs())
+	return probeInterpreters(cands, m.workers(len(cands)))
+}
+
+// interpreterCandidates lists executables in dirs that look like Python,
+// once per resolved path.
+func interpreterCandidates(dirs []searchDir) []Interpreter {
+	seen := map[string]bool{}
+	var cands []Interpreter
+	for _, d := range dirs {
+		entries, err := os.ReadDir(d.dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !interpreterNameRe.MatchString(e.Name()) {
+				continue
+			}
+			p := filepath.Join(d.dir, e.Name())
+			real, err := filepath.EvalSymlinks(p)
+			if err != nil || seen[real] || !isExecutable(real) {
+				continue
+			}
+			seen[real] = true
+			cands = append(cands, Interpreter{Path: p, Source: d.source})
+		}
+	}
+	return cands
+}
+
+// probeInterpreters probes cands on a pool of workers and returns those that
+// run, newest version first.
+func probeInterpreters(cands []Interpreter, workers int) []Interpreter {
+	ok := make([]bool, len(cands))
+	parallel(workers, len(cands), func(i int) {
+		ok[i] = probeInterpreter(&cands[i]) == nil
+	})
+	var out []Interpreter
+	versions := map[string]pyVersion{}
+	for i, c := range cands {
+		if ok[i] {
+			out = append(out, c)
+			versions[c.Path], _ = parseVersion(c.Version)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return compareVersions(versions[out[i].Path], versions[out[j].Path]) > 0
+	})
+	return out
+}
+
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || fi.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || fi.Mode()&0o111 != 0
+}
+
+// probeInterpreter runs it in isolated mode and fills in version,
+// implementation, arch and venv support.
+func probeInterpreter(it *Interpreter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, it.Path, "-I", "-c", probeScript).Output()
+	if err != nil {
+		return err
+	}
+	var r struct {
+		Version        string `json:"version"`
+		Implementation string `json:"implementation"`
+		Arch           string `json:"arch"`
+		Venv           bool   `json:"venv"`
+	}
+	if err := json.Unmarshal(out, &r); err != nil {
+		return err
+	}
+	if r.Version == "" {
+		return fmt.Errorf("%s: no version reported", it.Path)
+	}
+	it.Version, it.Implementation, it.Arch, it.HasVenv = r.Version, r.Implementation, r.Arch, r.Venv
+	return nil
+}
+
+// ResolvePython maps a --python request to an interpreter. A path is used
+// as is; anything else is parsed by parsePythonSpec ("3.12", ">=3.10,<3.13",
+// "pypy3.10") and picks the newest matching discovered interpreter (CPython
+// unless the request says pypy) that can create venvs.
+func (m *Manager) ResolvePython(spec string) (*Interpreter, error) {
+	if strings.ContainsAny(spec, `/\`) {
+		if !isExecutable(spec) {
+			return nil, fmt.Errorf("python interpreter %s not found", spec)
+		}
+		it := &Interpreter{Path: spec}
+		// Version and implementation are informational for explicit paths.
+		probeInterpreter(it)
+		return it, nil
+	}
+	ps, err := parsePythonSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	// uv venv seeds the venv itself and does not need the venv module.
+	return pickInterpreter(m.Pythons(), ps, !m.useUv)
+}
+
+// pickInterpreter chooses the interpreter for spec from a discovery list
+// sorted newest first. Final releases win over pre-releases, so ">=3.12"
+// does not pick a 3.14 beta when 3.13 is installed.
+func pickInterpreter(all []Interpreter, spec pythonSpec, needVenv bool) (*Interpreter, error) {
+	var noVenv *Interpreter
+	for _, pre := range []bool{false, true} {
+		for i := range all {
+			it := &all[i]
+			v, err := parseVersion(it.Version)
+			if err != nil || v.IsPrerelease() != pre || !spec.matches(it.Implementation, v) {
+				continue
+			}
+			if it.HasVenv || !needVenv {
+				return it, nil
+			}
+			if noVenv == nil {
+				noVenv = it
+			}
+		}
+	}
+	if noVenv != nil {
+		return nil, fmt.Errorf("python %s at %s has no venv/ensurepip module (on Debian/Ubuntu install python%s-venv)", noVenv.Version, noVenv.Path, majorMinor(noVenv.Version))
+	}
+	var found []string
+	for _, it := range all {
+		found = append(found, it.Version)
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no python interpreter matching %q found", spec.raw)
+	}
+	return nil, fmt.Errorf("no python interpreter matching %q found (available: %s)", spec.raw, strings.Join(found, ", "))
+}
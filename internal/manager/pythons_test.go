@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakePython writes a script named name into dir that answers the
+// discovery probe with the given values.
+func fakePython(t *testing.T, dir, name, version, impl string, venv bool) string {
+	t.Helper()
+	v := "false"
+	if venv {
+		v = "true"
+	}
+	p := filepath.Join(dir, name)
+	script := "#!/bin/sh\necho '{\"version\": \"" + version + "\", \"implementation\": \"" + impl + "\", \"arch\": \"x86_64\", \"venv\": " + v + "}'\n"
+	if err := os.WriteFile(p, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDiscoverInterpreters(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-ins for python")
+	}
+	a, b := t.TempDir(), t.TempDir()
+	fakePython(t, a, "python3.11", "3.11.9", "cpython", true)
+	p12 := fakePython(t, b, "python3.12", "3.12.4", "cpython", true)
+	os.Symlink(p12, filepath.Join(a, "python3"))
+	fakePython(t, b, "pypy3.10", "3.10.14", "pypy", true)
+	fakePython(t, b, "python3.13", "3.13.0", "cpython", false)
+	os.WriteFile(filepath.Join(b, "python3-config"), []byte("#!/bin/sh\n"), 0o755)
+	os.WriteFile(filepath.Join(b, "python2"), []byte("#!/bin/sh\nexit 1\n"), 0o755)
+
+	cands := interpreterCandidates([]searchDir{{a, SourcePath}, {b, SourceConfig}})
+	pys := probeInterpreters(cands, 2)
+	var got []string
+	for _, p := range pys {
+		got = append(got, p.Version+"/"+p.Source)
+	}
+	// python3 -> python3.12 is reported once, under the first source.
+	if want := "3.13.0/config 3.12.4/path 3.11.9/path 3.10.14/config"; strings.Join(got, " ") != want {
+		t.Fatalf("got %v, want %s", got, want)
+	}
+
+	pick := func(s string, needVenv bool) (*Interpreter, error) {
+		spec, err := parsePythonSpec(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pickInterpreter(pys, spec, needVenv)
+	}
+	for spec, want := range map[string]string{
+		"3.12": "3.12.4", "python3.11": "3.11.9", "3": "3.12.4", "pypy3.10": "3.10.14", "": "3.12.4",
+		">=3.10,<3.12": "3.11.9", "3.11.*": "3.11.9", "~=3.10": "3.12.4", "pypy3.10-7.3.16": "3.10.14",
+	} {
+		it, err := pick(spec, true)
+		if err != nil || it.Version != want {
+			t.Errorf("pick(%q)=%v %v, want %s", spec, it, err, want)
+		}
+	}
+	if _, err := pick("3.13", true); err == nil || !strings.Contains(err.Error(), "no venv") {
+		t.Errorf("interpreter without venv should be rejected with a hint, got %v", err)
+	}
+	if it, err := pick("3.13", false); err != nil || it.Version != "3.13.0" {
+		t.Errorf("uv does not need the venv module, got %v %v", it, err)
+	}
+	if _, err := pick("3.9", true); err == nil || !strings.Contains(err.Error(), "available: 3.13.0") {
+		t.Errorf("missing version should list what exists, got %v", err)
+	}
+}
+
+func TestPythonSearchDirsSkipVenvs(t *testing.T) {
+	m, base := newTestMgr(t)
+	managed := filepath.Join(base, "app", "bin")
+	other := filepath.Join(t.TempDir(), "proj", ".venv")
+	os.MkdirAll(filepath.Join(other, "bin"), 0o755)
+	os.WriteFile(filepath.Join(other, "pyvenv.cfg"), []byte("home = /usr/bin\n"), 0o644)
+	active := filepath.Join(t.TempDir(), "active", "bin")
+	plain := t.TempDir()
+	t.Setenv("VIRTUAL_ENV", filepath.Dir(active))
+	t.Setenv("PATH", strings.Join([]string{active, managed, filepath.Join(other, "bin"), plain}, string(os.PathListSeparator)))
+
+	var got []string
+	for _, d := range m.pythonSearchDirs() {
+		if d.source == SourcePath {
+			got = append(got, d.dir)
+		}
+	}
+	if len(got) != 1 || got[0] != plain {
+		t.Fatalf("PATH dirs scanned: %v, want only %s", got, plain)
+	}
+}
@@ -0,0 +1,205 @@
+package manager
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Where a venv's python request came from, see PythonChoice.
+const (
+	PythonSourceFlag     = "flag"
+	PythonSourceFile     = ".python-version"
+	PythonSourceRequires = "requires-python"
+	PythonSourceConfig   = "config"
+	PythonSourceDefault  = "default"
+	// PythonSourceRepair marks a request derived by Repair from the venv's
+	// previous interpreter.
+	PythonSourceRepair = "repair"
+)
+
+const (
+	implCPython = "cpython"
+	implPyPy    = "pypy"
+)
+
+// pythonSpec is a parsed interpreter request: an implementation and a PEP 440
+// specifier set over its version.
+type pythonSpec struct {
+	raw  string
+	impl string
+	set  specifierSet
+}
+
+// parsePythonSpec accepts bare versions ("3", "3.12", "3.11.*"), PEP 440
+// specifier sets (">=3.10,<3.13") and implementation-prefixed names
+// ("python3.12", "pypy3.10", pyenv's "pypy3.10-7.3.12"). A bare version is
+// a prefix: "3.12" means "==3.12.*".
+func parsePythonSpec(s string) (pythonSpec, error) {
+	spec := pythonSpec{raw: strings.TrimSpace(s), impl: implCPython}
+	v := strings.TrimSuffix(strings.ToLower(spec.raw), ".exe")
+	switch {
+	case strings.HasPrefix(v, implPyPy):
+		spec.impl, v = implPyPy, strings.TrimPrefix(v, implPyPy)
+	case strings.HasPrefix(v, implCPython):
+		v = strings.TrimPrefix(v, implCPython)
+	case strings.HasPrefix(v, "python"):
+		v = strings.TrimPrefix(v, "python")
+	}
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return spec, nil
+	}
+	if v[0] >= '0' && v[0] <= '9' {
+		// pyenv suffixes the implementation's own version: pypy3.10-7.3.12.
+		v, _, _ = strings.Cut(v, "-")
+		if !strings.HasSuffix(v, ".*") {
+			v += ".*"
+		}
+		v = "==" + v
+	}
+	set, err := parseSpecifierSet(v)
+	if err != nil {
+		return spec, fmt.Errorf("invalid python request %q: %v", spec.raw, err)
+	}
+	spec.set = set
+	return spec, nil
+}
+
+func (s pythonSpec) matches(impl string, v pyVersion) bool {
+	return impl == s.impl && s.set.Contains(v)
+}
+
+// PythonChoice records how a venv's interpreter was picked. It is stored in
+// the venv's .venv-manager/python.json and shown by describe.
+type PythonChoice struct {
+	// Requested is the --python value, .python-version line or
+	// requires-python specifier; empty for the system default.
+	Requested string `json:"requested,omitempty"`
+	// Source is one of the PythonSource* constants.
+	Source string `json:"source"`
+	// SourcePath is the project file Requested was read from.
+	SourcePath     string `json:"source_path,omitempty"`
+	Interpreter    string `json:"interpreter,omitempty"`
+	Version        string `json:"version,omitempty"`
+	Implementation string `json:"implementation,omitempty"`
+}
+
+func pythonChoicePath(venvPath string) string {
+	return filepath.Join(venvPath, ".venv-manager", "python.json")
+}
+
+func writePythonChoice(venvPath string, c *PythonChoice) error {
+	p := pythonChoicePath(venvPath)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, b, 0o644)
+}
+
+// readPythonChoice returns the recorded choice, or nil for venvs created
+// before it was recorded or by other tools.
+func readPythonChoice(venvPath string) *PythonChoice {
+	b, err := os.ReadFile(pythonChoicePath(venvPath))
+	if err != nil {
+		return nil
+	}
+	var c PythonChoice
+	if json.Unmarshal(b, &c) != nil {
+		return nil
+	}
+	return &c
+}
+
+// VenvPythonChoice returns how a venv's interpreter was chosen, or nil when
+// that was not recorded.
+func (m *Manager) VenvPythonChoice(name string) (*PythonChoice, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	return readPythonChoice(venvPath), nil
+}
+
+// ProjectPython finds the interpreter a project asks for, walking up from
+// dir: a .python-version file first, then requires-python in
+// pyproject.toml. It returns an empty request when neither exists. Entries
+// that are not interpreter requests, such as the virtualenv names
+// pyenv-virtualenv writes to .python-version, are skipped with a warning.
+func ProjectPython(dir string) (request, source, path string, warnings []string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	for {
+		p := filepath.Join(dir, ".python-version")
+		for _, ln := range readLines(p) {
+			// "system" defers to whatever python is on PATH.
+			if ln == "system" {
+				continue
+			}
+			if _, err := parsePythonSpec(ln); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: ignoring %q: not a python version", p, ln))
+				continue
+			}
+			return ln, PythonSourceFile, p, warnings, nil
+		}
+		p = filepath.Join(dir, "pyproject.toml")
+		if req, err := requiresPython(p); err != nil {
+			return "", "", "", warnings, err
+		} else if req != "" {
+			if _, err := parsePythonSpec(req); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: ignoring requires-python %q: %v", p, req, err))
+			} else {
+				return req, PythonSourceRequires, p, warnings, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", "", warnings, nil
+		}
+		dir = parent
+	}
+}
+
+var (
+	tomlTableRe  = regexp.MustCompile(`^\s*\[\s*([A-Za-z0-9_.-]+)\s*\]`)
+	requiresPyRe = regexp.MustCompile(`^\s*requires-python\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// requiresPython reads [project] requires-python from a pyproject.toml. A
+// missing file yields "".
+func requiresPython(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+	inProject := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		ln := sc.Text()
+		if m := tomlTableRe.FindStringSubmatch(ln); m != nil {
+			inProject = m[1] == "project"
+			continue
+		}
+		if !inProject {
+			continue
+		}
+		if m := requiresPyRe.FindStringSubmatch(ln); m != nil {
+			return m[1] + m[2], nil
+		}
+	}
+	return "", sc.Err()
+}
@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePythonSpec(t *testing.T) {
+	for in, want := range map[string]string{
+		"3":              "cpython ==3.*",
+		"3.12":           "cpython ==3.12.*",
+		"3.11.*":         "cpython ==3.11.*",
+		"python3.12":     "cpython ==3.12.*",
+		"cpython3.13":    "cpython ==3.13.*",
+		"pypy3.10":       "pypy ==3.10.*",
+		"pypy":           "pypy ",
+		">=3.10,<3.13":   "cpython >=3.10,<3.13",
+		" >= 3.9 ":       "cpython >=3.9",
+		"python3.12.exe": "cpython ==3.12.*",
+	} {
+		spec, err := parsePythonSpec(in)
+		if err != nil {
+			t.Errorf("parse(%q): %v", in, err)
+			continue
+		}
+		if got := spec.impl + " " + spec.set.String(); got != want {
+			t.Errorf("parse(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for _, bad := range []string{"3.x", ">=three", "jython2.7"} {
+		if _, err := parsePythonSpec(bad); err == nil {
+			t.Errorf("parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPickPrefersFinalReleases(t *testing.T) {
+	all := []Interpreter{
+		{Path: "/a", Version: "3.14.0b2", Implementation: "cpython", HasVenv: true},
+		{Path: "/b", Version: "3.13.1", Implementation: "cpython", HasVenv: true},
+	}
+	for s, want := range map[string]string{">=3.12": "/b", "3.14": "/a"} {
+		spec, _ := parsePythonSpec(s)
+		it, err := pickInterpreter(all, spec, true)
+		if err != nil || it.Path != want {
+			t.Errorf("pick(%q) = %v %v, want %s", s, it, err, want)
+		}
+	}
+}
+
+func TestProjectPython(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "pkg", "src")
+	os.MkdirAll(sub, 0o755)
+
+	req, src, _, _, err := ProjectPython(sub)
+	if err != nil || req != "" || src != "" {
+		t.Fatalf("no project files: got %q %q %v", req, src, err)
+	}
+
+	pyproject := filepath.Join(root, "pkg", "pyproject.toml")
+	os.WriteFile(pyproject, []byte(`[tool.other]
+requires-python = "==2.7"
+
+[project]
+name = "demo"
+requires-python = ">=3.10,<3.13"  # comment
+`), 0o644)
+	req, src, path, _, err := ProjectPython(sub)
+	if err != nil || req != ">=3.10,<3.13" || src != PythonSourceRequires || path != pyproject {
+		t.Fatalf("requires-python: got %q %q %q %v", req, src, path, err)
+	}
+
+	// A .python-version further up does not beat a nearer pyproject.toml.
+	os.WriteFile(filepath.Join(root, ".python-version"), []byte("# pinned\nsystem\n3.11\n"), 0o644)
+	req, src, _, _, err = ProjectPython(sub)
+	if err != nil || req != ">=3.10,<3.13" {
+		t.Fatalf("nearest file should win: got %q %q %v", req, src, err)
+	}
+	os.WriteFile(filepath.Join(root, "pkg", ".python-version"), []byte("pypy3.10\n"), 0o644)
+	req, src, _, _, err = ProjectPython(sub)
+	if err != nil || req != "pypy3.10" || src != PythonSourceFile {
+		t.Fatalf(".python-version: got %q %q %v", req, src, err)
+	}
+
+	// pyenv-virtualenv writes env names; they are skipped with a warning
+	// and the walk goes on to the pyproject.toml next to it.
+	os.WriteFile(filepath.Join(root, "pkg", ".python-version"), []byte("myproject-env\n"), 0o644)
+	req, src, _, warnings, err := ProjectPython(sub)
+	if err != nil || req != ">=3.10,<3.13" || src != PythonSourceRequires {
+		t.Fatalf("virtualenv name: got %q %q %v", req, src, err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "myproject-env") {
+		t.Fatalf("warnings = %q", warnings)
+	}
+	os.Remove(pyproject)
+	os.WriteFile(filepath.Join(root, ".python-version"), []byte("3.x\n"), 0o644)
+	req, _, _, warnings, err = ProjectPython(sub)
+	if err != nil || req != "" || len(warnings) != 2 {
+		t.Fatalf("no usable request: got %q %q %v", req, warnings, err)
+	}
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pyvenvCfgPath returns the path of a venv's pyvenv.cfg.
+func pyvenvCfgPath(venvPath string) string {
+	return filepath.Join(venvPath, "pyvenv.cfg")
+}
+
+// readPyvenvCfg parses pyvenv.cfg into lower-cased keys. Missing or
+// unreadable files yield an empty map.
+func readPyvenvCfg(venvPath string) map[string]string {
+	cfg := map[string]string{}
+	data, err := os.ReadFile(pyvenvCfgPath(venvPath))
+	if err != nil {
+		return cfg
+	}
+	for _, ln := range strings.Split(string(data), "\n") {
+		k, v, ok := strings.Cut(ln, "=")
+		if !ok {
+			continue
+		}
+		cfg[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return cfg
+}
+
+// venvPythonVersion returns the X.Y.Z interpreter version recorded in
+// pyvenv.cfg ("version" for `python -m venv`, "version_info" for uv and
+// virtualenv), or "" when it is not recorded.
+func venvPythonVersion(venvPath string) string {
+	cfg := readPyvenvCfg(venvPath)
+	v := cfg["version_info"]
+	if v == "" {
+		v = cfg["version"]
+	}
+	// virtualenv writes e.g. "3.11.7.final.0".
+	if parts := strings.Split(v, "."); len(parts) > 3 {
+		v = strings.Join(parts[:3], ".")
+	}
+	return v
+}
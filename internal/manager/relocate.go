@@ -0,0 +1,202 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// relocateVenv rewrites the absolute paths a venv records for itself after
+// it was moved from oldPath to venvPath: console-script shebangs, activate
+// scripts and symlinks in bin, pyvenv.cfg, and the .pth files and
+// direct_url.json records in site-packages. It returns how many files were
+// changed, and fails when any of them still names oldPath afterwards.
+// Windows .exe launchers get their recorded interpreter rewritten; other
+// binary files are left alone.
+func relocateVenv(venvPath, oldPath string) (int, error) {
+	return relocateTree(venvPath, oldPath, venvPath)
+}
+
+// relocateTree is relocateVenv for a venv tree at root, possibly partial,
+// that is to live at newPath.
+func relocateTree(root, oldPath, newPath string) (int, error) {
+	files, err := relocatableFiles(root)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, f := range files {
+		ok, err := relocateFile(f, oldPath, newPath)
+		if err != nil {
+			return changed, err
+		}
+		if ok {
+			changed++
+		}
+	}
+	var left []string
+	for _, f := range files {
+		if mentionsPath(f, oldPath) {
+			left = append(left, f)
+		}
+	}
+	if len(left) > 0 {
+		return changed, fmt.Errorf("%d file(s) still reference %s: %s", len(left), oldPath, strings.Join(left, ", "))
+	}
+	return changed, nil
+}
+
+// relocatableFiles lists the files of a venv that may record its path.
+func relocatableFiles(venvPath string) ([]string, error) {
+	files := []string{pyvenvCfgPath(venvPath)}
+	bin := utils.VenvBinDir(venvPath)
+	entries, err := os.ReadDir(bin)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Type().IsRegular() || e.Type()&os.ModeSymlink != 0 {
+			files = append(files, filepath.Join(bin, e.Name()))
+		}
+	}
+	for _, sp := range sitePackagesDirs(venvPath) {
+		pths, _ := filepath.Glob(filepath.Join(sp, "*.pth"))
+		urls, _ := filepath.Glob(filepath.Join(sp, "*.dist-info", "direct_url.json"))
+		files = append(append(files, pths...), urls...)
+	}
+	return files, nil
+}
+
+// relocateFile points one file, or symlink, at newPath instead of oldPath
+// and reports whether it changed.
+func relocateFile(f, oldPath, newPath string) (bool, error) {
+	fi, err := os.Lstat(f)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(f)
+		if err != nil {
+			return false, err
+		}
+		repl := string(replacePath([]byte(target), oldPath, newPath))
+		if repl == target {
+			return false, nil
+		}
+		if err := os.Remove(f); err != nil {
+			return false, err
+		}
+		return true, os.Symlink(repl, f)
+	}
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return false, err
+	}
+	var repl []byte
+	if bytes.IndexByte(b, 0) >= 0 {
+		repl = relocateLauncher(b, oldPath, newPath)
+	} else {
+		repl = replacePath(b, oldPath, newPath)
+	}
+	if strings.HasSuffix(f, ".json") {
+		// JSON escapes Windows backslashes.
+		repl = replacePath(repl, jsonEscapePath(oldPath), jsonEscapePath(newPath))
+	}
+	if bytes.Equal(repl, b) {
+		return false, nil
+	}
+	// WriteFile on an existing file keeps its mode.
+	return true, os.WriteFile(f, repl, 0o644)
+}
+
+// mentionsPath reports whether a text file, or a symlink's target, still
+// contains p.
+func mentionsPath(f, p string) bool {
+	if target, err := os.Readlink(f); err == nil {
+		return pathPattern(p).MatchString(target)
+	}
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return false
+	}
+	if bytes.IndexByte(b, 0) >= 0 {
+		i, j, _, ok := launcherInterpreter(b)
+		return ok && pathPattern(p).Match(b[i:j])
+	}
+	return pathPattern(p).Match(b) || pathPattern(jsonEscapePath(p)).Match(b)
+}
+
+// launcherInterpreter locates the interpreter a Windows console-script
+// launcher records, as the span b[i:j]. pip's launchers are a stub, a
+// "#!<python.exe>" line and then a zip of the script; uv's end with the
+// interpreter path, its length as a little-endian uint32 and a "UVSC" or
+// "UVPY" magic, and report uv so that the length can be updated.
+func launcherInterpreter(b []byte) (i, j int, uv, ok bool) {
+	if n := len(b); n >= 8 && (bytes.HasSuffix(b, []byte("UVSC")) || bytes.HasSuffix(b, []byte("UVPY"))) {
+		size := int(binary.LittleEndian.Uint32(b[n-8:]))
+		if size <= n-8 {
+			return n - 8 - size, n - 8, true, true
+		}
+		return 0, 0, false, false
+	}
+	// The shebang ends where the zip starts: its end-of-central-directory
+	// record gives the central directory's size and offset in the zip.
+	ecd := bytes.LastIndex(b, []byte("PK\x05\x06"))
+	if ecd < 0 || len(b)-ecd < 22 {
+		return 0, 0, false, false
+	}
+	size := int(binary.LittleEndian.Uint32(b[ecd+12:]))
+	offset := int(binary.LittleEndian.Uint32(b[ecd+16:]))
+	start := ecd - size - offset
+	if start < 0 {
+		return 0, 0, false, false
+	}
+	i = bytes.LastIndex(b[:start], []byte("#!"))
+	if i < 0 {
+		return 0, 0, false, false
+	}
+	return i, start, false, true
+}
+
+// relocateLauncher rewrites the interpreter path of a Windows launcher,
+// see launcherInterpreter, and returns b unchanged for any other binary.
+func relocateLauncher(b []byte, oldPath, newPath string) []byte {
+	i, j, uv, ok := launcherInterpreter(b)
+	if !ok {
+		return b
+	}
+	repl := replacePath(b[i:j], oldPath, newPath)
+	if bytes.Equal(repl, b[i:j]) {
+		return b
+	}
+	out := append(append(append([]byte{}, b[:i]...), repl...), b[j:]...)
+	if uv {
+		binary.LittleEndian.PutUint32(out[len(out)-8:], uint32(len(repl)))
+	}
+	return out
+}
+
+// pathPattern matches p where a path ends with it, so that /x/venv is not
+// found inside /x/venv2 when a venv is renamed to a longer name.
+func pathPattern(p string) *regexp.Regexp {
+	return regexp.MustCompile(regexp.QuoteMeta(p) + `([^A-Za-z0-9._-]|$)`)
+}
+
+func replacePath(b []byte, from, to string) []byte {
+	return pathPattern(from).ReplaceAllFunc(b, func(m []byte) []byte {
+		return append([]byte(to), m[len(from):]...)
+	})
+}
+
+func jsonEscapePath(p string) string {
+	return strings.ReplaceAll(p, `\`, `\\`)
+}
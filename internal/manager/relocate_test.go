@@ -0,0 +1,176 @@
+package manager
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// fakeSitePackagesRefs adds the site-packages files that record a venv's
+// path: a .pth file and a direct_url.json.
+func fakeSitePackagesRefs(t *testing.T, venvPath string) string {
+	t.Helper()
+	sp := filepath.Join(venvPath, "lib", "python3.12", "site-packages")
+	if runtime.GOOS == "windows" {
+		sp = filepath.Join(venvPath, "Lib", "site-packages")
+	}
+	di := filepath.Join(sp, "proj-1.0.dist-info")
+	if err := os.MkdirAll(di, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(sp, "proj.pth"), []byte(filepath.Join(venvPath, "src", "proj")+"\n"), 0o644)
+	os.WriteFile(filepath.Join(di, "direct_url.json"), []byte(`{"url": "file://`+filepath.ToSlash(filepath.Join(venvPath, "src", "proj"))+`", "dir_info": {"editable": true}}`), 0o644)
+	return sp
+}
+
+func TestRelocateSitePackagesAndLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks")
+	}
+	dir := t.TempDir()
+	// The new name extends the old one: /x/v must not match inside /x/v2.
+	old, venv := filepath.Join(dir, "v"), filepath.Join(dir, "v2")
+	fakeVenv(t, old, "")
+	sp := fakeSitePackagesRefs(t, old)
+	os.Symlink(filepath.Join(old, "bin", "tool"), filepath.Join(old, "bin", "tool-link"))
+	os.Rename(old, venv)
+	sp = strings.Replace(sp, old, venv, 1)
+
+	n, err := relocateVenv(venv, old)
+	if err != nil || n != 6 {
+		t.Fatalf("relocate changed %d files, err %v; want 6", n, err)
+	}
+	b, _ := os.ReadFile(filepath.Join(sp, "proj.pth"))
+	if string(b) != filepath.Join(venv, "src", "proj")+"\n" {
+		t.Errorf(".pth not rewritten: %q", b)
+	}
+	b, _ = os.ReadFile(filepath.Join(sp, "proj-1.0.dist-info", "direct_url.json"))
+	if !strings.Contains(string(b), "file://"+venv+"/src/proj") {
+		t.Errorf("direct_url.json not rewritten: %s", b)
+	}
+	if target, _ := os.Readlink(filepath.Join(venv, "bin", "tool-link")); target != filepath.Join(venv, "bin", "tool") {
+		t.Errorf("symlink not repointed: %s", target)
+	}
+
+	// Relocating again is a no-op, and moving back restores every path.
+	if n, err := relocateVenv(venv, old); err != nil || n != 0 {
+		t.Fatalf("second relocate changed %d files, err %v", n, err)
+	}
+	os.Rename(venv, old)
+	if _, err := relocateVenv(old, venv); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = os.ReadFile(filepath.Join(old, "pyvenv.cfg"))
+	if !strings.HasSuffix(string(b), "-m venv "+old+"\n") {
+		t.Errorf("pyvenv.cfg not restored: %q", b)
+	}
+}
+
+func TestMentionsPath(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "activate")
+	os.WriteFile(f, []byte("VIRTUAL_ENV=/x/venv2\n"), 0o644)
+	if mentionsPath(f, "/x/venv") {
+		t.Error("/x/venv found inside /x/venv2")
+	}
+	os.WriteFile(f, []byte("PATH=/x/venv/bin:$PATH\n"), 0o644)
+	if !mentionsPath(f, "/x/venv") {
+		t.Error("/x/venv/bin not found")
+	}
+}
+
+func TestRenameAndMoveRelocate(t *testing.T) {
+	m, _ := newTestMgr(t)
+	fakeVenv(t, m.VenvPath("a"), "")
+	tool := func(venv string) string {
+		b, _ := os.ReadFile(filepath.Join(utils.VenvBinDir(venv), "tool"))
+		return strings.TrimSpace(string(b))
+	}
+
+	if err := m.Rename("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tool(m.VenvPath("b")); got != "#!"+utils.PythonPath(m.VenvPath("b")) {
+		t.Fatalf("shebang after rename: %q", got)
+	}
+
+	other := t.TempDir()
+	if _, err := m.Move("b", m.GetBaseDir()); err == nil {
+		t.Fatal("move into the venv's own base dir should fail")
+	}
+	dst, err := m.Move("b", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst != filepath.Join(other, "b") || fileExists(m.VenvPath("b")) {
+		t.Fatalf("moved to %s, original still there: %v", dst, fileExists(m.VenvPath("b")))
+	}
+	if got := tool(dst); got != "#!"+utils.PythonPath(dst) {
+		t.Fatalf("shebang after move: %q", got)
+	}
+	fakeVenv(t, m.VenvPath("b"), "")
+	if _, err := m.Move("b", other); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("move onto an existing venv: %v", err)
+	}
+}
+
+// fakeLaunchers writes a pip (distlib) and a uv console-script launcher for
+// the venv at venvPath into bin.
+func fakeLaunchers(t *testing.T, bin, venvPath string) {
+	t.Helper()
+	python := filepath.Join(venvPath, "Scripts", "python.exe")
+	var script bytes.Buffer
+	zw := zip.NewWriter(&script)
+	w, _ := zw.Create("__main__.py")
+	w.Write([]byte("from proj import main\nmain()\n"))
+	zw.Close()
+	pip := append([]byte("MZ\x00\x00stub"), "#!"+python+"\r\n"...)
+	os.WriteFile(filepath.Join(bin, "pip-tool.exe"), append(pip, script.Bytes()...), 0o755)
+
+	uv := append([]byte("MZ\x00\x00stub"), script.Bytes()...)
+	uv = append(uv, python...)
+	uv = binary.LittleEndian.AppendUint32(uv, uint32(len(python)))
+	os.WriteFile(filepath.Join(bin, "uv-tool.exe"), append(uv, "UVSC"...), 0o755)
+}
+
+func TestRelocateLaunchers(t *testing.T) {
+	dir := t.TempDir()
+	old, venv := filepath.Join(dir, "staging", "v"), filepath.Join(dir, "venvs", "long-name")
+	bin := filepath.Join(venv, "Scripts")
+	os.MkdirAll(bin, 0o755)
+	fakeLaunchers(t, bin, old)
+	for _, f := range []string{"pip-tool.exe", "uv-tool.exe"} {
+		if !mentionsPath(filepath.Join(bin, f), old) {
+			t.Fatalf("%s: old path not found", f)
+		}
+	}
+	python := filepath.Join(venv, "Scripts", "python.exe")
+	for _, f := range []string{"pip-tool.exe", "uv-tool.exe"} {
+		p := filepath.Join(bin, f)
+		if ok, err := relocateFile(p, old, venv); !ok || err != nil {
+			t.Fatalf("%s: changed %v, err %v", f, ok, err)
+		}
+		if mentionsPath(p, old) {
+			t.Fatalf("%s still names %s", f, old)
+		}
+		b, _ := os.ReadFile(p)
+		i, j, _, ok := launcherInterpreter(b)
+		if !ok || !strings.Contains(string(b[i:j]), python) {
+			t.Fatalf("%s: interpreter %q, want %s", f, b[i:j], python)
+		}
+		// The script zip must still be found from its end.
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if f == "uv-tool.exe" {
+			zr, err = zip.NewReader(bytes.NewReader(b[:i]), int64(i))
+		}
+		if err != nil || len(zr.File) != 1 {
+			t.Fatalf("%s: script zip unreadable: %v", f, err)
+		}
+	}
+}
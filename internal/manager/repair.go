@@ -0,0 +1,258 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// RepairOptions configures Repair.
+type RepairOptions struct {
+	// Python overrides the interpreter to rebuild on, see ResolvePython.
+	// Empty picks one with the venv's recorded major.minor version.
+	Python string
+	// Force rebuilds venvs that look healthy too.
+	Force bool
+}
+
+// venvProblems lists why a venv's interpreter cannot run: bin/python missing
+// or dangling (the base Python was uninstalled or upgraded away), or the
+// pyvenv.cfg home gone. Empty means healthy.
+func venvProblems(venvPath string) []string {
+	var problems []string
+	py := utils.PythonPath(venvPath)
+	if _, err := os.Lstat(py); err != nil {
+		problems = append(problems, "python executable is missing")
+	} else if _, err := os.Stat(py); err != nil {
+		target, _ := os.Readlink(py)
+		problems = append(problems, fmt.Sprintf("python link is dangling (-> %s)", target))
+	}
+	if !fileExists(pyvenvCfgPath(venvPath)) {
+		problems = append(problems, "pyvenv.cfg is missing")
+	} else if home := readPyvenvCfg(venvPath)["home"]; home == "" {
+		problems = append(problems, "pyvenv.cfg has no home")
+	} else if fi, err := os.Stat(home); err != nil || !fi.IsDir() {
+		problems = append(problems, fmt.Sprintf("base interpreter dir %s no longer exists", home))
+	}
+	return problems
+}
+
+// Repair rebuilds broken venvs (see venvProblems), or every venv with
+// --global. Each is rebuilt in a staging directory on a compatible python
+// with the packages recorded in its site-packages metadata, or in its newest
+// snapshot when that is unreadable, then swapped in. Healthy venvs are
+// skipped unless opts.Force is set.
+func (m *Manager) Repair(name string, opts RepairOptions) (*BulkReport, error) {
+	targets, err := m.resolveTargets(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.forEachVenv("repair", targets, func(venvPath string, res *VenvResult) error {
+		return m.repairVenv(venvPath, res, opts)
+	}), nil
+}
+
+func (m *Manager) repairVenv(venvPath string, res *VenvResult, opts RepairOptions) error {
+	name := filepath.Base(venvPath)
+	problems := venvProblems(venvPath)
+	if len(problems) == 0 && !opts.Force {
+		res.Status, res.Note = StatusSkipped, "healthy"
+		return nil
+	}
+	unlock, err := m.lock(name, "repair")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if fileExists(m.repythonBackupPath(name)) {
+		return fmt.Errorf("'%s' has a repython backup pending; confirm or revert it first", name)
+	}
+	reqs, from, err := m.repairRequirements(venvPath)
+	if err != nil {
+		return err
+	}
+	create := CreateOptions{Python: opts.Python}
+	if create.Python == "" {
+		if create.Python, err = m.repairPython(venvPath); err != nil {
+			return err
+		}
+		create.PythonSource = PythonSourceRepair
+	}
+	staging, choice, err := m.buildStaged(name, venvPath, create, []byte(strings.Join(reqs, "\n")+"\n"), "")
+	if err != nil {
+		return err
+	}
+	defer m.releaseStaging()
+	// The broken venv is not worth keeping; it is only parked until the
+	// new one is in place. Venv names never start with a dot.
+	old := filepath.Join(m.stagingDir(), ".old-"+name)
+	if err := os.RemoveAll(old); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	if err := swapIn(staging, venvPath, old); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	os.RemoveAll(old)
+
+	res.Packages = reqs
+	what := "no packages"
+	if len(reqs) > 0 {
+		what = fmt.Sprintf("%d package(s) from %s", len(reqs), from)
+	}
+	res.Note = fmt.Sprintf("rebuilt on python %s with %s", choice.Version, what)
+	if len(problems) > 0 {
+		res.Note = strings.Join(problems, "; ") + "; " + res.Note
+	}
+	return nil
+}
+
+// repairRequirements lists the requirements to reinstall: the venv's
+// installed distributions as recorded in site-packages (seed packages
+// aside), or the newest snapshot when site-packages is unreadable. from says
+// which. Editable, VCS, URL and local installs are reinstalled from where
+// they came from, see repairSpec; when a local source is gone, nothing can
+// be rebuilt and the error names it.
+func (m *Manager) repairRequirements(venvPath string) (reqs []string, from string, err error) {
+	if pkgs, err := readInstalled(venvPath); err == nil {
+		var gone []string
+		for _, p := range pkgs {
+			if seedPackages[normalizePkgName(p.Name)] {
+				continue
+			}
+			spec, local := repairSpec(p)
+			if local != "" && !fileExists(local) {
+				gone = append(gone, fmt.Sprintf("%s (%s)", p.Name, local))
+			}
+			reqs = append(reqs, spec)
+		}
+		for _, sp := range sitePackagesDirs(venvPath) {
+			links, _ := filepath.Glob(filepath.Join(sp, "*.egg-link"))
+			for _, l := range links {
+				lines := readLines(l)
+				if len(lines) == 0 {
+					continue
+				}
+				if !fileExists(lines[0]) {
+					gone = append(gone, fmt.Sprintf("%s (%s)", strings.TrimSuffix(filepath.Base(l), ".egg-link"), lines[0]))
+				}
+				reqs = append(reqs, "-e "+lines[0])
+			}
+		}
+		if len(gone) > 0 {
+			return nil, "", fmt.Errorf("cannot reinstall %s: source no longer exists; venv left as it was", strings.Join(gone, ", "))
+		}
+		sort.Strings(reqs)
+		return reqs, "site-packages", nil
+	}
+	snaps, err := m.ListSnapshots(filepath.Base(venvPath))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(snaps) == 0 {
+		return nil, "", fmt.Errorf("no site-packages and no snapshot to recover packages from")
+	}
+	return readLines(filepath.Join(snapshotsDir(venvPath), snaps[0].ID+".txt")), "snapshot " + snaps[0].ID, nil
+}
+
+// directURL is the PEP 610 direct_url.json record of a distribution
+// installed from a URL, a VCS or a local directory.
+type directURL struct {
+	URL          string `json:"url"`
+	Subdirectory string `json:"subdirectory"`
+	DirInfo      *struct {
+		Editable bool `json:"editable"`
+	} `json:"dir_info"`
+	VCSInfo *struct {
+		VCS      string `json:"vcs"`
+		CommitID string `json:"commit_id"`
+	} `json:"vcs_info"`
+}
+
+// repairSpec is the requirement that reinstalls p the way it was installed,
+// as pip freeze writes it: a pin, "-e <dir>" for local editables, or
+// "name @ <url>" for URL, VCS and local installs, VCS ones at the installed
+// commit. local is the file or directory it needs on disk, if any.
+func repairSpec(p InstalledPackage) (spec, local string) {
+	b, err := os.ReadFile(filepath.Join(p.MetadataPath, "direct_url.json"))
+	if err != nil {
+		return p.Spec(), ""
+	}
+	var d directURL
+	if json.Unmarshal(b, &d) != nil || d.URL == "" {
+		return p.Spec(), ""
+	}
+	u := d.URL
+	if d.VCSInfo != nil {
+		u = d.VCSInfo.VCS + "+" + u
+		if d.VCSInfo.CommitID != "" {
+			u += "@" + d.VCSInfo.CommitID
+		}
+	} else if strings.HasPrefix(d.URL, "file:") {
+		local = fileURLPath(d.URL)
+	}
+	var frag []string
+	if d.Subdirectory != "" {
+		frag = append(frag, "subdirectory="+d.Subdirectory)
+	}
+	if d.DirInfo != nil && d.DirInfo.Editable {
+		if local != "" {
+			return "-e " + filepath.Join(local, filepath.FromSlash(d.Subdirectory)), local
+		}
+		frag = append([]string{"egg=" + p.Name}, frag...)
+		return "-e " + u + "#" + strings.Join(frag, "&"), ""
+	}
+	if len(frag) > 0 {
+		u += "#" + strings.Join(frag, "&")
+	}
+	return p.Name + " @ " + u, local
+}
+
+// fileURLPath converts a file: URL to a local path.
+func fileURLPath(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return strings.TrimPrefix(s, "file://")
+	}
+	p := u.Path
+	// file:///C:/x parses to /C:/x.
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+// repairPython picks the interpreter request to rebuild a venv on: the same
+// implementation and major.minor as before so the recorded pins still
+// install, else what the venv was originally created with.
+func (m *Manager) repairPython(venvPath string) (string, error) {
+	choice := readPythonChoice(venvPath)
+	version := venvPythonVersion(venvPath)
+	if version == "" && choice != nil {
+		version = choice.Version
+	}
+	if version != "" {
+		spec := "==" + majorMinor(version) + ".*"
+		if choice != nil && choice.Implementation == implPyPy {
+			spec = implPyPy + spec
+		}
+		if _, err := m.ResolvePython(spec); err == nil {
+			return spec, nil
+		}
+	}
+	if choice != nil && choice.Requested != "" {
+		return choice.Requested, nil
+	}
+	if version != "" {
+		return "", fmt.Errorf("no python %s found to rebuild on; install one or pass --python", majorMinor(version))
+	}
+	return "", nil
+}
@@ -0,0 +1,126 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+func TestVenvProblems(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("venv interpreters are symlinks on POSIX only")
+	}
+	dir := t.TempDir()
+	home := filepath.Join(dir, "base", "bin")
+	os.MkdirAll(home, 0o755)
+	os.WriteFile(filepath.Join(home, "python3.11"), []byte("#!/bin/sh\n"), 0o755)
+	venv := filepath.Join(dir, "v")
+	os.MkdirAll(utils.VenvBinDir(venv), 0o755)
+	os.Symlink(filepath.Join(home, "python3.11"), utils.PythonPath(venv))
+	os.WriteFile(pyvenvCfgPath(venv), []byte("home = "+home+"\nversion = 3.11.7\n"), 0o644)
+
+	if p := venvProblems(venv); len(p) != 0 {
+		t.Fatalf("healthy venv reported %v", p)
+	}
+	os.RemoveAll(filepath.Join(dir, "base"))
+	p := venvProblems(venv)
+	if len(p) != 2 || !strings.Contains(p[0], "dangling") || !strings.Contains(p[1], home) {
+		t.Fatalf("removed base python: got %v", p)
+	}
+	os.Remove(utils.PythonPath(venv))
+	os.Remove(pyvenvCfgPath(venv))
+	if p := venvProblems(venv); len(p) != 2 || !strings.Contains(p[0], "missing") || !strings.Contains(p[1], "pyvenv.cfg") {
+		t.Fatalf("missing files: got %v", p)
+	}
+}
+
+func TestRepairRequirements(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	writeDistInfo(t, sp, "requests", "2.31.0", nil, true)
+	writeDistInfo(t, sp, "pip", "24.0", nil, false)
+	writeDistInfo(t, sp, "idna", "3.6", nil, false)
+	venv := m.VenvPath("v")
+	snaps := snapshotsDir(venv)
+	os.MkdirAll(snaps, 0o755)
+	os.WriteFile(filepath.Join(snaps, "20260101-100000.txt"), []byte("requests==2.0.0\n"), 0o644)
+
+	reqs, from, err := m.repairRequirements(venv)
+	if err != nil || from != "site-packages" || strings.Join(reqs, " ") != "idna==3.6 requests==2.31.0" {
+		t.Fatalf("got %v from %q, err %v", reqs, from, err)
+	}
+
+	// Without site-packages the newest snapshot is used.
+	os.RemoveAll(filepath.Dir(filepath.Dir(sp)))
+	reqs, from, err = m.repairRequirements(venv)
+	if err != nil || from != "snapshot 20260101-100000" || strings.Join(reqs, " ") != "requests==2.0.0" {
+		t.Fatalf("got %v from %q, err %v", reqs, from, err)
+	}
+}
+
+func TestRepairRequirementsDirectReferences(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file URLs of POSIX paths")
+	}
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	src := filepath.Join(dir, "src", "proj")
+	os.MkdirAll(src, 0o755)
+	direct := map[string]string{
+		"proj":   `{"url": "file://` + src + `", "dir_info": {"editable": true}}`,
+		"lib":    `{"url": "https://github.com/o/lib.git", "vcs_info": {"vcs": "git", "commit_id": "abc123", "requested_revision": "main"}, "subdirectory": "py"}`,
+		"blob":   `{"url": "https://example.com/blob-1.0-py3-none-any.whl", "archive_info": {}}`,
+		"vendor": `{"url": "file://` + src + `", "dir_info": {}}`,
+	}
+	for name, d := range direct {
+		writeDistInfo(t, sp, name, "1.0", nil, true)
+		os.WriteFile(filepath.Join(sp, name+"-1.0.dist-info", "direct_url.json"), []byte(d), 0o644)
+	}
+	writeDistInfo(t, sp, "idna", "3.6", nil, false)
+	legacy := filepath.Join(dir, "src", "legacy")
+	os.MkdirAll(legacy, 0o755)
+	os.WriteFile(filepath.Join(sp, "legacy.egg-link"), []byte(legacy+"\n.\n"), 0o644)
+
+	reqs, _, err := m.repairRequirements(m.VenvPath("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"-e " + legacy,
+		"-e " + src,
+		"blob @ https://example.com/blob-1.0-py3-none-any.whl",
+		"idna==3.6",
+		"lib @ git+https://github.com/o/lib.git@abc123#subdirectory=py",
+		"vendor @ file://" + src,
+	}
+	if strings.Join(reqs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(reqs, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A local source that is gone cannot be reinstalled.
+	os.RemoveAll(src)
+	if _, _, err := m.repairRequirements(m.VenvPath("v")); err == nil || !strings.Contains(err.Error(), "proj ("+src+")") {
+		t.Fatalf("missing source: %v", err)
+	}
+}
+
+func TestRepairSkipsHealthy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("venv interpreters are symlinks on POSIX only")
+	}
+	m, _ := newTestMgr(t)
+	venv := m.VenvPath("ok")
+	os.MkdirAll(utils.VenvBinDir(venv), 0o755)
+	exe, _ := os.Executable()
+	os.Symlink(exe, utils.PythonPath(venv))
+	os.WriteFile(pyvenvCfgPath(venv), []byte("home = "+filepath.Dir(exe)+"\n"), 0o644)
+
+	rep, err := m.Repair("ok", RepairOptions{})
+	if err != nil || rep.Skipped != 1 || rep.Results[0].Note != "healthy" {
+		t.Fatalf("got %+v, %v", rep, err)
+	}
+}
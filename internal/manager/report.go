@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+)
+
+// Per-venv result statuses.
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// outputTailLines bounds how much tool output a VenvResult keeps.
+const outputTailLines = 10
+
+// VenvResult is the outcome of a bulk operation on one venv.
+type VenvResult struct {
+	Venv       string `json:"venv"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	// Packages lists the packages the operation touched.
+	Packages []string `json:"packages,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	// OutputTail is the last lines of pip's output.
+	OutputTail string `json:"output_tail,omitempty"`
+	Note       string `json:"note,omitempty"`
+	// ReclaimedBytes and Reclaimed report disk space freed, per package.
+	ReclaimedBytes int64          `json:"reclaimed_bytes,omitempty"`
+	Reclaimed      []PackageBytes `json:"reclaimed,omitempty"`
+	// SizeBytes is the venv's disk usage, see GetSize.
+	SizeBytes int64 `json:"size_bytes,omitempty"`
+}
+
+// BulkReport collects the per-venv results of an operation over one or more
+// venvs, in target order.
+type BulkReport struct {
+	Operation string       `json:"operation"`
+	Results   []VenvResult `json:"results"`
+	OK        int          `json:"ok"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+}
+
+// NewBulkReport builds a report, normalising statuses: a result with an
+// error is failed, one without a status is ok.
+func NewBulkReport(op string, results []VenvResult) *BulkReport {
+	r := &BulkReport{Operation: op, Results: results}
+	if r.Results == nil {
+		r.Results = []VenvResult{}
+	}
+	for i := range r.Results {
+		res := &r.Results[i]
+		switch {
+		case res.Error != "":
+			res.Status = StatusFailed
+		case res.Status == "":
+			res.Status = StatusOK
+		}
+		switch res.Status {
+		case StatusFailed:
+			r.Failed++
+		case StatusSkipped:
+			r.Skipped++
+		default:
+			r.OK++
+		}
+	}
+	return r
+}
+
+// Err returns nil when every venv succeeded. A single failed venv yields its
+// own error; several yield a *BatchError.
+func (r *BulkReport) Err() error {
+	if r.Failed == 0 {
+		return nil
+	}
+	if len(r.Results) == 1 {
+		return errors.New(r.Results[0].Error)
+	}
+	be := &BatchError{Op: r.Operation, Total: len(r.Results)}
+	for _, res := range r.Results {
+		if res.Status == StatusFailed {
+			be.Failed = append(be.Failed, VenvError{Venv: res.Venv, Err: errors.New(res.Error)})
+		}
+	}
+	return be
+}
+
+// outputTail returns the last n non-empty lines of out.
+func outputTail(out []byte, n int) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,264 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// RepythonOptions configures Repython.
+type RepythonOptions struct {
+	// Python is the target interpreter request, see ResolvePython.
+	Python string
+	// Verify is a shell command run in the rebuilt venv, after `pip check`,
+	// before it is swapped in. A failure leaves the original untouched.
+	Verify string
+}
+
+// RepythonReport is the outcome of Repython.
+type RepythonReport struct {
+	Venv string `json:"venv"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Interpreter is the python the new venv was built from.
+	Interpreter string `json:"interpreter,omitempty"`
+	// Packages is how many freeze lines were reinstalled.
+	Packages int    `json:"packages"`
+	Verify   string `json:"verify,omitempty"`
+	// Backup is where the original venv is kept until ConfirmRepython or
+	// RevertRepython.
+	Backup string `json:"backup"`
+}
+
+// repythonBackupPath is where Repython keeps the original venv.
+func (m *Manager) repythonBackupPath(name string) string {
+	return filepath.Join(m.baseDir, ".venv-manager", "repython", name)
+}
+
+// Repython rebuilds a venv on another interpreter. The current freeze is
+// installed into a new venv built in a staging directory and verified there;
+// only then is the new venv swapped in. The original is kept as a backup
+// until ConfirmRepython deletes it or RevertRepython swaps it back.
+func (m *Manager) Repython(name string, opts RepythonOptions) (*RepythonReport, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Python == "" {
+		return nil, fmt.Errorf("a target python is required")
+	}
+	unlock, err := m.lock(name, "repython")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	backup := m.repythonBackupPath(name)
+	if fileExists(backup) {
+		return nil, fmt.Errorf("'%s' has a repython backup pending; run 'venv-manager repython %s --confirm' or '--revert' first", name, name)
+	}
+	if err := m.checkOffline(); err != nil {
+		return nil, err
+	}
+	freeze, err := m.pkg(venvPath).freeze().Output()
+	if err != nil {
+		return nil, fmt.Errorf("pip freeze failed: %v", err)
+	}
+	pins, editables := fetchablePins(string(freeze))
+	rep := &RepythonReport{Venv: name, From: venvPythonVersion(venvPath), Packages: len(pins) + len(editables), Verify: opts.Verify, Backup: backup}
+
+	staging, choice, err := m.buildStaged(name, venvPath, CreateOptions{Python: opts.Python}, freeze, opts.Verify)
+	if err != nil {
+		return nil, err
+	}
+	defer m.releaseStaging()
+	rep.To, rep.Interpreter = choice.Version, choice.Interpreter
+	if err := os.MkdirAll(filepath.Dir(backup), 0o755); err != nil {
+		os.RemoveAll(staging)
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+	if err := swapIn(staging, venvPath, backup); err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+	return rep, nil
+}
+
+// buildStaged builds a replacement for the venv name at venvPath in the
+// staging directory: a new venv created with create carrying venvPath's metadata, with
+// requirements installed and, when verify is set, `pip check` and verify
+// passed. The caller swaps it in and then calls releaseStaging; on error
+// nothing is left behind.
+func (m *Manager) buildStaged(name, venvPath string, create CreateOptions, requirements []byte, verify string) (staging string, choice *PythonChoice, err error) {
+	staging = m.stagingPath(name)
+	if err := os.RemoveAll(staging); err != nil {
+		return "", nil, fmt.Errorf("failed to clear staging directory: %v", err)
+	}
+	m.sweepStagingOnce()
+	if err := m.holdStaging(); err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(staging)
+			m.releaseStaging()
+		}
+	}()
+	if err := m.createVenv(staging, create); err != nil {
+		return "", nil, err
+	}
+	// Snapshots and the index override move with the venv; the python
+	// choice is the new one.
+	if err := copyVenvMeta(venvPath, staging); err != nil {
+		return "", nil, fmt.Errorf("failed to copy venv metadata: %v", err)
+	}
+	choice = readPythonChoice(staging)
+	if choice == nil {
+		choice = &PythonChoice{Version: venvPythonVersion(staging)}
+	}
+
+	if len(bytes.TrimSpace(requirements)) > 0 {
+		tmp, err := os.CreateTemp("", "venv-rebuild-req-*.txt")
+		if err != nil {
+			return "", nil, err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.Write(requirements); err != nil {
+			tmp.Close()
+			return "", nil, err
+		}
+		tmp.Close()
+		if out, err := m.runInstall(staging, "-r", tmp.Name()); err != nil {
+			return "", nil, fmt.Errorf("failed to install packages on python %s; '%s' is unchanged: %v\n%s", choice.Version, name, err, out)
+		}
+	}
+	if verify != "" {
+		if out, err := m.pkg(staging).check().CombinedOutput(); err != nil {
+			return "", nil, fmt.Errorf("pip check failed on python %s; '%s' is unchanged: %v\n%s", choice.Version, name, err, out)
+		}
+		if err := runIn(staging, shellArgv(verify)); err != nil {
+			return "", nil, fmt.Errorf("verify command failed on python %s; '%s' is unchanged: %v", choice.Version, name, err)
+		}
+	}
+	return staging, choice, nil
+}
+
+// swapIn moves the venv built at staging to venvPath, moving the current one
+// to backup, and fixes up the paths the new venv recorded while staged. Any
+// failure puts the original back.
+func swapIn(staging, venvPath, backup string) error {
+	if err := os.Rename(venvPath, backup); err != nil {
+		return fmt.Errorf("failed to move '%s' to backup: %v", venvPath, err)
+	}
+	if err := os.Rename(staging, venvPath); err != nil {
+		if rerr := os.Rename(backup, venvPath); rerr != nil {
+			return fmt.Errorf("failed to move new venv into place: %v\nputting the old venv back from %s also failed: %v", err, backup, rerr)
+		}
+		return fmt.Errorf("failed to move new venv into place: %v", err)
+	}
+	if _, err := relocateVenv(venvPath, staging); err != nil {
+		rerr := os.Rename(venvPath, staging)
+		if rerr == nil {
+			rerr = os.Rename(backup, venvPath)
+		}
+		if rerr != nil {
+			return fmt.Errorf("failed to relocate new venv: %v\nputting the old venv back from %s also failed: %v", err, backup, rerr)
+		}
+		return fmt.Errorf("failed to relocate new venv: %v", err)
+	}
+	return nil
+}
+
+// copyVenvMeta copies a venv's .venv-manager directory to another venv,
+// except the python choice.
+func copyVenvMeta(from, to string) error {
+	src := filepath.Join(from, ".venv-manager")
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	dst := filepath.Join(to, ".venv-manager")
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == filepath.Base(pythonChoicePath(from)) {
+			continue
+		}
+		if err := utils.CopyDir(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepythonBackup returns the pending repython backup of a venv, or "" when
+// there is none.
+func (m *Manager) RepythonBackup(name string) (string, error) {
+	if _, err := m.requireVenv(name); err != nil {
+		return "", err
+	}
+	if b := m.repythonBackupPath(name); fileExists(b) {
+		return b, nil
+	}
+	return "", nil
+}
+
+// ConfirmRepython deletes the backup Repython kept.
+func (m *Manager) ConfirmRepython(name string) error {
+	backup, err := m.RepythonBackup(name)
+	if err != nil {
+		return err
+	}
+	unlock, err := m.lock(name, "repython confirm")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if backup == "" {
+		return fmt.Errorf("'%s' has no repython backup", name)
+	}
+	return os.RemoveAll(backup)
+}
+
+// RevertRepython puts the original venv back and discards the rebuilt one.
+// The backup's recorded paths still name the venv's own location, so it
+// needs no relocation.
+func (m *Manager) RevertRepython(name string) error {
+	backup, err := m.RepythonBackup(name)
+	if err != nil {
+		return err
+	}
+	unlock, err := m.lock(name, "repython revert")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if backup == "" {
+		return fmt.Errorf("'%s' has no repython backup", name)
+	}
+	venvPath := m.VenvPath(name)
+	discard := m.stagingPath(name)
+	if err := os.RemoveAll(discard); err != nil {
+		return err
+	}
+	if err := m.holdStaging(); err != nil {
+		return err
+	}
+	defer m.releaseStaging()
+	if err := os.Rename(venvPath, discard); err != nil {
+		return fmt.Errorf("failed to move rebuilt venv aside: %v", err)
+	}
+	if err := os.Rename(backup, venvPath); err != nil {
+		if rerr := os.Rename(discard, venvPath); rerr != nil {
+			return fmt.Errorf("failed to restore backup: %v\nputting the rebuilt venv back from %s also failed: %v", err, discard, rerr)
+		}
+		return fmt.Errorf("failed to restore backup: %v", err)
+	}
+	return os.RemoveAll(discard)
+}
@@ -0,0 +1,117 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// fakeVenv lays out the files a venv records its own path in.
+func fakeVenv(t *testing.T, venvPath, marker string) {
+	t.Helper()
+	bin := utils.VenvBinDir(venvPath)
+	os.MkdirAll(bin, 0o755)
+	os.WriteFile(filepath.Join(venvPath, "pyvenv.cfg"), []byte("home = /usr/bin\ncommand = /usr/bin/python3 -m venv "+venvPath+"\n"), 0o644)
+	os.WriteFile(filepath.Join(bin, "activate"), []byte("VIRTUAL_ENV=\""+venvPath+"\"\n"), 0o644)
+	os.WriteFile(filepath.Join(bin, "tool"), []byte("#!"+filepath.Join(bin, "python")+"\n"), 0o755)
+	os.WriteFile(filepath.Join(bin, "launcher.exe"), []byte("MZ\x00#!"+venvPath), 0o755)
+	os.WriteFile(filepath.Join(venvPath, "marker"), []byte(marker), 0o644)
+}
+
+func TestRelocateVenv(t *testing.T) {
+	dir := t.TempDir()
+	old, venv := filepath.Join(dir, "staging", "v"), filepath.Join(dir, "v")
+	fakeVenv(t, old, "")
+	os.Rename(old, venv)
+
+	n, err := relocateVenv(venv, old)
+	if err != nil || n != 3 {
+		t.Fatalf("relocate changed %d files, err %v; want 3", n, err)
+	}
+	tool := filepath.Join(utils.VenvBinDir(venv), "tool")
+	b, _ := os.ReadFile(tool)
+	if string(b) != "#!"+filepath.Join(utils.VenvBinDir(venv), "python")+"\n" {
+		t.Errorf("shebang not rewritten: %q", b)
+	}
+	if fi, _ := os.Stat(tool); fi.Mode().Perm() != 0o755 {
+		t.Errorf("mode changed to %v", fi.Mode())
+	}
+	b, _ = os.ReadFile(filepath.Join(utils.VenvBinDir(venv), "launcher.exe"))
+	if !strings.Contains(string(b), old) {
+		t.Error("binary files must be left alone")
+	}
+}
+
+func TestRepythonSwapConfirmRevert(t *testing.T) {
+	m, dir := newTestMgr(t)
+	venv := m.VenvPath("v")
+	fakeVenv(t, venv, "old")
+	os.MkdirAll(filepath.Join(venv, ".venv-manager", "snapshots"), 0o755)
+	os.WriteFile(filepath.Join(venv, ".venv-manager", "snapshots", "s.txt"), []byte("a==1\n"), 0o644)
+	writePythonChoice(venv, &PythonChoice{Source: PythonSourceDefault, Version: "3.11.0"})
+
+	staging := m.stagingPath("v")
+	fakeVenv(t, staging, "new")
+	writePythonChoice(staging, &PythonChoice{Source: PythonSourceFlag, Version: "3.12.0"})
+	if err := copyVenvMeta(venv, staging); err != nil {
+		t.Fatal(err)
+	}
+	if c := readPythonChoice(staging); c.Version != "3.12.0" {
+		t.Errorf("python choice must not be copied over, got %+v", c)
+	}
+	if !fileExists(filepath.Join(staging, ".venv-manager", "snapshots", "s.txt")) {
+		t.Error("snapshots should be copied")
+	}
+
+	backup := m.repythonBackupPath("v")
+	os.MkdirAll(filepath.Dir(backup), 0o755)
+	if err := swapIn(staging, venv, backup); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(venv, "marker")); string(b) != "new" {
+		t.Fatalf("new venv not swapped in: %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(utils.VenvBinDir(venv), "activate")); strings.Contains(string(b), "staging") {
+		t.Errorf("staging path left in activate: %q", b)
+	}
+	if fileExists(staging) {
+		t.Error("staging dir should be gone")
+	}
+	if names, _ := m.List(); len(names) != 1 {
+		t.Errorf("backup must not show up as a venv: %v", names)
+	}
+	if err := m.Rename("v", "w"); err == nil {
+		t.Error("rename with a pending backup should fail")
+	}
+
+	if err := m.RevertRepython("v"); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(venv, "marker")); string(b) != "old" {
+		t.Fatalf("backup not restored: %q", b)
+	}
+	if err := m.ConfirmRepython("v"); err == nil {
+		t.Error("confirm without a backup should fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".venv-manager", "staging", "v")); !os.IsNotExist(err) {
+		t.Error("rebuilt venv should be discarded on revert")
+	}
+}
+
+func TestSwapInPutsOldVenvBack(t *testing.T) {
+	m, dir := newTestMgr(t)
+	venv := filepath.Join(dir, "v")
+	fakeVenv(t, venv, "old")
+	backup := m.repythonBackupPath("v")
+	os.MkdirAll(filepath.Dir(backup), 0o755)
+	err := swapIn(m.stagingPath("v"), venv, backup)
+	if err == nil || strings.Contains(err.Error(), "also failed") {
+		t.Fatalf("swapIn without a staged venv: %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(venv, "marker")); string(b) != "old" {
+		t.Fatalf("old venv not put back: %q", b)
+	}
+}
@@ -0,0 +1,346 @@
+package manager
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// requirement is a parsed PEP 508 dependency specifier, e.g.
+// `requests[socks]>=2.0,<3; python_version >= "3.8"`.
+type requirement struct {
+	Name      string
+	Extras    []string
+	Specifier string
+	URL       string
+	Marker    string
+}
+
+var reqNameRe = regexp.MustCompile(`^\s*([A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?)\s*`)
+
+func parseRequirement(s string) (requirement, error) {
+	var r requirement
+	body, marker, _ := strings.Cut(s, ";")
+	r.Marker = strings.TrimSpace(marker)
+
+	m := reqNameRe.FindStringSubmatch(body)
+	if m == nil {
+		return r, fmt.Errorf("invalid requirement %q", s)
+	}
+	r.Name = m[1]
+	rest := strings.TrimSpace(body[len(m[0]):])
+
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return r, fmt.Errorf("unterminated extras in %q", s)
+		}
+		for _, e := range strings.Split(rest[1:end], ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				r.Extras = append(r.Extras, e)
+			}
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+	switch {
+	case strings.HasPrefix(rest, "@"):
+		r.URL = strings.TrimSpace(rest[1:])
+	case strings.HasPrefix(rest, "("):
+		r.Specifier = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(rest, "("), ")"))
+	default:
+		r.Specifier = rest
+	}
+	return r, nil
+}
+
+// markerEnv is the set of PEP 508 environment marker variables for a venv.
+type markerEnv map[string]string
+
+// markerEnvFor derives marker values for a venv without running its
+// interpreter: the Python version comes from pyvenv.cfg, platform values
+// from the host (a venv always targets the machine it lives on).
+func markerEnvFor(venvPath string) markerEnv {
+	env := markerEnv{
+		"os_name":                        "posix",
+		"sys_platform":                   runtime.GOOS,
+		"platform_system":                strings.ToUpper(runtime.GOOS[:1]) + runtime.GOOS[1:],
+		"platform_machine":               goarchToMachine(runtime.GOOS, runtime.GOARCH),
+		"implementation_name":            "cpython",
+		"platform_python_implementation": "CPython",
+		"platform_release":               "",
+		"platform_version":               "",
+	}
+	if runtime.GOOS == "windows" {
+		env["os_name"] = "nt"
+		env["sys_platform"] = "win32"
+	}
+	ver := venvPythonVersion(venvPath)
+	if ver != "" {
+		env["python_full_version"] = ver
+		env["implementation_version"] = ver
+		parts := strings.SplitN(ver, ".", 3)
+		if len(parts) >= 2 {
+			env["python_version"] = parts[0] + "." + parts[1]
+		}
+	}
+	if impl := strings.ToLower(readPyvenvCfg(venvPath)["implementation"]); impl == "pypy" {
+		env["implementation_name"] = "pypy"
+		env["platform_python_implementation"] = "PyPy"
+	}
+	return env
+}
+
+func goarchToMachine(goos, goarch string) string {
+	switch goarch {
+	case "amd64":
+		if goos == "windows" {
+			return "AMD64"
+		}
+		return "x86_64"
+	case "arm64":
+		if goos == "linux" {
+			return "aarch64"
+		}
+		return "arm64"
+	case "386":
+		return "i686"
+	}
+	return goarch
+}
+
+// versionMarkers are compared with PEP 440 semantics; everything else is a
+// plain string comparison.
+var versionMarkers = map[string]bool{
+	"python_version":         true,
+	"python_full_version":    true,
+	"implementation_version": true,
+}
+
+// evalMarker evaluates a PEP 508 marker expression. extra is the extra being
+// resolved ("" for the base requirement set).
+func evalMarker(marker string, env markerEnv, extra string) (bool, error) {
+	if strings.TrimSpace(marker) == "" {
+		return true, nil
+	}
+	toks, err := tokenizeMarker(marker)
+	if err != nil {
+		return false, err
+	}
+	p := &markerParser{toks: toks, env: env, extra: extra}
+	v, err := p.parseOr()
+	if err != nil {
+		return false, err
+	}
+	if p.pos != len(p.toks) {
+		return false, fmt.Errorf("unexpected %q in marker %q", p.toks[p.pos].val, marker)
+	}
+	return v, nil
+}
+
+type markerTok struct {
+	kind string // "str", "ident", "op", "(", ")"
+	val  string
+}
+
+func tokenizeMarker(s string) ([]markerTok, error) {
+	var toks []markerTok
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, markerTok{kind: string(c)})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in marker %q", s)
+			}
+			toks = append(toks, markerTok{kind: "str", val: s[i+1 : i+1+end]})
+			i += end + 2
+		case strings.ContainsRune("<>=!~", rune(c)):
+			j := i + 1
+			for j < len(s) && strings.ContainsRune("<>=!~", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, markerTok{kind: "op", val: s[i:j]})
+			i = j
+		case c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || (s[j] >= 'a' && s[j] <= 'z') || (s[j] >= 'A' && s[j] <= 'Z') || (s[j] >= '0' && s[j] <= '9')) {
+				j++
+			}
+			word := s[i:j]
+			switch word {
+			case "and", "or", "in":
+				toks = append(toks, markerTok{kind: word})
+			case "not":
+				toks = append(toks, markerTok{kind: "not"})
+			default:
+				toks = append(toks, markerTok{kind: "ident", val: word})
+			}
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q in marker %q", c, s)
+		}
+	}
+	return toks, nil
+}
+
+type markerParser struct {
+	toks  []markerTok
+	pos   int
+	env   markerEnv
+	extra string
+}
+
+func (p *markerParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos].kind
+	}
+	return ""
+}
+
+func (p *markerParser) parseOr() (bool, error) {
+	v, err := p.parseAnd()
+	if err != nil {
+		return false, err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		r, err := p.parseAnd()
+		if err != nil {
+			return false, err
+		}
+		v = v || r
+	}
+	return v, nil
+}
+
+func (p *markerParser) parseAnd() (bool, error) {
+	v, err := p.parseAtom()
+	if err != nil {
+		return false, err
+	}
+	for p.peek() == "and" {
+		p.pos++
+		r, err := p.parseAtom()
+		if err != nil {
+			return false, err
+		}
+		v = v && r
+	}
+	return v, nil
+}
+
+func (p *markerParser) parseAtom() (bool, error) {
+	if p.peek() == "(" {
+		p.pos++
+		v, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if p.peek() != ")" {
+			return false, fmt.Errorf("missing ')' in marker")
+		}
+		p.pos++
+		return v, nil
+	}
+	lhs, lname, err := p.parseValue()
+	if err != nil {
+		return false, err
+	}
+	var op string
+	switch p.peek() {
+	case "op":
+		op = p.toks[p.pos].val
+		p.pos++
+	case "in":
+		op = "in"
+		p.pos++
+	case "not":
+		p.pos++
+		if p.peek() != "in" {
+			return false, fmt.Errorf("expected 'in' after 'not' in marker")
+		}
+		p.pos++
+		op = "not in"
+	default:
+		return false, fmt.Errorf("expected comparison operator in marker")
+	}
+	rhs, rname, err := p.parseValue()
+	if err != nil {
+		return false, err
+	}
+	if lname == "extra" || rname == "extra" {
+		lhs, rhs = normalizePkgName(lhs), normalizePkgName(rhs)
+	}
+	if versionMarkers[lname] || versionMarkers[rname] {
+		if ok, handled := compareMarkerVersions(lhs, op, rhs); handled {
+			return ok, nil
+		}
+	}
+	switch op {
+	case "==", "===":
+		return lhs == rhs, nil
+	case "!=":
+		return lhs != rhs, nil
+	case "in":
+		return strings.Contains(rhs, lhs), nil
+	case "not in":
+		return !strings.Contains(rhs, lhs), nil
+	case "<":
+		return lhs < rhs, nil
+	case "<=":
+		return lhs <= rhs, nil
+	case ">":
+		return lhs > rhs, nil
+	case ">=":
+		return lhs >= rhs, nil
+	}
+	return false, fmt.Errorf("unsupported marker operator %q", op)
+}
+
+// parseValue returns a literal or variable value plus the variable name
+// (empty for literals).
+func (p *markerParser) parseValue() (string, string, error) {
+	if p.pos >= len(p.toks) {
+		return "", "", fmt.Errorf("unexpected end of marker")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	switch t.kind {
+	case "str":
+		return t.val, "", nil
+	case "ident":
+		if t.val == "extra" {
+			return p.extra, "extra", nil
+		}
+		v, ok := p.env[t.val]
+		if !ok {
+			return "", "", fmt.Errorf("unknown marker variable %q", t.val)
+		}
+		return v, t.val, nil
+	}
+	return "", "", fmt.Errorf("unexpected token in marker")
+}
+
+// compareMarkerVersions applies lhs <op> rhs as a PEP 440 specifier; handled
+// is false when either side is not a version, so the caller falls back to a
+// string comparison.
+func compareMarkerVersions(lhs, op, rhs string) (ok, handled bool) {
+	if op == "in" || op == "not in" {
+		return false, false
+	}
+	v, err := parseVersion(lhs)
+	if err != nil {
+		return false, false
+	}
+	set, err := parseSpecifierSet(op + rhs)
+	if err != nil {
+		return false, false
+	}
+	return set.Contains(v), true
+}
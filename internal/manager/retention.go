@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// RetentionPolicy bounds how many snapshots a venv keeps. Zero fields mean
+// "no limit". The newest snapshot is always kept, so there is always
+// something to roll back to.
+type RetentionPolicy struct {
+	// KeepLast keeps at most this many snapshots.
+	KeepLast int `json:"keep_last,omitempty"`
+	// MaxAgeDays drops snapshots older than this many days.
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+}
+
+// IsZero reports whether the policy never removes anything.
+func (p RetentionPolicy) IsZero() bool { return p.KeepLast <= 0 && p.MaxAgeDays <= 0 }
+
+// AutoSnapshot takes a snapshot labelled with operation before it mutates
+// the venv, when automatic snapshots are enabled, then applies the retention
+// policy. It returns nil (and no error) when automatic snapshots are off.
+func (m *Manager) AutoSnapshot(name, operation string) (*Snapshot, error) {
+	if !m.autoSnapshot {
+		return nil, nil
+	}
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := m.lock(name, operation)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return m.autoSnapshotLocked(venvPath, name, operation)
+}
+
+// AutoSnapshotLocked is AutoSnapshot for a caller that already holds the
+// venv's lock, see LockVenv.
+func (m *Manager) AutoSnapshotLocked(name, operation string) (*Snapshot, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.autoSnapshotLocked(venvPath, name, operation)
+}
+
+func (m *Manager) autoSnapshotLocked(venvPath, name, operation string) (*Snapshot, error) {
+	snap, err := m.autoSnapshotAt(venvPath, name, operation)
+	if err != nil || snap == nil {
+		return nil, err
+	}
+	if !m.retention.IsZero() {
+		// Best effort: a failed prune must not block the operation itself.
+		_, _ = m.pruneSnapshotsLocked(venvPath, name, m.retention)
+	}
+	return snap, nil
+}
+
+// autoSnapshotAt is AutoSnapshot for a venv that may still be staged, so
+// without retention: a venv being built has no older snapshots.
+func (m *Manager) autoSnapshotAt(venvPath, name, operation string) (*Snapshot, error) {
+	if !m.autoSnapshot {
+		return nil, nil
+	}
+	snap, err := m.snapshotAt(venvPath, name, SnapshotOptions{Label: operation, Operation: operation})
+	if err != nil {
+		return nil, fmt.Errorf("pre-%s snapshot failed: %v", operation, err)
+	}
+	return snap, nil
+}
+
+// RetentionPolicy returns the policy configured for automatic pruning.
+func (m *Manager) RetentionPolicy() RetentionPolicy { return m.retention }
+
+// PruneSnapshots deletes the snapshots of a venv that fall outside policy
+// and returns them. With dryRun, nothing is deleted.
+func (m *Manager) PruneSnapshots(name string, policy RetentionPolicy, dryRun bool) ([]Snapshot, error) {
+	if dryRun {
+		snaps, err := m.ListSnapshots(name)
+		if err != nil {
+			return nil, err
+		}
+		return selectExpired(snaps, policy, time.Now()), nil
+	}
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := m.lock(name, "snapshot prune")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return m.pruneSnapshotsLocked(venvPath, name, policy)
+}
+
+func (m *Manager) pruneSnapshotsLocked(venvPath, name string, policy RetentionPolicy) ([]Snapshot, error) {
+	snaps, err := m.ListSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+	expired := selectExpired(snaps, policy, time.Now())
+	for _, s := range expired {
+		if err := deleteSnapshotFiles(venvPath, s.ID); err != nil {
+			return nil, fmt.Errorf("failed to delete snapshot %s: %v", filepath.Base(s.Path), err)
+		}
+	}
+	return expired, nil
+}
+
+// selectExpired returns the snapshots (newest first, as ListSnapshots
+// orders them) that policy says to drop.
+func selectExpired(snaps []Snapshot, policy RetentionPolicy, now time.Time) []Snapshot {
+	expired := []Snapshot{}
+	cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+	for i, s := range snaps {
+		if i == 0 {
+			continue
+		}
+		if (policy.KeepLast > 0 && i >= policy.KeepLast) || (policy.MaxAgeDays > 0 && s.CreatedAt.Before(cutoff)) {
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
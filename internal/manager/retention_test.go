@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectExpired(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var snaps []Snapshot
+	for i, age := range []int{0, 1, 5, 20, 40} {
+		snaps = append(snaps, Snapshot{ID: string(rune('a' + i)), CreatedAt: now.AddDate(0, 0, -age)})
+	}
+	ids := func(ss []Snapshot) string {
+		var out []string
+		for _, s := range ss {
+			out = append(out, s.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	cases := []struct {
+		policy RetentionPolicy
+		want   string
+	}{
+		{RetentionPolicy{}, ""},
+		{RetentionPolicy{KeepLast: 2}, "c,d,e"},
+		{RetentionPolicy{MaxAgeDays: 10}, "d,e"},
+		{RetentionPolicy{KeepLast: 4, MaxAgeDays: 30}, "e"},
+		// The newest snapshot survives even when it is too old.
+		{RetentionPolicy{KeepLast: 1, MaxAgeDays: 1}, "b,c,d,e"},
+	}
+	for _, c := range cases {
+		if got := ids(selectExpired(snaps, c.policy, now)); got != c.want {
+			t.Errorf("%+v: expired=%q want %q", c.policy, got, c.want)
+		}
+	}
+	old := []Snapshot{{ID: "only", CreatedAt: now.AddDate(-1, 0, 0)}}
+	if got := selectExpired(old, RetentionPolicy{MaxAgeDays: 1}, now); len(got) != 0 {
+		t.Fatalf("sole snapshot must be kept, got %v", got)
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	m, dir := newTestMgr(t)
+	venv := filepath.Join(dir, "v")
+	os.MkdirAll(venv, 0o755)
+	base := time.Now().UTC().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		snap := &Snapshot{Venv: "v", CreatedAt: base.Add(time.Duration(i) * time.Minute), Operation: "install"}
+		if err := saveSnapshot(venv, snap, []byte("a==1\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pruned, err := m.PruneSnapshots("v", RetentionPolicy{KeepLast: 3}, true)
+	if err != nil || len(pruned) != 1 {
+		t.Fatalf("dry run: %v %v", pruned, err)
+	}
+	if snaps, _ := m.ListSnapshots("v"); len(snaps) != 4 {
+		t.Fatalf("dry run deleted snapshots: %d left", len(snaps))
+	}
+	pruned, err = m.PruneSnapshots("v", RetentionPolicy{KeepLast: 2}, false)
+	if err != nil || len(pruned) != 2 {
+		t.Fatalf("prune: %v %v", pruned, err)
+	}
+	snaps, _ := m.ListSnapshots("v")
+	if len(snaps) != 2 || !snaps[1].CreatedAt.After(pruned[0].CreatedAt) {
+		t.Fatalf("wrong snapshots kept: %+v", snaps)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(pruned[0].Path, ".txt") + ".json"); !os.IsNotExist(err) {
+		t.Fatal("sidecar of pruned snapshot left behind")
+	}
+}
+
+func TestAutoSnapshotDisabled(t *testing.T) {
+	m, dir := newTestMgr(t)
+	os.MkdirAll(filepath.Join(dir, "v"), 0o755)
+	snap, err := m.AutoSnapshot("v", "install")
+	if snap != nil || err != nil {
+		t.Fatalf("disabled auto snapshot returned %v, %v", snap, err)
+	}
+}
@@ -0,0 +1,375 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// RollbackPlan is the delta that restores a venv to a snapshot: packages to
+// uninstall because the snapshot doesn't have them, and pins to (re)install
+// because they are missing or at a different version.
+type RollbackPlan struct {
+	Venv     string          `json:"venv"`
+	Snapshot *Snapshot       `json:"snapshot"`
+	Remove   []PackageChange `json:"remove"`
+	Install  []PackageChange `json:"install"`
+}
+
+// Empty reports whether the venv already matches the snapshot.
+func (p *RollbackPlan) Empty() bool { return len(p.Remove) == 0 && len(p.Install) == 0 }
+
+// PlanRollback computes the rollback delta without touching the venv. If
+// snapshotID is empty, the most recent snapshot is used.
+func (m *Manager) PlanRollback(name, snapshotID string) (*RollbackPlan, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	target, err := m.findSnapshot(name, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := m.pipFreeze(venvPath)
+	if err != nil {
+		return nil, err
+	}
+	want, err := os.ReadFile(target.Path)
+	if err != nil {
+		return nil, err
+	}
+	plan := &RollbackPlan{Venv: name, Snapshot: target}
+	plan.Remove, plan.Install = planDelta(cur, parseFreeze(strings.Split(string(want), "\n")))
+	return plan, nil
+}
+
+// Rollback restores a venv to a snapshot by applying only the delta between
+// the current freeze and the snapshot, or by restoring archived files for
+// full snapshots. If snapshotID is empty, uses the most recent snapshot. When
+// the restore fails, the venv is returned to its pre-rollback state from a
+// file-level backup, without pip or the network. Returns the snapshot that
+// was restored.
+func (m *Manager) Rollback(name, snapshotID string) (*Snapshot, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := m.lock(name, "rollback")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return m.rollbackLocked(venvPath, name, snapshotID)
+}
+
+func (m *Manager) rollbackLocked(venvPath, name, snapshotID string) (*Snapshot, error) {
+	target, err := m.findSnapshot(name, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	// Taken after resolving the target, so "newest" still means the one the
+	// caller had in mind.
+	if _, err := m.autoSnapshotLocked(venvPath, name, "rollback"); err != nil {
+		return nil, err
+	}
+	// Full snapshots restore files directly: no pip, no index, no network.
+	// Files built for another Python version would mix two interpreters in
+	// one venv (say after repython), so those fall back to the freeze delta.
+	if target.Full && samePythonMinor(target.PythonVersion, venvPythonVersion(venvPath)) {
+		if err := restoreFull(venvPath, target); err != nil {
+			return nil, fmt.Errorf("rollback failed, venv left as it was: %v", err)
+		}
+		return target, nil
+	}
+	plan, err := m.PlanRollback(name, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Empty() {
+		return plan.Snapshot, nil
+	}
+	if err := m.applyDeltaWithBackup(venvPath, plan); err != nil {
+		return nil, err
+	}
+	return plan.Snapshot, nil
+}
+
+// applyDeltaWithBackup applies a rollback plan so that a failure leaves the
+// venv as it was, undone from files rather than through pip or the index,
+// which may be exactly what just failed. The RECORD-listed files of the dists
+// the plan removes or replaces are moved aside first; moving a removed dist
+// out is its uninstall. When one of them has no RECORD, site-packages and
+// bin are archived whole instead.
+func (m *Manager) applyDeltaWithBackup(venvPath string, plan *RollbackPlan) error {
+	pkgs, err := readInstalled(venvPath)
+	if err != nil {
+		return err
+	}
+	installed := map[string]bool{}
+	for _, p := range pkgs {
+		installed[normalizePkgName(p.Name)] = true
+	}
+	// Dists pip lists but whose metadata isn't in site-packages, such as
+	// legacy develop installs, are still left to pip to uninstall.
+	touched := map[string]bool{}
+	var uninstall []PackageChange
+	for _, r := range plan.Remove {
+		touched[normalizePkgName(r.Name)] = true
+		if !installed[normalizePkgName(r.Name)] {
+			uninstall = append(uninstall, r)
+		}
+	}
+	installing := map[string]bool{}
+	for _, i := range plan.Install {
+		installing[normalizePkgName(i.Name)] = true
+		if i.From != "" {
+			touched[normalizePkgName(i.Name)] = true
+		}
+	}
+	files, ok := distFiles(venvPath, pkgs, touched)
+	if !ok {
+		backup, err := takeFileBackup(venvPath)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(filepath.Dir(backup.Path))
+		if err := m.applyDelta(venvPath, plan.Remove, plan.Install); err != nil {
+			if rerr := restoreFull(venvPath, backup); rerr != nil {
+				return fmt.Errorf("rollback failed: %v\nrestoring the pre-rollback state also failed: %v", err, rerr)
+			}
+			return fmt.Errorf("rollback failed, venv left as it was: %v", err)
+		}
+		return nil
+	}
+
+	b := &fileBackup{venvPath: venvPath, dir: filepath.Join(venvPath, ".venv-manager", "rollback-backup")}
+	if err := os.RemoveAll(b.dir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(b.dir)
+	if err := b.moveAside(files); err != nil {
+		if rerr := b.restore(nil); rerr != nil {
+			return fmt.Errorf("failed to back up the venv before rollback: %v\nrestoring the moved files also failed: %v", err, rerr)
+		}
+		return fmt.Errorf("failed to back up the venv before rollback: %v", err)
+	}
+	if err := m.applyDelta(venvPath, uninstall, plan.Install); err != nil {
+		if rerr := b.restore(installing); rerr != nil {
+			return fmt.Errorf("rollback failed: %v\nrestoring the pre-rollback state also failed: %v", err, rerr)
+		}
+		return fmt.Errorf("rollback failed, venv left as it was: %v", err)
+	}
+	return nil
+}
+
+// distFiles returns the dist-info dirs and RECORD-listed files inside
+// venvPath of the installed dists named in names. ok is false when one of
+// them has no RECORD, so its files can't be known.
+func distFiles(venvPath string, pkgs []InstalledPackage, names map[string]bool) (files []string, ok bool) {
+	for _, p := range pkgs {
+		if !names[normalizePkgName(p.Name)] {
+			continue
+		}
+		if !strings.HasSuffix(p.MetadataPath, ".dist-info") {
+			return nil, false
+		}
+		record, err := readRecord(p.MetadataPath)
+		if err != nil {
+			return nil, false
+		}
+		files = append(files, p.MetadataPath)
+		sp := filepath.Dir(p.MetadataPath)
+		for _, rec := range record {
+			f := filepath.Join(sp, filepath.FromSlash(rec))
+			if rel, err := filepath.Rel(venvPath, f); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			if !strings.HasPrefix(f, p.MetadataPath+string(filepath.Separator)) {
+				files = append(files, f)
+			}
+		}
+	}
+	return files, true
+}
+
+// fileBackup holds files moved out of a venv, under dir at their path
+// relative to the venv.
+type fileBackup struct {
+	venvPath string
+	dir      string
+	moved    []string
+}
+
+// moveAside moves files into the backup, then removes the directories that
+// moving them emptied, so a removed package doesn't linger as an empty,
+// importable namespace dir.
+func (b *fileBackup) moveAside(files []string) error {
+	for _, f := range files {
+		if _, err := os.Lstat(f); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(b.venvPath, f)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(b.dir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(f, dst); err != nil {
+			return fmt.Errorf("failed to move %s aside: %v", rel, err)
+		}
+		b.moved = append(b.moved, rel)
+	}
+	keep := map[string]bool{b.venvPath: true, utils.VenvBinDir(b.venvPath): true}
+	for _, sp := range sitePackagesDirs(b.venvPath) {
+		keep[sp] = true
+	}
+	for _, rel := range b.moved {
+		d := filepath.Dir(filepath.Join(b.venvPath, rel))
+		for !keep[d] && os.Remove(d) == nil {
+			d = filepath.Dir(d)
+		}
+	}
+	return nil
+}
+
+// restore moves the backed-up files back. The dists named in discard are
+// deleted first, so a half-finished install of a new version doesn't sit
+// next to the restored one.
+func (b *fileBackup) restore(discard map[string]bool) error {
+	var errs []string
+	if len(discard) > 0 {
+		pkgs, _ := readInstalled(b.venvPath)
+		for _, p := range pkgs {
+			if !discard[normalizePkgName(p.Name)] {
+				continue
+			}
+			files, _ := distFiles(b.venvPath, []InstalledPackage{p}, discard)
+			if files == nil {
+				files = []string{p.MetadataPath}
+			}
+			for _, f := range files {
+				if err := os.RemoveAll(f); err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+		}
+	}
+	for _, rel := range b.moved {
+		live := filepath.Join(b.venvPath, rel)
+		if err := os.RemoveAll(live); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(live), 0o755); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := os.Rename(filepath.Join(b.dir, rel), live); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// takeFileBackup archives a venv's site-packages and bin dir, like a full
+// snapshot, into a scratch directory inside the venv that the caller
+// removes.
+func takeFileBackup(venvPath string) (*Snapshot, error) {
+	dir := filepath.Join(venvPath, ".venv-manager", "rollback-backup")
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{ID: "pre-rollback", Path: filepath.Join(dir, "pre-rollback.txt"), VenvPath: venvPath}
+	if err := writeFullArchive(venvPath, snap); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to back up the venv before rollback: %v", err)
+	}
+	return snap, nil
+}
+
+// samePythonMinor reports whether two Python versions share major.minor.
+// An unknown version is assumed to match.
+func samePythonMinor(a, b string) bool {
+	return a == "" || b == "" || majorMinor(a) == majorMinor(b)
+}
+
+// planDelta returns what must change to turn cur into want.
+func planDelta(cur, want map[string]freezeEntry) (remove, install []PackageChange) {
+	remove, install = []PackageChange{}, []PackageChange{}
+	for _, k := range sortedKeys(keySet(cur)) {
+		if _, ok := want[k]; !ok {
+			remove = append(remove, PackageChange{Name: cur[k].Name, From: cur[k].Version})
+		}
+	}
+	for _, k := range sortedKeys(keySet(want)) {
+		w := want[k]
+		c, ok := cur[k]
+		if ok && c.Version == w.Version {
+			continue
+		}
+		ch := PackageChange{Name: w.Name, To: w.requirement()}
+		if ok {
+			ch.From = c.Version
+		}
+		install = append(install, ch)
+	}
+	return remove, install
+}
+
+// applyDelta installs the install pins, then uninstalls remove: a failure
+// part way, such as the index going away, then leaves packages added rather
+// than lost. Installs use --no-deps: the target is a complete, consistent
+// freeze, so resolving dependencies could only drift away from it.
+func (m *Manager) applyDelta(venvPath string, remove, install []PackageChange) error {
+	if len(install) > 0 {
+		tmp, err := os.CreateTemp("", "vm-rollback-*.txt")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		for _, i := range install {
+			fmt.Fprintln(tmp, i.To)
+		}
+		tmp.Close()
+		if out, err := m.runInstall(venvPath, "--no-deps", "-r", tmp.Name()); err != nil {
+			return fmt.Errorf("install failed: %v\n%s", err, out)
+		}
+	}
+	if len(remove) > 0 {
+		names := make([]string, len(remove))
+		for i, r := range remove {
+			names[i] = r.Name
+		}
+		if out, err := m.pkg(venvPath).uninstall(names...).CombinedOutput(); err != nil {
+			return fmt.Errorf("uninstall failed: %v\n%s", err, out)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) pipFreeze(venvPath string) (map[string]freezeEntry, error) {
+	out, err := m.pkg(venvPath).freeze().Output()
+	if err != nil {
+		return nil, fmt.Errorf("pip freeze failed: %v", err)
+	}
+	return parseFreeze(strings.Split(string(out), "\n")), nil
+}
+
+func keySet[V any](m map[string]V) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}
@@ -0,0 +1,373 @@
+package manager
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// slimDirs are directories inside installed packages that are not needed at
+// runtime. Only directories nested in a package are considered, never a
+// top-level import name.
+var slimDirs = map[string]bool{
+	"tests": true, "test": true,
+	"docs": true, "doc": true,
+	"examples": true, "example": true,
+}
+
+// distInfoRuntime are the .dist-info files read at runtime or by pip
+// (importlib.metadata, entry points, uninstall). Everything else in a
+// .dist-info dir (licenses, AUTHORS, DESCRIPTION.rst, ...) can go.
+var distInfoRuntime = map[string]bool{
+	"METADATA": true, "RECORD": true, "INSTALLER": true, "WHEEL": true,
+	"REQUESTED": true, "direct_url.json": true, "entry_points.txt": true,
+	"top_level.txt": true, "namespace_packages.txt": true,
+}
+
+// unownedPackage attributes files no RECORD claims.
+const unownedPackage = "(unowned)"
+
+// PackageBytes is what slim mode removed (or would remove) from one package.
+type PackageBytes struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	// Paths are relative to site-packages.
+	Paths []string `json:"paths"`
+}
+
+// distLayout is what the .dist-info dirs of one site-packages say about the
+// files installed there.
+type distLayout struct {
+	// owners maps each RECORD path, and every directory above one, to the
+	// dists that installed files there. Namespace package dirs such as
+	// google/ have several.
+	owners map[string]map[string]bool
+	// required holds paths that must survive: entry point modules and
+	// top_level.txt names.
+	required []string
+	// metaJunk maps dists to their removable .dist-info files.
+	metaJunk map[string][]string
+}
+
+// slimSitePackages finds what slim mode removes from one site-packages dir:
+// test/doc/example dirs of RECORD-owned packages, stale bytecode and
+// .dist-info files not needed at runtime. Packages in keep are skipped.
+// pycTag is the interpreter's cache tag (e.g. "cpython-312"); empty skips the
+// tag check.
+func slimSitePackages(sp, pycTag string, keep map[string]bool) (map[string]*PackageBytes, error) {
+	layout := readDistLayout(sp)
+	found := map[string]*PackageBytes{}
+	add := func(owner, rel string, size int64) {
+		pb := found[owner]
+		if pb == nil {
+			pb = &PackageBytes{Name: owner}
+			found[owner] = pb
+		}
+		pb.Bytes += size
+		pb.Paths = append(pb.Paths, rel)
+	}
+	for dist, files := range layout.metaJunk {
+		if keep[normalizePkgName(dist)] {
+			continue
+		}
+		for _, rel := range files {
+			add(dist, rel, pathSize(filepath.Join(sp, rel)))
+		}
+	}
+	err := filepath.Walk(sp, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(sp, p)
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		top, _, nested := strings.Cut(rel, "/")
+		if info.IsDir() && (strings.HasSuffix(top, ".dist-info") || strings.HasSuffix(top, ".egg-info")) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() && nested && slimDirs[info.Name()] {
+			// A dir shared by several dists is left alone: part of it
+			// belongs to a package that may need it.
+			owners := layout.ownersOf(rel)
+			if len(owners) != 1 || anyKept(owners, keep) || layout.isRequired(rel) {
+				return nil
+			}
+			add(owners[0], rel, pathSize(p))
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && filepath.Base(filepath.Dir(p)) == "__pycache__" && strings.HasSuffix(p, ".pyc") && stalePyc(p, pycTag) {
+			// "pkg/__pycache__/mod.cpython-311.pyc" belongs with "pkg/mod.py".
+			src := path.Join(path.Dir(path.Dir(rel)), strings.SplitN(info.Name(), ".", 2)[0]+".py")
+			owners := layout.ownersOf(src)
+			if anyKept(owners, keep) {
+				return nil
+			}
+			owner := unownedPackage
+			if len(owners) > 0 {
+				owner = owners[0]
+			}
+			add(owner, rel, info.Size())
+		}
+		return nil
+	})
+	return found, err
+}
+
+// readDistLayout reads RECORD, top_level.txt and entry_points.txt of every
+// .dist-info dir in sp.
+func readDistLayout(sp string) *distLayout {
+	l := &distLayout{owners: map[string]map[string]bool{}, metaJunk: map[string][]string{}}
+	infos, _ := filepath.Glob(filepath.Join(sp, "*.dist-info"))
+	for _, di := range infos {
+		pkg, err := readDistInfo(di)
+		if err != nil {
+			continue
+		}
+		record, _ := readRecord(di)
+		for _, rec := range record {
+			top := strings.SplitN(rec, "/", 2)[0]
+			if top == "" || top == ".." || top == "__pycache__" || strings.HasSuffix(top, ".dist-info") {
+				continue
+			}
+			for p := path.Clean(rec); p != "."; p = path.Dir(p) {
+				if l.owners[p] == nil {
+					l.owners[p] = map[string]bool{}
+				}
+				l.owners[p][pkg.Name] = true
+			}
+		}
+		for _, name := range readLines(filepath.Join(di, "top_level.txt")) {
+			l.required = append(l.required, strings.ReplaceAll(name, ".", "/"))
+		}
+		for _, ln := range readLines(filepath.Join(di, "entry_points.txt")) {
+			// "name = pkg.module:attr [extra]"
+			_, target, ok := strings.Cut(ln, "=")
+			if !ok {
+				continue
+			}
+			mod := strings.TrimSpace(strings.SplitN(target, ":", 2)[0])
+			if mod != "" {
+				l.required = append(l.required, strings.ReplaceAll(mod, ".", "/"))
+			}
+		}
+		entries, _ := os.ReadDir(di)
+		for _, e := range entries {
+			if !distInfoRuntime[e.Name()] {
+				l.metaJunk[pkg.Name] = append(l.metaJunk[pkg.Name], filepath.Base(di)+"/"+e.Name())
+			}
+		}
+	}
+	return l
+}
+
+// ownersOf returns the dists owning rel (slash-separated, relative to
+// site-packages), sorted. A path no RECORD lists, such as a test dir left out
+// of it, belongs to the owners of its nearest listed parent.
+func (l *distLayout) ownersOf(rel string) []string {
+	for p := path.Clean(rel); p != "."; p = path.Dir(p) {
+		if o := l.owners[p]; len(o) > 0 {
+			return sortedKeys(o)
+		}
+	}
+	return nil
+}
+
+func anyKept(owners []string, keep map[string]bool) bool {
+	for _, o := range owners {
+		if keep[normalizePkgName(o)] {
+			return true
+		}
+	}
+	return false
+}
+
+// isRequired reports whether removing dir (relative, slash-separated) would
+// remove a required module.
+func (l *distLayout) isRequired(dir string) bool {
+	for _, r := range l.required {
+		if r == dir || strings.HasPrefix(r, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// stalePyc reports whether a __pycache__ file can never be used: it targets
+// another interpreter, its source is gone, or the source changed since it
+// was compiled. Hash-based pycs (PEP 552) are kept.
+func stalePyc(path, tag string) bool {
+	parts := strings.SplitN(filepath.Base(path), ".", 3)
+	if len(parts) < 3 {
+		return false
+	}
+	if tag != "" && parts[1] != tag {
+		return true
+	}
+	src, err := os.Stat(filepath.Join(filepath.Dir(filepath.Dir(path)), parts[0]+".py"))
+	if err != nil {
+		return true
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var hdr [16]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return true
+	}
+	if binary.LittleEndian.Uint32(hdr[4:8]) != 0 {
+		return false
+	}
+	mtime, size := binary.LittleEndian.Uint32(hdr[8:12]), binary.LittleEndian.Uint32(hdr[12:16])
+	return mtime != uint32(src.ModTime().Unix()) || size != uint32(src.Size())
+}
+
+// pycCacheTag returns sys.implementation.cache_tag for a venv's interpreter.
+func pycCacheTag(venvPath string) string {
+	env := markerEnvFor(venvPath)
+	ver := strings.ReplaceAll(env["python_version"], ".", "")
+	if ver == "" {
+		return ""
+	}
+	if env["implementation_name"] == "pypy" {
+		return "pypy" + ver
+	}
+	return "cpython-" + ver
+}
+
+// slimVenv removes (or with dryRun, only measures) what slim mode targets in
+// every site-packages dir of a venv. Results are sorted by bytes, largest
+// first.
+func slimVenv(venvPath string, dryRun bool, keep map[string]bool) ([]PackageBytes, error) {
+	tag := pycCacheTag(venvPath)
+	merged := map[string]*PackageBytes{}
+	for _, sp := range sitePackagesDirs(venvPath) {
+		found, err := slimSitePackages(sp, tag, keep)
+		if err != nil {
+			return nil, err
+		}
+		var removed []string
+		for name, pb := range found {
+			if !dryRun {
+				for _, rel := range pb.Paths {
+					if err := os.RemoveAll(filepath.Join(sp, filepath.FromSlash(rel))); err != nil {
+						return nil, err
+					}
+				}
+				removed = append(removed, pb.Paths...)
+			}
+			if m := merged[name]; m != nil {
+				m.Bytes += pb.Bytes
+				m.Paths = append(m.Paths, pb.Paths...)
+			} else {
+				merged[name] = pb
+			}
+		}
+		if err := pruneRecords(sp, removed); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]PackageBytes, 0, len(merged))
+	for _, pb := range merged {
+		sort.Strings(pb.Paths)
+		out = append(out, *pb)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bytes != out[j].Bytes {
+			return out[i].Bytes > out[j].Bytes
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// pruneRecords drops the rows of removed paths (and of files under removed
+// dirs) from every RECORD in sp, so pip's uninstall and `pip check` see the
+// files that are actually there.
+func pruneRecords(sp string, removed []string) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	gone := map[string]bool{}
+	for _, rel := range removed {
+		gone[rel] = true
+	}
+	isGone := func(rel string) bool {
+		for p := path.Clean(rel); p != "."; p = path.Dir(p) {
+			if gone[p] {
+				return true
+			}
+		}
+		return false
+	}
+	infos, _ := filepath.Glob(filepath.Join(sp, "*.dist-info"))
+	for _, di := range infos {
+		record := filepath.Join(di, "RECORD")
+		f, err := os.Open(record)
+		if err != nil {
+			continue
+		}
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		rows, err := r.ReadAll()
+		f.Close()
+		if err != nil {
+			continue
+		}
+		kept := rows[:0]
+		for _, row := range rows {
+			if len(row) == 0 || !isGone(filepath.ToSlash(row[0])) {
+				kept = append(kept, row)
+			}
+		}
+		if len(kept) == len(rows) {
+			continue
+		}
+		var b strings.Builder
+		w := csv.NewWriter(&b)
+		w.WriteAll(kept)
+		if err := w.Error(); err != nil {
+			return err
+		}
+		if err := os.WriteFile(record, []byte(b.String()), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pathSize(p string) int64 {
+	var size int64
+	filepath.Walk(p, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func readLines(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if ln := strings.TrimSpace(sc.Text()); ln != "" && !strings.HasPrefix(ln, "[") && !strings.HasPrefix(ln, "#") {
+			out = append(out, ln)
+		}
+	}
+	return out
+}
@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePyc writes a timestamp-based pyc for src into __pycache__ with the
+// given mtime/size recorded in its header.
+func writePyc(t *testing.T, src, tag string, mtime time.Time, size int64) string {
+	t.Helper()
+	dir := filepath.Join(filepath.Dir(src), "__pycache__")
+	os.MkdirAll(dir, 0o755)
+	hdr := make([]byte, 16)
+	copy(hdr, []byte{0xa7, 0x0d, 0x0d, 0x0a})
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(mtime.Unix()))
+	binary.LittleEndian.PutUint32(hdr[12:16], uint32(size))
+	p := filepath.Join(dir, strings.TrimSuffix(filepath.Base(src), ".py")+"."+tag+".pyc")
+	if err := os.WriteFile(p, append(hdr, "code"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestStalePyc(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "mod.py")
+	os.WriteFile(src, []byte("x = 1\n"), 0o644)
+	st, _ := os.Stat(src)
+
+	fresh := writePyc(t, src, "cpython-312", st.ModTime(), st.Size())
+	if stalePyc(fresh, "cpython-312") {
+		t.Error("matching pyc reported stale")
+	}
+	if !stalePyc(fresh, "cpython-311") {
+		t.Error("pyc for another interpreter should be stale")
+	}
+	changed := writePyc(t, src, "cpython-312", st.ModTime().Add(-time.Hour), st.Size())
+	if !stalePyc(changed, "cpython-312") {
+		t.Error("pyc older than its source should be stale")
+	}
+	orphan := writePyc(t, filepath.Join(dir, "gone.py"), "cpython-312", st.ModTime(), 1)
+	if !stalePyc(orphan, "cpython-312") {
+		t.Error("pyc without source should be stale")
+	}
+	hashed := filepath.Join(dir, "__pycache__", "mod.cpython-312.opt-1.pyc")
+	hdr := make([]byte, 16)
+	binary.LittleEndian.PutUint32(hdr[4:8], 1)
+	os.WriteFile(hashed, hdr, 0o644)
+	if stalePyc(hashed, "cpython-312") {
+		t.Error("hash-based pyc must be kept")
+	}
+}
+
+func TestSlimVenv(t *testing.T) {
+	dir := t.TempDir()
+	sp := fakeSitePackages(t, dir)
+	venv := filepath.Join(dir, "v")
+	os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte("version = 3.12.4\n"), 0o644)
+
+	writeDistInfo(t, sp, "pkg", "1.0", nil, true)
+	di := filepath.Join(sp, "pkg-1.0.dist-info")
+	os.WriteFile(filepath.Join(di, "RECORD"), []byte("pkg/__init__.py,,\npkg-1.0.dist-info/METADATA,,\n"), 0o644)
+	os.WriteFile(filepath.Join(di, "LICENSE"), []byte(strings.Repeat("l", 100)), 0o644)
+	os.WriteFile(filepath.Join(di, "entry_points.txt"), []byte("[console_scripts]\npkg-selftest = pkg.tests.cli:main\n"), 0o644)
+	for _, d := range []string{"pkg/tests", "pkg/sub/docs", "pkg/sub/examples", "tests"} {
+		os.MkdirAll(filepath.Join(sp, d), 0o755)
+		os.WriteFile(filepath.Join(sp, d, "f.py"), []byte(strings.Repeat("x", 10)), 0o644)
+	}
+	os.WriteFile(filepath.Join(sp, "pkg", "__init__.py"), nil, 0o644)
+	stale := writePyc(t, filepath.Join(sp, "pkg", "__init__.py"), "cpython-311", time.Now(), 0)
+
+	writeDistInfo(t, sp, "other", "2.0", nil, false)
+	os.WriteFile(filepath.Join(sp, "other-2.0.dist-info", "RECORD"), []byte("other/__init__.py,,\n"), 0o644)
+	os.MkdirAll(filepath.Join(sp, "other", "tests"), 0o755)
+	os.WriteFile(filepath.Join(sp, "other", "tests", "t.py"), []byte("t"), 0o644)
+
+	got, err := slimVenv(venv, true, map[string]bool{"other": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "pkg" {
+		t.Fatalf("expected only pkg, got %+v", got)
+	}
+	paths := strings.Join(got[0].Paths, ",")
+	if strings.Contains(paths, "entry_points") || strings.Contains(paths, "METADATA") || !strings.Contains(paths, "pkg-1.0.dist-info/LICENSE") {
+		t.Fatalf("dist-info: only non-runtime files may go, got %s", paths)
+	}
+	for _, p := range []string{"pkg/sub/docs", "pkg/sub/examples", "pkg/__pycache__/__init__.cpython-311.pyc"} {
+		if !strings.Contains(paths, p) {
+			t.Errorf("missing %s in %s", p, paths)
+		}
+	}
+	if strings.Contains(paths, "pkg/tests") {
+		t.Error("pkg/tests holds an entry point module and must be kept")
+	}
+	if strings.Contains(paths, ",tests") {
+		t.Error("top-level dirs must never be removed")
+	}
+	if got[0].Bytes != 100+10+10+int64(len("code"))+16 {
+		t.Errorf("bytes=%d", got[0].Bytes)
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Fatal("dry run deleted files")
+	}
+
+	if _, err := slimVenv(venv, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"pkg/sub/docs", "pkg-1.0.dist-info/LICENSE", "other/tests"} {
+		if _, err := os.Stat(filepath.Join(sp, p)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", p)
+		}
+	}
+	for _, p := range []string{"pkg/tests", "tests", "pkg-1.0.dist-info/METADATA", "pkg-1.0.dist-info/RECORD", "pkg/__init__.py"} {
+		if _, err := os.Stat(filepath.Join(sp, p)); err != nil {
+			t.Errorf("%s must survive: %v", p, err)
+		}
+	}
+}
+
+func TestSlimNamespacePackages(t *testing.T) {
+	dir := t.TempDir()
+	sp := fakeSitePackages(t, dir)
+	venv := filepath.Join(dir, "v")
+	os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte("version = 3.12.4\n"), 0o644)
+	// Two dists sharing the google/ namespace; google-b also has files in a
+	// docs dir google-a installed.
+	for _, d := range []struct{ name, sub string }{{"google-a", "a"}, {"google-b", "b"}} {
+		writeDistInfo(t, sp, d.name, "1.0", nil, true)
+		record := "google/" + d.sub + "/__init__.py,,\ngoogle/" + d.sub + "/tests/t.py,,\ngoogle/docs/" + d.sub + ".txt,,\n"
+		os.WriteFile(filepath.Join(sp, d.name+"-1.0.dist-info", "RECORD"), []byte(record), 0o644)
+		for _, f := range []string{d.sub + "/__init__.py", d.sub + "/tests/t.py", "docs/" + d.sub + ".txt"} {
+			os.MkdirAll(filepath.Dir(filepath.Join(sp, "google", f)), 0o755)
+			os.WriteFile(filepath.Join(sp, "google", f), []byte("x"), 0o644)
+		}
+	}
+
+	got, err := slimVenv(venv, false, map[string]bool{"google-a": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "google-b" || strings.Join(got[0].Paths, ",") != "google/b/tests" {
+		t.Fatalf("expected only google-b's tests, got %+v", got)
+	}
+	for _, p := range []string{"google/a/tests/t.py", "google/docs/a.txt", "google/docs/b.txt"} {
+		if _, err := os.Stat(filepath.Join(sp, p)); err != nil {
+			t.Errorf("%s must survive: %v", p, err)
+		}
+	}
+	b, _ := os.ReadFile(filepath.Join(sp, "google-b-1.0.dist-info", "RECORD"))
+	if strings.Contains(string(b), "tests") || !strings.Contains(string(b), "google/b/__init__.py") {
+		t.Fatalf("RECORD still lists removed files or lost others: %q", b)
+	}
+}
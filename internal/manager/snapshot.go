@@ -1,63 +1,159 @@
 package manager
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
-
-	"github.com/jacopobonomi/venv-manager/internal/utils"
 )
 
-// Snapshot is a captured pip-freeze state of a venv.
+// Snapshot is a captured pip-freeze state of a venv. Its metadata is stored
+// in a JSON sidecar (<id>.json) next to the freeze file (<id>.txt).
 type Snapshot struct {
-	ID           string    `json:"id"`
-	Label        string    `json:"label,omitempty"`
-	Venv         string    `json:"venv"`
-	CreatedAt    time.Time `json:"created_at"`
-	PackageCount int       `json:"package_count"`
-	Path         string    `json:"path"`
+	ID            string    `json:"id"`
+	Label         string    `json:"label,omitempty"`
+	Venv          string    `json:"venv"`
+	CreatedAt     time.Time `json:"created_at"`
+	PackageCount  int       `json:"package_count"`
+	PythonVersion string    `json:"python_version,omitempty"`
+	PipVersion    string    `json:"pip_version,omitempty"`
+	FreezeHash    string    `json:"freeze_hash,omitempty"`
+	// Platform is the host and interpreter the snapshot was taken on; nil
+	// for snapshots that predate it.
+	Platform *SnapshotPlatform `json:"platform,omitempty"`
+	// Operation that triggered the snapshot ("manual" for user requests).
+	Operation string `json:"operation,omitempty"`
+	// Migrated marks metadata reconstructed from a legacy .txt-only snapshot.
+	Migrated bool `json:"migrated,omitempty"`
+	// ImportedFrom is "[host:]venv/id" for snapshots brought in with
+	// ImportSnapshot.
+	ImportedFrom string `json:"imported_from,omitempty"`
+	// Full snapshots also archive site-packages and bin/ into <id>.tar.gz,
+	// so they can be restored without pip or network access.
+	Full         bool     `json:"full,omitempty"`
+	ArchiveRoots []string `json:"archive_roots,omitempty"`
+	ArchiveHash  string   `json:"archive_sha256,omitempty"`
+	ArchiveBytes int64    `json:"archive_bytes,omitempty"`
+	// VenvPath is where the venv lived when the snapshot was taken; a full
+	// restore relocates archived files from there.
+	VenvPath string `json:"venv_path,omitempty"`
+	Path     string `json:"path,omitempty"`
+}
+
+// SnapshotOptions configures CreateSnapshotWithOptions.
+type SnapshotOptions struct {
+	Label string
+	// Full archives site-packages and entry points for offline rollback.
+	Full bool
+	// Operation records what triggered the snapshot. Defaults to "manual".
+	Operation string
 }
 
+// snapshotIDLayout is the timestamp prefix of every snapshot ID.
+const snapshotIDLayout = "20060102-150405"
+
 func snapshotsDir(venvPath string) string {
 	return filepath.Join(venvPath, ".venv-manager", "snapshots")
 }
 
 // CreateSnapshot captures the current pip freeze output and stores it under the venv.
 func (m *Manager) CreateSnapshot(name, label string) (*Snapshot, error) {
+	return m.CreateSnapshotWithOptions(name, SnapshotOptions{Label: label})
+}
+
+// CreateSnapshotWithOptions is the full form of CreateSnapshot.
+func (m *Manager) CreateSnapshotWithOptions(name string, opts SnapshotOptions) (*Snapshot, error) {
 	venvPath, err := m.requireVenv(name)
 	if err != nil {
 		return nil, err
 	}
-	out, err := exec.Command(utils.PipPath(venvPath), "freeze").Output()
+	unlock, err := m.lock(name, "snapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return m.snapshotAt(venvPath, name, opts)
+}
+
+// snapshotAt snapshots the venv at venvPath, recorded under name.
+func (m *Manager) snapshotAt(venvPath, name string, opts SnapshotOptions) (*Snapshot, error) {
+	if opts.Operation == "" {
+		opts.Operation = "manual"
+	}
+	out, err := m.pkg(venvPath).freeze().Output()
 	if err != nil {
 		return nil, fmt.Errorf("pip freeze failed: %v", err)
 	}
+	snap := &Snapshot{
+		Label:         opts.Label,
+		Venv:          name,
+		CreatedAt:     time.Now().UTC(),
+		PythonVersion: venvPythonVersion(venvPath),
+		Operation:     opts.Operation,
+		Full:          opts.Full,
+		VenvPath:      venvPath,
+	}
+	platform := platformOf(markerEnvFor(venvPath))
+	snap.Platform = &platform
+	if pkgs, err := readInstalled(venvPath); err == nil {
+		for _, p := range pkgs {
+			if normalizePkgName(p.Name) == "pip" {
+				snap.PipVersion = p.Version
+			}
+		}
+	}
+	if err := saveSnapshot(venvPath, snap, out); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// saveSnapshot assigns snap an unused ID, then writes the freeze file, the
+// archive for full snapshots, and the metadata sidecar. ID, Path,
+// PackageCount and FreezeHash are filled in.
+func saveSnapshot(venvPath string, snap *Snapshot, freeze []byte) error {
 	dir := snapshotsDir(venvPath)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return nil, err
+		return err
 	}
-	now := time.Now().UTC()
-	id := now.Format("20060102-150405")
-	base := id
-	if label != "" {
-		base = id + "_" + sanitizeLabel(label)
+	base := snap.CreatedAt.Format(snapshotIDLayout)
+	if snap.Label != "" {
+		base += "_" + sanitizeLabel(snap.Label)
 	}
-	fpath := filepath.Join(dir, base+".txt")
-	if err := os.WriteFile(fpath, out, 0o644); err != nil {
-		return nil, err
+	// Two snapshots within the same second would otherwise overwrite each other.
+	id := base
+	for i := 2; fileExists(filepath.Join(dir, id+".txt")); i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
 	}
-	return &Snapshot{
-		ID:           base,
-		Label:        label,
-		Venv:         name,
-		CreatedAt:    now,
-		PackageCount: countLines(out),
-		Path:         fpath,
-	}, nil
+	snap.ID = id
+	snap.Path = filepath.Join(dir, id+".txt")
+	snap.PackageCount = countLines(freeze)
+	snap.FreezeHash = freezeHash(freeze)
+	if err := os.WriteFile(snap.Path, freeze, 0o644); err != nil {
+		return err
+	}
+	if snap.Full {
+		if err := writeFullArchive(venvPath, snap); err != nil {
+			os.Remove(snap.Path)
+			return err
+		}
+	}
+	return writeSnapshotMeta(snap)
+}
+
+func writeSnapshotMeta(snap *Snapshot) error {
+	meta := *snap
+	meta.Path = ""
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(strings.TrimSuffix(snap.Path, ".txt")+".json", data, 0o644)
 }
 
 // ListSnapshots returns all snapshots for a venv, newest first.
@@ -79,28 +175,14 @@ func (m *Manager) ListSnapshots(name string) ([]Snapshot, error) {
 		if e.IsDir() || !strings.HasSuffix(e.Name(), ".txt") {
 			continue
 		}
-		id := strings.TrimSuffix(e.Name(), ".txt")
-		label := ""
-		if idx := strings.Index(id, "_"); idx >= 0 {
-			label = id[idx+1:]
-		}
-		info, err := e.Info()
+		snap, err := loadSnapshot(name, venvPath, filepath.Join(dir, e.Name()))
 		if err != nil {
 			continue
 		}
-		data, _ := os.ReadFile(filepath.Join(dir, e.Name()))
-		snaps = append(snaps, Snapshot{
-			ID:           id,
-			Label:        label,
-			Venv:         name,
-			CreatedAt:    info.ModTime().UTC(),
-			PackageCount: countLines(data),
-			Path:         filepath.Join(dir, e.Name()),
-		})
+		snaps = append(snaps, *snap)
 	}
 	// Sort newest-first; fall back to ID (which embeds the timestamp) when
-	// mtimes tie — filesystems on fast CI runners can produce identical
-	// nanosecond mtimes for files written back-to-back.
+	// timestamps tie.
 	sort.Slice(snaps, func(i, j int) bool {
 		if !snaps[i].CreatedAt.Equal(snaps[j].CreatedAt) {
 			return snaps[i].CreatedAt.After(snaps[j].CreatedAt)
@@ -110,14 +192,48 @@ func (m *Manager) ListSnapshots(name string) ([]Snapshot, error) {
 	return snaps, nil
 }
 
-// Rollback restores a venv to a snapshot. If snapshotID is empty, uses the
-// most recent snapshot. Uninstalls current packages, then reinstalls from
-// snapshot file. Returns the snapshot that was restored.
-func (m *Manager) Rollback(name, snapshotID string) (*Snapshot, error) {
-	venvPath, err := m.requireVenv(name)
+// loadSnapshot reads the sidecar of a freeze file. Snapshots written before
+// sidecars existed are migrated: their metadata is reconstructed from the
+// file name and contents, and a sidecar is written for next time. The Python
+// version they were taken with is unknown; the venv's may have changed since.
+func loadSnapshot(name, venvPath, txtPath string) (*Snapshot, error) {
+	id := strings.TrimSuffix(filepath.Base(txtPath), ".txt")
+	if data, err := os.ReadFile(strings.TrimSuffix(txtPath, ".txt") + ".json"); err == nil {
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err == nil {
+			// The venv may have been renamed since the sidecar was written.
+			snap.ID, snap.Venv, snap.Path = id, name, txtPath
+			return &snap, nil
+		}
+	}
+	freeze, err := os.ReadFile(txtPath)
 	if err != nil {
 		return nil, err
 	}
+	snap := &Snapshot{
+		ID:           id,
+		Venv:         name,
+		PackageCount: countLines(freeze),
+		FreezeHash:   freezeHash(freeze),
+		Migrated:     true,
+		Path:         txtPath,
+	}
+	// Legacy IDs are "<timestamp>[_<label>]". The ID timestamp survives file
+	// copies, unlike the mtime, so prefer it.
+	stamp, label, _ := strings.Cut(id, "_")
+	snap.Label = label
+	if t, err := time.Parse(snapshotIDLayout, stamp); err == nil {
+		snap.CreatedAt = t.UTC()
+	} else if info, err := os.Stat(txtPath); err == nil {
+		snap.CreatedAt = info.ModTime().UTC()
+	}
+	_ = writeSnapshotMeta(snap) // best effort: read-only venvs still list fine
+	return snap, nil
+}
+
+// findSnapshot returns the snapshot with the given ID, or the newest one
+// when snapshotID is empty.
+func (m *Manager) findSnapshot(name, snapshotID string) (*Snapshot, error) {
 	snaps, err := m.ListSnapshots(name)
 	if err != nil {
 		return nil, err
@@ -125,43 +241,15 @@ func (m *Manager) Rollback(name, snapshotID string) (*Snapshot, error) {
 	if len(snaps) == 0 {
 		return nil, fmt.Errorf("no snapshots for venv %q", name)
 	}
-	var target *Snapshot
 	if snapshotID == "" {
-		target = &snaps[0]
-	} else {
-		for i := range snaps {
-			if snaps[i].ID == snapshotID {
-				target = &snaps[i]
-				break
-			}
-		}
-		if target == nil {
-			return nil, fmt.Errorf("snapshot %q not found for venv %q", snapshotID, name)
-		}
-	}
-
-	pip := utils.PipPath(venvPath)
-	// Freeze current, uninstall everything, then install snapshot.
-	cur, err := exec.Command(pip, "freeze").Output()
-	if err != nil {
-		return nil, fmt.Errorf("pip freeze failed: %v", err)
+		return &snaps[0], nil
 	}
-	if len(strings.TrimSpace(string(cur))) > 0 {
-		tmp, err := os.CreateTemp("", "vm-uninstall-*.txt")
-		if err != nil {
-			return nil, err
+	for i := range snaps {
+		if snaps[i].ID == snapshotID {
+			return &snaps[i], nil
 		}
-		tmp.Write(cur)
-		tmp.Close()
-		defer os.Remove(tmp.Name())
-		if out, err := exec.Command(pip, "uninstall", "-y", "-r", tmp.Name()).CombinedOutput(); err != nil {
-			return nil, fmt.Errorf("uninstall failed: %v\n%s", err, out)
-		}
-	}
-	if out, err := exec.Command(pip, "install", "-r", target.Path).CombinedOutput(); err != nil {
-		return nil, fmt.Errorf("install from snapshot failed: %v\n%s", err, out)
 	}
-	return target, nil
+	return nil, fmt.Errorf("snapshot %q not found for venv %q", snapshotID, name)
 }
 
 // DeleteSnapshot removes a snapshot file.
@@ -170,11 +258,33 @@ func (m *Manager) DeleteSnapshot(name, snapshotID string) error {
 	if err != nil {
 		return err
 	}
+	if ValidateName(snapshotID) != nil {
+		return fmt.Errorf("invalid snapshot id %q", snapshotID)
+	}
+	unlock, err := m.lock(name, "snapshot delete")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return deleteSnapshotFiles(venvPath, snapshotID)
+}
+
+// deleteSnapshotFiles removes a snapshot's freeze file, sidecar and archive.
+// The caller holds the venv's lock.
+func deleteSnapshotFiles(venvPath, snapshotID string) error {
 	p := filepath.Join(snapshotsDir(venvPath), snapshotID+".txt")
-	if !m.fs.Exists(p) {
+	if !fileExists(p) {
 		return fmt.Errorf("snapshot %q not found", snapshotID)
 	}
-	return os.Remove(p)
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+	for _, ext := range []string{".json", ".tar.gz"} {
+		if err := os.Remove(strings.TrimSuffix(p, ".txt") + ext); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
 
 func sanitizeLabel(s string) string {
@@ -197,6 +307,16 @@ func sanitizeLabel(s string) string {
 	return out
 }
 
+func freezeHash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func fileExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
 func countLines(b []byte) int {
 	if len(b) == 0 {
 		return 0
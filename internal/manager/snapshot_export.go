@@ -0,0 +1,204 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// snapshotExportFormat identifies exported snapshot files. Bump
+// snapshotExportVersion on incompatible changes; ImportSnapshot rejects
+// versions it does not know.
+const (
+	snapshotExportFormat  = "venv-manager.snapshot"
+	snapshotExportVersion = 1
+)
+
+// SnapshotPlatform describes the host a snapshot was taken on, so an import
+// can tell whether its pins are likely to install.
+type SnapshotPlatform struct {
+	SysPlatform    string `json:"sys_platform"`
+	Machine        string `json:"platform_machine"`
+	Implementation string `json:"implementation"`
+	// InterpreterTag and PlatformTag follow wheel tag conventions
+	// (e.g. "cp312", "linux_x86_64").
+	InterpreterTag string `json:"interpreter_tag,omitempty"`
+	PlatformTag    string `json:"platform_tag"`
+}
+
+// SnapshotExport is the portable, self-describing form of a snapshot.
+// Only the freeze travels: full snapshot archives are platform-specific.
+type SnapshotExport struct {
+	Format        string           `json:"format"`
+	FormatVersion int              `json:"format_version"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	SourceHost    string           `json:"source_host,omitempty"`
+	SourceVenv    string           `json:"source_venv"`
+	ID            string           `json:"id"`
+	Label         string           `json:"label,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Operation     string           `json:"operation,omitempty"`
+	PythonVersion string           `json:"python_version,omitempty"`
+	PipVersion    string           `json:"pip_version,omitempty"`
+	Platform      SnapshotPlatform `json:"platform"`
+	FreezeHash    string           `json:"freeze_sha256"`
+	Freeze        []string         `json:"freeze"`
+}
+
+// ExportSnapshot packages a snapshot of a venv for use on another machine.
+// An empty snapshotID exports the newest snapshot.
+func (m *Manager) ExportSnapshot(name, snapshotID string) (*SnapshotExport, error) {
+	if _, err := m.requireVenv(name); err != nil {
+		return nil, err
+	}
+	snap, err := m.findSnapshot(name, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(snap.Path)
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{}
+	for _, ln := range strings.Split(string(data), "\n") {
+		if ln = strings.TrimSpace(ln); ln != "" {
+			lines = append(lines, ln)
+		}
+	}
+	host, _ := os.Hostname()
+	// Describe the snapshot, not the venv as it is now: it may have been
+	// rebuilt on another Python since.
+	var platform SnapshotPlatform
+	if snap.Platform != nil {
+		platform = *snap.Platform
+	}
+	platform.InterpreterTag = interpreterTag(platform.Implementation, snap.PythonVersion)
+	return &SnapshotExport{
+		Format:        snapshotExportFormat,
+		FormatVersion: snapshotExportVersion,
+		ExportedAt:    time.Now().UTC(),
+		SourceHost:    host,
+		SourceVenv:    name,
+		ID:            snap.ID,
+		Label:         snap.Label,
+		CreatedAt:     snap.CreatedAt,
+		Operation:     snap.Operation,
+		PythonVersion: snap.PythonVersion,
+		PipVersion:    snap.PipVersion,
+		Platform:      platform,
+		FreezeHash:    freezeHash(joinFreeze(lines)),
+		Freeze:        lines,
+	}, nil
+}
+
+// ImportSnapshot adds an exported snapshot to a venv's snapshot list, ready
+// for rollback. It returns the new snapshot and warnings about mismatches
+// (Python version, platform) that may make its pins uninstallable here.
+func (m *Manager) ImportSnapshot(name string, exp *SnapshotExport) (*Snapshot, []string, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock, err := m.lock(name, "snapshot import")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	if exp.Format != snapshotExportFormat {
+		return nil, nil, fmt.Errorf("not a venv-manager snapshot export (format %q)", exp.Format)
+	}
+	if exp.FormatVersion < 1 || exp.FormatVersion > snapshotExportVersion {
+		return nil, nil, fmt.Errorf("unsupported snapshot export version %d (this build reads up to %d)", exp.FormatVersion, snapshotExportVersion)
+	}
+	freeze := joinFreeze(exp.Freeze)
+	if exp.FreezeHash != "" && freezeHash(freeze) != exp.FreezeHash {
+		return nil, nil, fmt.Errorf("snapshot export is corrupt: freeze does not match freeze_sha256")
+	}
+
+	env := markerEnvFor(venvPath)
+	local := platformOf(env)
+	var warnings []string
+	if pv := env["python_version"]; pv != "" && exp.PythonVersion != "" && !samePythonVersion(pv, majorMinor(exp.PythonVersion)) {
+		warnings = append(warnings, fmt.Sprintf("snapshot was taken on Python %s, %q runs %s", exp.PythonVersion, name, env["python_full_version"]))
+	}
+	if exp.Platform.PlatformTag != "" && exp.Platform.PlatformTag != local.PlatformTag {
+		warnings = append(warnings, fmt.Sprintf("snapshot was taken on %s, this host is %s; binary wheels may differ", exp.Platform.PlatformTag, local.PlatformTag))
+	}
+	if exp.Platform.Implementation != "" && exp.Platform.Implementation != local.Implementation {
+		warnings = append(warnings, fmt.Sprintf("snapshot was taken with %s, %q uses %s", exp.Platform.Implementation, name, local.Implementation))
+	}
+
+	source := exp.SourceVenv + "/" + exp.ID
+	if exp.SourceHost != "" {
+		source = exp.SourceHost + ":" + source
+	}
+	snap := &Snapshot{
+		Label:         exp.Label,
+		Venv:          name,
+		CreatedAt:     time.Now().UTC(),
+		PythonVersion: exp.PythonVersion,
+		PipVersion:    exp.PipVersion,
+		Operation:     "import",
+		ImportedFrom:  source,
+	}
+	if exp.Platform.PlatformTag != "" {
+		platform := exp.Platform
+		snap.Platform = &platform
+	}
+	if err := saveSnapshot(venvPath, snap, freeze); err != nil {
+		return nil, nil, err
+	}
+	return snap, warnings, nil
+}
+
+func joinFreeze(lines []string) []byte {
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func majorMinor(v string) string {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return v
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// platformOf derives wheel-style tags from marker values.
+func platformOf(env markerEnv) SnapshotPlatform {
+	p := SnapshotPlatform{
+		SysPlatform:    env["sys_platform"],
+		Machine:        env["platform_machine"],
+		Implementation: env["implementation_name"],
+	}
+	p.InterpreterTag = interpreterTag(p.Implementation, env["python_version"])
+	switch p.SysPlatform {
+	case "win32":
+		p.PlatformTag = "win_" + strings.ToLower(p.Machine)
+	case "darwin":
+		p.PlatformTag = "macosx_" + p.Machine
+	default:
+		p.PlatformTag = p.SysPlatform + "_" + p.Machine
+	}
+	return p
+}
+
+// interpreterTag is the wheel interpreter tag for a Python version, e.g.
+// "cp312". An unknown implementation gets the generic "py" prefix; an
+// unknown version no tag.
+func interpreterTag(implementation, version string) string {
+	if version == "" {
+		return ""
+	}
+	prefix := "py"
+	switch implementation {
+	case "cpython":
+		prefix = "cp"
+	case "pypy":
+		prefix = "pp"
+	}
+	return prefix + strings.ReplaceAll(majorMinor(version), ".", "")
+}
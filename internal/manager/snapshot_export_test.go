@@ -0,0 +1,135 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePyvenvCfg(t *testing.T, venv, version string) {
+	t.Helper()
+	os.MkdirAll(venv, 0o755)
+	if err := os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte("home = /usr/bin\nversion_info = "+version+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotExportImportRoundTrip(t *testing.T) {
+	m, dir := newTestMgr(t)
+	writePyvenvCfg(t, filepath.Join(dir, "src"), "3.12.4.final.0")
+	writePyvenvCfg(t, filepath.Join(dir, "dst"), "3.12.1.final.0")
+	platform := platformOf(markerEnvFor(filepath.Join(dir, "src")))
+	snap := &Snapshot{Venv: "src", Label: "good", CreatedAt: time.Now().UTC().Add(-time.Hour), PythonVersion: "3.12.4", Operation: "manual", Platform: &platform}
+	// The venv has moved on to another Python since the snapshot.
+	writePyvenvCfg(t, filepath.Join(dir, "src"), "3.13.0.final.0")
+	if err := saveSnapshot(filepath.Join(dir, "src"), snap, []byte("a==1.0\nb==2.0\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	exp, err := m.ExportSnapshot("src", snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Format != snapshotExportFormat || exp.FormatVersion != snapshotExportVersion || exp.SourceVenv != "src" {
+		t.Fatalf("bad header: %+v", exp)
+	}
+	if exp.Platform.InterpreterTag != "cp312" || exp.Platform.PlatformTag == "" {
+		t.Fatalf("bad platform: %+v", exp.Platform)
+	}
+
+	got, warnings, err := m.ImportSnapshot("dst", exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("same minor/platform should not warn: %v", warnings)
+	}
+	if got.Operation != "import" || got.Label != "good" || !strings.HasSuffix(got.ImportedFrom, "src/"+snap.ID) || got.PackageCount != 2 {
+		t.Fatalf("unexpected imported snapshot: %+v", got)
+	}
+	listed, err := m.ListSnapshots("dst")
+	if err != nil || len(listed) != 1 || listed[0].ID != got.ID || listed[0].ImportedFrom != got.ImportedFrom {
+		t.Fatalf("imported snapshot not listed: %+v %v", listed, err)
+	}
+	data, _ := os.ReadFile(listed[0].Path)
+	if string(data) != "a==1.0\nb==2.0\n" {
+		t.Fatalf("freeze=%q", data)
+	}
+	if listed[0].Platform == nil || listed[0].Platform.PlatformTag != platform.PlatformTag {
+		t.Fatalf("imported snapshot lost its platform: %+v", listed[0].Platform)
+	}
+}
+
+func TestSnapshotExportWithoutRecordedPlatform(t *testing.T) {
+	m, dir := newTestMgr(t)
+	writePyvenvCfg(t, filepath.Join(dir, "src"), "3.13.0.final.0")
+	snap := &Snapshot{Venv: "src", CreatedAt: time.Now().UTC(), PythonVersion: "3.11.2"}
+	if err := saveSnapshot(filepath.Join(dir, "src"), snap, []byte("a==1.0\n")); err != nil {
+		t.Fatal(err)
+	}
+	exp, err := m.ExportSnapshot("src", snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Platform != (SnapshotPlatform{InterpreterTag: "py311"}) {
+		t.Fatalf("platform should be unknown apart from the snapshot's Python: %+v", exp.Platform)
+	}
+}
+
+func TestSnapshotImportChecks(t *testing.T) {
+	m, dir := newTestMgr(t)
+	writePyvenvCfg(t, filepath.Join(dir, "dst"), "3.11.7")
+	good := func() *SnapshotExport {
+		lines := []string{"a==1.0"}
+		return &SnapshotExport{
+			Format: snapshotExportFormat, FormatVersion: 1, SourceVenv: "x", ID: "id",
+			PythonVersion: "3.12.4", Freeze: lines, FreezeHash: freezeHash(joinFreeze(lines)),
+			Platform: SnapshotPlatform{PlatformTag: "plan9_mips", Implementation: "cpython"},
+		}
+	}
+
+	_, warnings, err := m.ImportSnapshot("dst", good())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected python and platform warnings, got %v", warnings)
+	}
+
+	bad := good()
+	bad.Format = "something-else"
+	if _, _, err := m.ImportSnapshot("dst", bad); err == nil {
+		t.Fatal("expected unknown format to be rejected")
+	}
+	bad = good()
+	bad.FormatVersion = snapshotExportVersion + 1
+	if _, _, err := m.ImportSnapshot("dst", bad); err == nil {
+		t.Fatal("expected future format version to be rejected")
+	}
+	bad = good()
+	bad.Freeze = append(bad.Freeze, "evil==6.6.6")
+	if _, _, err := m.ImportSnapshot("dst", bad); err == nil {
+		t.Fatal("expected tampered freeze to be rejected")
+	}
+}
+
+func TestPlatformOf(t *testing.T) {
+	cases := []struct {
+		env  markerEnv
+		want string
+	}{
+		{markerEnv{"sys_platform": "linux", "platform_machine": "aarch64", "python_version": "3.12", "implementation_name": "cpython"}, "linux_aarch64"},
+		{markerEnv{"sys_platform": "darwin", "platform_machine": "arm64"}, "macosx_arm64"},
+		{markerEnv{"sys_platform": "win32", "platform_machine": "AMD64"}, "win_amd64"},
+	}
+	for _, c := range cases {
+		if got := platformOf(c.env).PlatformTag; got != c.want {
+			t.Errorf("platformOf(%v)=%q want %q", c.env, got, c.want)
+		}
+	}
+	if got := platformOf(markerEnv{"python_version": "3.10", "implementation_name": "pypy"}).InterpreterTag; got != "pp310" {
+		t.Errorf("pypy interpreter tag=%q", got)
+	}
+}
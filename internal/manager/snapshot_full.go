@@ -0,0 +1,401 @@
+package manager
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// paxHashKey carries each regular file's sha256 in the archive, so a restore
+// can verify content file by file.
+const paxHashKey = "VENVMANAGER.sha256"
+
+// fullArchivePath returns where a full snapshot's tarball lives.
+func fullArchivePath(snap *Snapshot) string {
+	return strings.TrimSuffix(snap.Path, ".txt") + ".tar.gz"
+}
+
+// archiveRoots returns the venv-relative directories a full snapshot covers:
+// every site-packages dir plus the bin/Scripts dir with entry points.
+func archiveRoots(venvPath string) []string {
+	var roots []string
+	for _, sp := range sitePackagesDirs(venvPath) {
+		if rel, err := filepath.Rel(venvPath, sp); err == nil {
+			roots = append(roots, filepath.ToSlash(rel))
+		}
+	}
+	bin := utils.VenvBinDir(venvPath)
+	if _, err := os.Stat(bin); err == nil {
+		if rel, err := filepath.Rel(venvPath, bin); err == nil {
+			roots = append(roots, filepath.ToSlash(rel))
+		}
+	}
+	return roots
+}
+
+// writeFullArchive tars and gzips roots of venvPath into the snapshot's
+// archive and records its hash, size and roots on snap. __pycache__ dirs are
+// skipped: they are regenerated on import and often dominate the size.
+func writeFullArchive(venvPath string, snap *Snapshot) error {
+	roots := archiveRoots(venvPath)
+	if len(roots) == 0 {
+		return fmt.Errorf("no site-packages found in %s", venvPath)
+	}
+	out := fullArchivePath(snap)
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	tw := tar.NewWriter(gz)
+	werr := func() error {
+		for _, root := range roots {
+			err := filepath.Walk(filepath.Join(venvPath, filepath.FromSlash(root)), func(p string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() && info.Name() == "__pycache__" {
+					return filepath.SkipDir
+				}
+				rel, err := filepath.Rel(venvPath, p)
+				if err != nil {
+					return err
+				}
+				return addTarEntry(tw, p, filepath.ToSlash(rel), info)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gz.Close()
+	}()
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		os.Remove(out)
+		return fmt.Errorf("failed to write full snapshot: %v", werr)
+	}
+	st, err := os.Stat(out)
+	if err != nil {
+		return err
+	}
+	snap.Full = true
+	snap.ArchiveRoots = roots
+	snap.ArchiveHash = hex.EncodeToString(h.Sum(nil))
+	snap.ArchiveBytes = st.Size()
+	return nil
+}
+
+func addTarEntry(tw *tar.Writer, path, name string, info os.FileInfo) error {
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	// Ownership is meaningless across machines and breaks non-root restores.
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if !info.Mode().IsRegular() {
+		return tw.WriteHeader(hdr)
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	hdr.PAXRecords = map[string]string{paxHashKey: sum}
+	hdr.Format = tar.FormatPAX
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// restoreFull replaces the archived roots of a venv with the snapshot's
+// files. No pip and no network: the archive is verified, extracted into a
+// staging dir, and swapped in root by root. The venv keeps its current
+// interpreter links, and files archived while the venv lived elsewhere are
+// relocated. Any failure puts the original directories back. Callers check
+// that the snapshot was taken on the venv's Python version.
+func restoreFull(venvPath string, snap *Snapshot) error {
+	archive := fullArchivePath(snap)
+	sum, err := fileSHA256(archive)
+	if err != nil {
+		return fmt.Errorf("full snapshot archive unreadable: %v", err)
+	}
+	if sum != snap.ArchiveHash {
+		return fmt.Errorf("full snapshot archive %s is corrupt (sha256 mismatch)", filepath.Base(archive))
+	}
+
+	work := filepath.Join(venvPath, ".venv-manager", "restore-"+snap.ID)
+	os.RemoveAll(work)
+	defer os.RemoveAll(work)
+	staged, backup := filepath.Join(work, "new"), filepath.Join(work, "old")
+	if err := extractArchive(archive, staged); err != nil {
+		return err
+	}
+	if err := keepInterpreter(venvPath, staged); err != nil {
+		return fmt.Errorf("failed to keep the venv's interpreter: %v", err)
+	}
+	if from := archivedVenvPath(snap, staged); from != "" && from != venvPath {
+		if _, err := relocateTree(staged, from, venvPath); err != nil {
+			return fmt.Errorf("failed to relocate snapshot files from %s: %v", from, err)
+		}
+	}
+
+	type swap struct{ live, old string }
+	var done []swap
+	undo := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			os.RemoveAll(done[i].live)
+			if done[i].old != "" {
+				os.Rename(done[i].old, done[i].live)
+			}
+		}
+	}
+	for _, root := range snap.ArchiveRoots {
+		rel := filepath.FromSlash(root)
+		live, old, src := filepath.Join(venvPath, rel), filepath.Join(backup, rel), filepath.Join(staged, rel)
+		s := swap{live: live}
+		if _, err := os.Lstat(live); err == nil {
+			if err := os.MkdirAll(filepath.Dir(old), 0o755); err != nil {
+				undo()
+				return err
+			}
+			if err := os.Rename(live, old); err != nil {
+				undo()
+				return fmt.Errorf("failed to move %s aside: %v", root, err)
+			}
+			s.old = old
+		}
+		done = append(done, s)
+		if err := os.MkdirAll(filepath.Dir(live), 0o755); err != nil {
+			undo()
+			return err
+		}
+		if err := os.Rename(src, live); err != nil {
+			undo()
+			return fmt.Errorf("failed to restore %s: %v", root, err)
+		}
+	}
+	return nil
+}
+
+// venvInterpreterRe matches the interpreter entries of a venv's bin dir:
+// python, python3, python3.12, pythonw.exe, pypy3 and so on.
+var venvInterpreterRe = regexp.MustCompile(`^(python|pypy)w?(\d+(\.\d+)?)?(\.exe)?$`)
+
+// keepInterpreter replaces the interpreter entries of the bin dir extracted
+// at staged with the venv's current ones, so a restore never brings back
+// links to a base Python the venv no longer uses.
+func keepInterpreter(venvPath, staged string) error {
+	liveBin := utils.VenvBinDir(venvPath)
+	rel, err := filepath.Rel(venvPath, liveBin)
+	if err != nil {
+		return err
+	}
+	stagedBin := filepath.Join(staged, rel)
+	entries, err := os.ReadDir(stagedBin)
+	if err != nil {
+		// bin/ was not archived.
+		return nil
+	}
+	for _, e := range entries {
+		if venvInterpreterRe.MatchString(e.Name()) {
+			if err := os.RemoveAll(filepath.Join(stagedBin, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	entries, err = os.ReadDir(liveBin)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if !venvInterpreterRe.MatchString(e.Name()) {
+			continue
+		}
+		src, dst := filepath.Join(liveBin, e.Name()), filepath.Join(stagedBin, e.Name())
+		if e.Type()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(src)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, dst); err != nil {
+				return err
+			}
+		} else if err := utils.CopyDir(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archivedVenvPath returns where the venv lived when snap was taken. Older
+// snapshots did not record it; their console-script shebangs still tell.
+func archivedVenvPath(snap *Snapshot, staged string) string {
+	if snap.VenvPath != "" {
+		return snap.VenvPath
+	}
+	bin := filepath.Join(staged, "bin")
+	entries, err := os.ReadDir(bin)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		first, ok := shebang(filepath.Join(bin, e.Name()))
+		if !ok {
+			continue
+		}
+		py := strings.Fields(first)
+		if len(py) > 0 && filepath.IsAbs(py[0]) && venvInterpreterRe.MatchString(filepath.Base(py[0])) && filepath.Base(filepath.Dir(py[0])) == "bin" {
+			return filepath.Dir(filepath.Dir(py[0]))
+		}
+	}
+	return ""
+}
+
+// shebang returns the interpreter line of a script, without "#!".
+func shebang(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	ln, _ := bufio.NewReader(f).ReadString('\n')
+	rest, ok := strings.CutPrefix(strings.TrimSpace(ln), "#!")
+	return rest, ok
+}
+
+// extractArchive unpacks a full snapshot into dest, rejecting entries that
+// would escape it and verifying each file against its recorded sha256.
+func extractArchive(archive, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(strings.TrimSuffix(hdr.Name, "/"))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("refusing unsafe archive entry %q", hdr.Name)
+		}
+		// Symlink targets are kept as they are (bin/python points at the
+		// base interpreter), so no entry may be written through one: a
+		// "lib/x -> /etc" link followed by "lib/x/foo" would escape dest.
+		if throughSymlink(dest, name) {
+			return fmt.Errorf("refusing archive entry %q: it is or goes through a symlink", hdr.Name)
+		}
+		target := filepath.Join(dest, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode().Perm()|0o700); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, target, hdr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// throughSymlink reports whether dest/name, or any directory between dest
+// and it, already exists as a symlink.
+func throughSymlink(dest, name string) bool {
+	p := dest
+	for _, part := range strings.Split(name, string(filepath.Separator)) {
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return false
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func extractFile(r io.Reader, target string, hdr *tar.Header) error {
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if want := hdr.PAXRecords[paxHashKey]; want != "" && want != hex.EncodeToString(h.Sum(nil)) {
+		return fmt.Errorf("content hash mismatch for %s", hdr.Name)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package manager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+func TestFullSnapshotRestore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinked entry points are POSIX-only")
+	}
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	venv := filepath.Join(dir, "v")
+	writeDistInfo(t, sp, "alpha", "1.0", nil, true)
+	os.WriteFile(filepath.Join(sp, "alpha.py"), []byte("v = 1\n"), 0o644)
+	bin := utils.VenvBinDir(venv)
+	os.MkdirAll(bin, 0o755)
+	os.WriteFile(filepath.Join(bin, "alpha"), []byte("#!/bin/sh\n"), 0o755)
+	os.Symlink("/usr/bin/python3", filepath.Join(bin, "python"))
+	os.MkdirAll(filepath.Join(sp, "__pycache__"), 0o755)
+	os.WriteFile(filepath.Join(sp, "__pycache__", "alpha.pyc"), []byte("junk"), 0o644)
+
+	snap := &Snapshot{Venv: "v", CreatedAt: time.Now().UTC(), Full: true}
+	if err := saveSnapshot(venv, snap, []byte("alpha==1.0\n")); err != nil {
+		t.Fatal(err)
+	}
+	if snap.ArchiveHash == "" || snap.ArchiveBytes == 0 || len(snap.ArchiveRoots) != 2 {
+		t.Fatalf("archive metadata missing: %+v", snap)
+	}
+
+	// Mutate the venv: change a file, add a package, drop an entry point.
+	os.WriteFile(filepath.Join(sp, "alpha.py"), []byte("v = 2\n"), 0o644)
+	writeDistInfo(t, sp, "beta", "2.0", nil, true)
+	os.Remove(filepath.Join(bin, "alpha"))
+
+	restored, err := m.Rollback("v", snap.ID)
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if !restored.Full {
+		t.Fatalf("expected full snapshot, got %+v", restored)
+	}
+	if b, _ := os.ReadFile(filepath.Join(sp, "alpha.py")); string(b) != "v = 1\n" {
+		t.Fatalf("alpha.py not restored: %q", b)
+	}
+	if _, err := os.Stat(filepath.Join(sp, "beta-2.0.dist-info")); !os.IsNotExist(err) {
+		t.Fatalf("beta should be gone, stat err=%v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(bin, "alpha")); err != nil || fi.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("entry point not restored executable: %v", err)
+	}
+	if link, err := os.Readlink(filepath.Join(bin, "python")); err != nil || link != "/usr/bin/python3" {
+		t.Fatalf("symlink not restored: %q %v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(sp, "__pycache__")); !os.IsNotExist(err) {
+		t.Fatal("__pycache__ should not be archived")
+	}
+	if _, err := os.Stat(filepath.Join(venv, ".venv-manager", "restore-"+snap.ID)); !os.IsNotExist(err) {
+		t.Fatal("staging dir left behind")
+	}
+}
+
+func TestFullSnapshotRejectsCorruptArchive(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	venv := filepath.Join(dir, "v")
+	os.WriteFile(filepath.Join(sp, "alpha.py"), []byte("v = 1\n"), 0o644)
+	snap := &Snapshot{Venv: "v", CreatedAt: time.Now().UTC(), Full: true}
+	if err := saveSnapshot(venv, snap, []byte("")); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.OpenFile(fullArchivePath(snap), os.O_APPEND|os.O_WRONLY, 0)
+	f.Write([]byte("tamper"))
+	f.Close()
+	os.WriteFile(filepath.Join(sp, "alpha.py"), []byte("v = 2\n"), 0o644)
+
+	if _, err := m.Rollback("v", snap.ID); err == nil {
+		t.Fatal("expected corrupt archive to be rejected")
+	}
+	if b, _ := os.ReadFile(filepath.Join(sp, "alpha.py")); string(b) != "v = 2\n" {
+		t.Fatalf("venv must be untouched after a failed restore: %q", b)
+	}
+}
+
+func TestFullSnapshotRestoreAfterRename(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinked entry points are POSIX-only")
+	}
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	venv := filepath.Join(dir, "v")
+	os.WriteFile(filepath.Join(sp, "alpha.py"), []byte("v = 1\n"), 0o644)
+	bin := utils.VenvBinDir(venv)
+	os.MkdirAll(bin, 0o755)
+	os.WriteFile(filepath.Join(bin, "alpha"), []byte("#!"+filepath.Join(bin, "python")+"\n"), 0o755)
+	os.Symlink("/usr/bin/python3", filepath.Join(bin, "python"))
+	// A pip that freezes nothing.
+	os.WriteFile(filepath.Join(bin, "pip"), []byte("#!/bin/sh\n"), 0o755)
+	os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte("home = /usr/bin\nversion_info = 3.12.1\n"), 0o644)
+
+	snap, err := m.CreateSnapshotWithOptions("v", SnapshotOptions{Full: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.VenvPath != venv {
+		t.Fatalf("venv path not recorded: %q", snap.VenvPath)
+	}
+	if err := m.Rename("v", "w"); err != nil {
+		t.Fatal(err)
+	}
+	venv, bin, sp = m.VenvPath("w"), utils.VenvBinDir(m.VenvPath("w")), strings.Replace(sp, venv, m.VenvPath("w"), 1)
+	// Rebuilt on another interpreter of the same minor version since.
+	os.Remove(filepath.Join(bin, "python"))
+	os.Symlink("/opt/python3.12/bin/python3", filepath.Join(bin, "python"))
+	os.WriteFile(filepath.Join(sp, "alpha.py"), []byte("v = 2\n"), 0o644)
+
+	if _, err := m.Rollback("w", snap.ID); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(sp, "alpha.py")); string(b) != "v = 1\n" {
+		t.Fatalf("alpha.py not restored: %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(bin, "alpha")); string(b) != "#!"+filepath.Join(bin, "python")+"\n" {
+		t.Fatalf("restored shebang not relocated: %q", b)
+	}
+	if link, _ := os.Readlink(filepath.Join(bin, "python")); link != "/opt/python3.12/bin/python3" {
+		t.Fatalf("interpreter link replaced by the archived one: %q", link)
+	}
+}
+
+func TestFullSnapshotSkippedForOtherPython(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	venv := filepath.Join(dir, "v")
+	os.WriteFile(filepath.Join(sp, "alpha.py"), []byte("v = 1\n"), 0o644)
+	os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte("version_info = 3.12.1\n"), 0o644)
+	snap := &Snapshot{Venv: "v", CreatedAt: time.Now().UTC(), Full: true, PythonVersion: "3.11.9"}
+	if err := saveSnapshot(venv, snap, []byte("")); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(sp, "alpha.py"), []byte("v = 2\n"), 0o644)
+
+	// The freeze delta needs pip, which this fake venv lacks; what matters
+	// is that no 3.11 file was put into the 3.12 venv.
+	if _, err := m.Rollback("v", snap.ID); err == nil {
+		t.Fatal("expected the freeze delta to run, and fail without pip")
+	}
+	if b, _ := os.ReadFile(filepath.Join(sp, "alpha.py")); string(b) != "v = 2\n" {
+		t.Fatalf("files from another python version were restored: %q", b)
+	}
+}
+
+func TestArchivedVenvPathFromShebang(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX shebang paths")
+	}
+	staged := t.TempDir()
+	os.MkdirAll(filepath.Join(staged, "bin"), 0o755)
+	os.WriteFile(filepath.Join(staged, "bin", "activate"), []byte("# sourced\n"), 0o644)
+	os.WriteFile(filepath.Join(staged, "bin", "pip"), []byte("#!/old/venvs/app/bin/python3.12\nimport sys\n"), 0o755)
+	if got := archivedVenvPath(&Snapshot{}, staged); got != "/old/venvs/app" {
+		t.Fatalf("legacy snapshot origin = %q", got)
+	}
+	if got := archivedVenvPath(&Snapshot{VenvPath: "/recorded"}, staged); got != "/recorded" {
+		t.Fatalf("recorded origin = %q", got)
+	}
+}
+
+func TestExtractArchiveRefusesWritingThroughSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	outside := t.TempDir()
+	for name, second := range map[string]*tar.Header{
+		"dir":  {Name: "lib/x/foo", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1},
+		"file": {Name: "lib/x", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "a.tar.gz")
+			f, _ := os.Create(archive)
+			gz := gzip.NewWriter(f)
+			tw := tar.NewWriter(gz)
+			link := outside
+			if name == "file" {
+				link = filepath.Join(outside, "target")
+			}
+			tw.WriteHeader(&tar.Header{Name: "lib/x", Typeflag: tar.TypeSymlink, Linkname: link})
+			tw.WriteHeader(second)
+			tw.Write([]byte("x"))
+			tw.Close()
+			gz.Close()
+			f.Close()
+
+			err := extractArchive(archive, filepath.Join(t.TempDir(), "dest"))
+			if err == nil || !strings.Contains(err.Error(), "symlink") {
+				t.Fatalf("extractArchive: %v", err)
+			}
+			if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+				t.Fatalf("wrote outside dest: %v", entries)
+			}
+		})
+	}
+}
@@ -3,9 +3,12 @@ package manager
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
 )
 
 func TestSanitizeLabel(t *testing.T) {
@@ -81,3 +84,204 @@ func TestListSnapshotsSortsNewestFirst(t *testing.T) {
 		t.Fatalf("expected newest first: %v", snaps)
 	}
 }
+
+func TestPlanDelta(t *testing.T) {
+	cur := parseFreeze([]string{"requests==2.32.0", "six==1.16.0", "idna==3.6", "mypkg @ file:///new.whl"})
+	want := parseFreeze([]string{"requests==2.31.0", "idna==3.6", "urllib3==2.2.1", "mypkg @ file:///old.whl"})
+	remove, install := planDelta(cur, want)
+	if len(remove) != 1 || remove[0].Name != "six" || remove[0].From != "1.16.0" {
+		t.Fatalf("remove=%+v", remove)
+	}
+	var got []string
+	for _, c := range install {
+		got = append(got, c.To)
+	}
+	if strings.Join(got, ",") != "mypkg @ file:///old.whl,requests==2.31.0,urllib3==2.2.1" {
+		t.Fatalf("install=%v", got)
+	}
+	if install[1].From != "2.32.0" || install[2].From != "" {
+		t.Fatalf("install from=%+v", install)
+	}
+	remove, install = planDelta(want, want)
+	if len(remove)+len(install) != 0 {
+		t.Fatalf("identical freeze must yield an empty plan: %v %v", remove, install)
+	}
+}
+
+func TestSaveSnapshotKeepsLabelAndMetadata(t *testing.T) {
+	m, dir := newTestMgr(t)
+	os.MkdirAll(filepath.Join(dir, "v"), 0o755)
+	os.WriteFile(filepath.Join(dir, "v", "pyvenv.cfg"), []byte("home = /usr/bin\nversion = 3.12.4\n"), 0o644)
+	at := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	first := &Snapshot{Label: "pre_upgrade_v2", Venv: "v", CreatedAt: at, Operation: "upgrade", PythonVersion: "3.12.4"}
+	if err := saveSnapshot(filepath.Join(dir, "v"), first, []byte("a==1\nb==2\n")); err != nil {
+		t.Fatal(err)
+	}
+	second := &Snapshot{Label: "pre_upgrade_v2", Venv: "v", CreatedAt: at}
+	if err := saveSnapshot(filepath.Join(dir, "v"), second, []byte("a==1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("same-second snapshots must get distinct IDs: %s", first.ID)
+	}
+
+	snaps, err := m.ListSnapshots("v")
+	if err != nil || len(snaps) != 2 {
+		t.Fatalf("ListSnapshots=%v err=%v", snaps, err)
+	}
+	got := snaps[1]
+	if snaps[0].ID == first.ID {
+		got = snaps[0]
+	}
+	if got.Label != "pre_upgrade_v2" || !got.CreatedAt.Equal(at) || got.Operation != "upgrade" ||
+		got.PackageCount != 2 || got.FreezeHash == "" || got.Migrated {
+		t.Fatalf("metadata not preserved: %+v", got)
+	}
+}
+
+func TestListSnapshotsMigratesLegacy(t *testing.T) {
+	m, dir := newTestMgr(t)
+	sd := filepath.Join(dir, "v", ".venv-manager", "snapshots")
+	os.MkdirAll(sd, 0o755)
+	os.WriteFile(filepath.Join(dir, "v", "pyvenv.cfg"), []byte("version = 3.11.7\n"), 0o644)
+	legacy := filepath.Join(sd, "20260105-093000_nightly.txt")
+	os.WriteFile(legacy, []byte("pkg==1\n"), 0o644)
+	// A copied file gets a fresh mtime; the ID timestamp must win.
+	now := time.Now()
+	os.Chtimes(legacy, now, now)
+
+	snaps, err := m.ListSnapshots("v")
+	if err != nil || len(snaps) != 1 {
+		t.Fatalf("ListSnapshots=%v err=%v", snaps, err)
+	}
+	s := snaps[0]
+	if !s.Migrated || s.Label != "nightly" || s.PythonVersion != "" ||
+		!s.CreatedAt.Equal(time.Date(2026, 1, 5, 9, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected migrated snapshot: %+v", s)
+	}
+	if _, err := os.Stat(filepath.Join(sd, "20260105-093000_nightly.json")); err != nil {
+		t.Fatalf("migration should write a sidecar: %v", err)
+	}
+	if err := m.DeleteSnapshot("v", s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(sd); len(entries) != 0 {
+		t.Fatalf("DeleteSnapshot left files behind: %v", entries)
+	}
+	if err := m.DeleteSnapshot("v", "../../pyvenv"); err == nil {
+		t.Fatal("traversal snapshot id must be rejected")
+	}
+}
+
+// rollbackFixture makes a venv with alpha 2.0, beta 1.0 and gamma 1.0, each
+// with a RECORD, and a snapshot of alpha 1.0 and gamma 1.0. Its pip installs
+// alpha 1.0 over whatever is there, then fails when fail is true, as when
+// the network drops mid-download.
+func rollbackFixture(t *testing.T, fail bool) (*Manager, string, *Snapshot) {
+	t.Helper()
+	m, dir := newTestMgr(t)
+	sp := fakeSitePackages(t, dir)
+	venv := filepath.Join(dir, "v")
+	for _, d := range []struct{ name, version, file string }{
+		{"alpha", "2.0", "alpha/__init__.py"},
+		{"beta", "1.0", "beta.py"},
+		{"gamma", "1.0", "gamma.py"},
+	} {
+		writeDistInfo(t, sp, d.name, d.version, nil, true)
+		di := d.name + "-" + d.version + ".dist-info"
+		os.MkdirAll(filepath.Dir(filepath.Join(sp, d.file)), 0o755)
+		os.WriteFile(filepath.Join(sp, d.file), []byte(d.name+" "+d.version+"\n"), 0o644)
+		os.WriteFile(filepath.Join(sp, di, "RECORD"), []byte(d.file+",,\n"+di+"/METADATA,,\n"+di+"/RECORD,,\n"), 0o644)
+	}
+	bin := utils.VenvBinDir(venv)
+	os.MkdirAll(bin, 0o755)
+	exit := "0"
+	if fail {
+		exit = "1"
+	}
+	newInfo := filepath.Join(sp, "alpha-1.0.dist-info")
+	script := "#!/bin/sh\ncase \"$1\" in\n" +
+		"freeze) echo alpha==2.0; echo beta==1.0; echo gamma==1.0 ;;\n" +
+		"install) mkdir -p " + filepath.Join(sp, "alpha") + " " + newInfo + "\n" +
+		"  echo 'alpha 1.0' > " + filepath.Join(sp, "alpha", "__init__.py") + "\n" +
+		"  printf 'Metadata-Version: 2.1\\nName: alpha\\nVersion: 1.0\\n' > " + filepath.Join(newInfo, "METADATA") + "\n" +
+		"  printf 'alpha/__init__.py,,\\n' > " + filepath.Join(newInfo, "RECORD") + "\n" +
+		"  exit " + exit + " ;;\n" +
+		"uninstall) echo uninstall >> " + filepath.Join(dir, "uninstalled") + " ;;\n" +
+		"esac\n"
+	os.WriteFile(filepath.Join(bin, "pip"), []byte(script), 0o755)
+	snap := &Snapshot{Venv: "v", CreatedAt: time.Now().UTC()}
+	if err := saveSnapshot(venv, snap, []byte("alpha==1.0\ngamma==1.0\n")); err != nil {
+		t.Fatal(err)
+	}
+	return m, sp, snap
+}
+
+func TestRollbackFailureRestoresFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for pip")
+	}
+	m, sp, snap := rollbackFixture(t, true)
+
+	_, err := m.Rollback("v", snap.ID)
+	if err == nil || !strings.Contains(err.Error(), "venv left as it was") {
+		t.Fatalf("Rollback: %v", err)
+	}
+	for file, want := range map[string]string{"alpha/__init__.py": "alpha 2.0\n", "beta.py": "beta 1.0\n", "gamma.py": "gamma 1.0\n"} {
+		if b, _ := os.ReadFile(filepath.Join(sp, file)); string(b) != want {
+			t.Fatalf("%s not restored from the backup: %q", file, b)
+		}
+	}
+	if !fileExists(filepath.Join(sp, "alpha-2.0.dist-info", "RECORD")) || fileExists(filepath.Join(sp, "alpha-1.0.dist-info")) {
+		t.Fatal("the half-installed alpha 1.0 was not replaced by alpha 2.0")
+	}
+	if fileExists(filepath.Join(filepath.Dir(m.VenvPath("v")), "uninstalled")) {
+		t.Fatal("packages were uninstalled before the install succeeded")
+	}
+	if fileExists(filepath.Join(m.VenvPath("v"), ".venv-manager", "rollback-backup")) {
+		t.Fatal("backup left behind")
+	}
+}
+
+func TestRollbackMovesAsideOnlyTouchedDists(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for pip")
+	}
+	m, sp, snap := rollbackFixture(t, false)
+
+	if _, err := m.Rollback("v", snap.ID); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(sp, "alpha", "__init__.py")); string(b) != "alpha 1.0\n" {
+		t.Fatalf("alpha not installed: %q", b)
+	}
+	if fileExists(filepath.Join(sp, "beta.py")) || fileExists(filepath.Join(sp, "beta-1.0.dist-info")) || fileExists(filepath.Join(sp, "alpha-2.0.dist-info")) {
+		t.Fatal("removed and replaced dists left behind")
+	}
+	if b, _ := os.ReadFile(filepath.Join(sp, "gamma.py")); string(b) != "gamma 1.0\n" {
+		t.Fatalf("untouched dist changed: %q", b)
+	}
+	if fileExists(filepath.Join(m.VenvPath("v"), ".venv-manager", "rollback-backup")) {
+		t.Fatal("backup left behind")
+	}
+}
+
+func TestRollbackTakesItsAutoSnapshotUnderItsOwnLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for pip")
+	}
+	m, _, snap := rollbackFixture(t, false)
+	// Auto snapshot and retention run inside Rollback's lock; taking the
+	// lock again would wait out the timeout.
+	m.autoSnapshot = true
+	m.retention = RetentionPolicy{KeepLast: 2}
+	m.SetLockTimeout(-1)
+
+	if _, err := m.Rollback("v", snap.ID); err != nil {
+		t.Fatal(err)
+	}
+	snaps, err := m.ListSnapshots("v")
+	if err != nil || len(snaps) != 2 || snaps[0].Operation != "rollback" {
+		t.Fatalf("snapshots after rollback: %+v %v", snaps, err)
+	}
+}
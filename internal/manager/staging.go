@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// Venvs are built under <baseDir>/.venv-manager/staging/<pid>/<name> and
+// renamed into place only once complete, so a failed or interrupted build
+// never leaves a half-made venv in baseDir. The pid lets a later run tell
+// the leftovers of a crashed process from a build still in progress.
+
+func (m *Manager) stagingRoot() string {
+	return filepath.Join(m.baseDir, ".venv-manager", "staging")
+}
+
+// stagingDir holds this process's staged venvs.
+func (m *Manager) stagingDir() string {
+	return filepath.Join(m.stagingRoot(), strconv.Itoa(os.Getpid()))
+}
+
+// stagingPath is where a venv is built before being moved into place.
+func (m *Manager) stagingPath(name string) string {
+	return filepath.Join(m.stagingDir(), name)
+}
+
+// CleanupStaging removes everything this process has staged. Front ends call
+// it when interrupted; builds that fail clean up after themselves.
+func (m *Manager) CleanupStaging() error {
+	return os.RemoveAll(m.stagingDir())
+}
+
+// stagingUsers counts the builds using this process's staging directory.
+type stagingUsers struct {
+	mu    sync.Mutex
+	n     int
+	swept sync.Once
+}
+
+// holdStaging creates this process's staging directory and keeps it until
+// the matching releaseStaging, so that concurrent builds (repair --global)
+// never remove it from under each other.
+func (m *Manager) holdStaging() error {
+	m.staging.mu.Lock()
+	defer m.staging.mu.Unlock()
+	if err := os.MkdirAll(m.stagingDir(), 0o755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	m.staging.n++
+	return nil
+}
+
+// releaseStaging removes this process's staging directory once no build
+// holds it and it is empty.
+func (m *Manager) releaseStaging() {
+	m.staging.mu.Lock()
+	defer m.staging.mu.Unlock()
+	if m.staging.n > 0 {
+		m.staging.n--
+	}
+	if m.staging.n == 0 {
+		os.Remove(m.stagingDir())
+	}
+}
+
+// sweepStagingOnce sweeps the leftovers of crashed or killed runs the first
+// time this Manager builds a venv, rather than on every command.
+func (m *Manager) sweepStagingOnce() {
+	m.staging.swept.Do(func() { m.sweepStaging() })
+}
+
+// sweepStaging removes the staging directories of processes that no longer
+// run, and returns their paths. Anything not named after a pid is left
+// alone.
+func (m *Manager) sweepStaging() []string {
+	entries, err := os.ReadDir(m.stagingRoot())
+	if err != nil {
+		return nil
+	}
+	var swept []string
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() || pid == os.Getpid() || utils.ProcessAlive(pid) {
+			continue
+		}
+		p := filepath.Join(m.stagingRoot(), e.Name())
+		if os.RemoveAll(p) == nil {
+			swept = append(swept, p)
+		}
+	}
+	return swept
+}
+
+// stageVenv creates the venv name in a staging directory, runs fill on it
+// (to install packages) and moves it into place only when both succeed. On
+// failure the staging directory is removed and baseDir is untouched. The
+// venv's lock is held throughout, the base lock only while moving it in.
+func (m *Manager) stageVenv(name, operation string, opts CreateOptions, fill func(staging string) error) (err error) {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	unlock, err := m.lock(name, operation)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	venvPath := m.VenvPath(name)
+	if m.fs.Exists(venvPath) {
+		return fmt.Errorf("'%s' already exists", name)
+	}
+	staging := m.stagingPath(name)
+	if err := os.RemoveAll(staging); err != nil {
+		return fmt.Errorf("failed to clear staging directory: %v", err)
+	}
+	m.sweepStagingOnce()
+	if err := m.holdStaging(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(staging)
+			if !m.fs.Exists(venvPath) {
+				m.dropLock(name)
+			}
+		}
+		m.releaseStaging()
+	}()
+	if err := m.createVenv(staging, opts); err != nil {
+		return err
+	}
+	if fill != nil {
+		if err := fill(staging); err != nil {
+			return err
+		}
+	}
+	unlockBase, err := m.lockBase(operation)
+	if err != nil {
+		return err
+	}
+	defer unlockBase()
+	if m.fs.Exists(venvPath) {
+		return fmt.Errorf("'%s' already exists", name)
+	}
+	if err := os.Rename(staging, venvPath); err != nil {
+		return fmt.Errorf("failed to move venv into place: %v", err)
+	}
+	if _, err := relocateVenv(venvPath, staging); err != nil {
+		os.RemoveAll(venvPath)
+		return fmt.Errorf("failed to relocate venv: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// fakeVenvPython writes a stand-in for `python -m venv <path>` that lays out
+// a venv recording its path, then exits with code.
+func fakeVenvPython(t *testing.T, code int) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "python")
+	script := `#!/bin/sh
+[ "$1" = "-m" ] || exit 1
+mkdir -p "$3/bin"
+echo "#!$3/bin/python" > "$3/bin/tool"
+echo "home = /usr/bin" > "$3/pyvenv.cfg"
+exit ` + strconv.Itoa(code) + "\n"
+	if err := os.WriteFile(p, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCreateIsStaged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-in for python")
+	}
+	m, _ := newTestMgr(t)
+	if err := m.CreateWithOptions("bad", CreateOptions{Python: fakeVenvPython(t, 1)}); err == nil {
+		t.Fatal("failing venv creation should fail Create")
+	}
+	if names, _ := m.List(); len(names) != 0 || fileExists(m.VenvPath("bad")) {
+		t.Fatalf("failed create left %v behind", names)
+	}
+	if fileExists(m.stagingPath("bad")) || fileExists(m.stagingDir()) {
+		t.Fatal("failed create left its staging directory")
+	}
+
+	if err := m.CreateWithOptions("good", CreateOptions{Python: fakeVenvPython(t, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(utils.VenvBinDir(m.VenvPath("good")), "tool"))
+	if err != nil || strings.TrimSpace(string(b)) != "#!"+utils.PythonPath(m.VenvPath("good")) {
+		t.Fatalf("staged paths not relocated: %q %v", b, err)
+	}
+	if c := readPythonChoice(m.VenvPath("good")); c == nil || c.Source != PythonSourceFlag {
+		t.Fatalf("python choice not recorded: %+v", c)
+	}
+	if err := m.CreateWithOptions("good", CreateOptions{}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("second create: %v", err)
+	}
+}
+
+func TestSweepStaging(t *testing.T) {
+	m, _ := newTestMgr(t)
+	root := m.stagingRoot()
+	for _, d := range []string{"99999999", "junk", strconv.Itoa(os.Getpid()), strconv.Itoa(os.Getppid())} {
+		os.MkdirAll(filepath.Join(root, d, "v", "bin"), 0o755)
+	}
+	New(m.GetBaseDir())
+	if !fileExists(filepath.Join(root, "99999999")) {
+		t.Fatal("constructing a Manager swept staging")
+	}
+	swept := m.sweepStaging()
+	if len(swept) != 1 || filepath.Base(swept[0]) != "99999999" {
+		t.Fatalf("swept %v, want only the dead pid", swept)
+	}
+	for _, d := range []string{"junk", strconv.Itoa(os.Getpid()), strconv.Itoa(os.Getppid())} {
+		if !fileExists(filepath.Join(root, d, "v")) {
+			t.Errorf("staging dir %s was removed", d)
+		}
+	}
+	if err := m.CleanupStaging(); err != nil || fileExists(m.stagingDir()) {
+		t.Fatalf("CleanupStaging: %v", err)
+	}
+}
+
+func TestStagingHeldUntilLastRelease(t *testing.T) {
+	m, _ := newTestMgr(t)
+	if err := m.holdStaging(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.holdStaging(); err != nil {
+		t.Fatal(err)
+	}
+	m.releaseStaging()
+	if !fileExists(m.stagingDir()) {
+		t.Fatal("staging removed while another build holds it")
+	}
+	m.releaseStaging()
+	if fileExists(m.stagingDir()) {
+		t.Fatal("staging left after the last release")
+	}
+}
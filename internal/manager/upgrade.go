@@ -0,0 +1,374 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Upgrade levels, from least to most disruptive.
+const (
+	UpgradePatch = "patch"
+	UpgradeMinor = "minor"
+	UpgradeMajor = "major"
+	// UpgradeOther marks versions that are not PEP 440, so no level applies.
+	UpgradeOther = "other"
+)
+
+var upgradeRank = map[string]int{UpgradePatch: 1, UpgradeMinor: 2, UpgradeMajor: 3}
+
+// UpgradeOptions filters what Upgrade changes.
+type UpgradeOptions struct {
+	// DryRun only computes the plan.
+	DryRun bool
+	// Only caps upgrades at "patch" or "minor". A package whose latest
+	// release is beyond the cap is still upgraded, to the newest release
+	// within it.
+	Only string
+	// Include, when set, restricts the upgrade to these packages.
+	Include []string
+	// Exclude lists packages to leave alone.
+	Exclude []string
+	// Verify is a shell command run in the venv after the upgrade, following
+	// `pip check`. If either fails, the venv is rolled back to a snapshot
+	// taken just before the upgrade.
+	Verify string
+}
+
+// UpgradeChange is one package in an upgrade plan.
+type UpgradeChange struct {
+	Name    string `json:"name"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	// Level classifies Current→Latest: patch, minor, major or other.
+	Level string `json:"level"`
+	// Target is the requirement handed to pip: "name==latest", or a range
+	// capped below the next disallowed release.
+	Target string `json:"target,omitempty"`
+	// Capped is set when --only held the package below Latest.
+	Capped bool `json:"capped,omitempty"`
+	// Skipped explains why the package is left alone.
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// UpgradePlan is the set of changes for one venv, along with the venv's
+// result once the plan has run.
+type UpgradePlan struct {
+	VenvResult
+	Changes []UpgradeChange `json:"changes"`
+	// Verification is set for upgrades run with UpgradeOptions.Verify.
+	Verification *UpgradeVerification `json:"verification,omitempty"`
+}
+
+// UpgradeVerification reports the checks run after a verified upgrade.
+type UpgradeVerification struct {
+	Command  string `json:"command"`
+	Snapshot string `json:"snapshot"`
+	// Changed lists every package the upgrade touched, dependencies included.
+	Changed []PackageChange `json:"changed"`
+	Passed  bool            `json:"passed"`
+	// FailedStep is "pip check" or "command" when verification failed.
+	FailedStep string `json:"failed_step,omitempty"`
+	// Output is the failed step's output, its last lines for the command.
+	Output     string `json:"output,omitempty"`
+	RolledBack bool   `json:"rolled_back"`
+	// RollbackError is set when the automatic rollback itself failed.
+	RollbackError string `json:"rollback_error,omitempty"`
+}
+
+// Pending returns the changes that would be applied.
+func (p *UpgradePlan) Pending() []UpgradeChange {
+	var out []UpgradeChange
+	for _, c := range p.Changes {
+		if c.Skipped == "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// outdatedPackage is one entry of `pip list --outdated --format=json`.
+type outdatedPackage struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	LatestVersion string `json:"latest_version"`
+}
+
+// Upgrade upgrades outdated packages in one or all venvs.
+func (m *Manager) Upgrade(name string) error {
+	plans, err := m.UpgradeWithOptions(name, UpgradeOptions{})
+	if err != nil {
+		return err
+	}
+	return UpgradeReport(plans).Err()
+}
+
+// UpgradeWithOptions plans the upgrade of one or all venvs and, unless
+// opts.DryRun, applies it. Per-venv and per-package failures are recorded
+// in the returned plans rather than aborting the run.
+func (m *Manager) UpgradeWithOptions(name string, opts UpgradeOptions) ([]*UpgradePlan, error) {
+	if opts.Only != "" && opts.Only != UpgradePatch && opts.Only != UpgradeMinor {
+		return nil, fmt.Errorf("invalid --only %q: want patch or minor", opts.Only)
+	}
+	targets, err := m.resolveTargets(name)
+	if err != nil {
+		return nil, err
+	}
+	// Venvs are upgraded concurrently; failures are recorded per plan, so
+	// one venv never stops the others.
+	plans := make([]*UpgradePlan, len(targets))
+	parallel(m.workers(len(targets)), len(targets), func(i int) {
+		plans[i] = m.upgradeVenv(targets[i], opts)
+	})
+	return plans, nil
+}
+
+func (m *Manager) upgradeVenv(venvPath string, opts UpgradeOptions) *UpgradePlan {
+	start := time.Now()
+	plan := m.runUpgrade(venvPath, opts)
+	finishUpgrade(plan, opts.DryRun)
+	plan.DurationMS = time.Since(start).Milliseconds()
+	return plan
+}
+
+func (m *Manager) runUpgrade(venvPath string, opts UpgradeOptions) *UpgradePlan {
+	venv := filepath.Base(venvPath)
+	outdated, err := m.listOutdated(venvPath)
+	if err != nil {
+		return &UpgradePlan{VenvResult: VenvResult{Venv: venv, Error: err.Error()}, Changes: []UpgradeChange{}}
+	}
+	plan := planUpgrade(venv, outdated, opts)
+	if opts.DryRun || len(plan.Pending()) == 0 {
+		return plan
+	}
+	unlock, err := m.lock(venv, "upgrade")
+	if err != nil {
+		plan.Error = err.Error()
+		return plan
+	}
+	defer unlock()
+	if opts.Verify != "" {
+		m.verifiedUpgrade(venvPath, plan, opts.Verify)
+		return plan
+	}
+	if _, err := m.autoSnapshotLocked(venvPath, venv, "upgrade"); err != nil {
+		plan.Error = err.Error()
+		return plan
+	}
+	m.applyUpgrade(venvPath, plan)
+	return plan
+}
+
+// applyUpgrade installs each pending change, recording failures on it and
+// the upgraded packages and pip's output tail on the plan.
+func (m *Manager) applyUpgrade(venvPath string, plan *UpgradePlan) {
+	var output []byte
+	for i := range plan.Changes {
+		c := &plan.Changes[i]
+		if c.Skipped != "" {
+			continue
+		}
+		out, err := m.runInstall(venvPath, "--upgrade", c.Target)
+		output = append(output, out...)
+		if err != nil {
+			c.Error = fmt.Sprintf("%v\n%s", err, out)
+			continue
+		}
+		plan.Packages = append(plan.Packages, c.Name)
+	}
+	plan.OutputTail = outputTail(output, outputTailLines)
+}
+
+// finishUpgrade sets the plan's status and summarises its failures.
+func finishUpgrade(plan *UpgradePlan, dryRun bool) {
+	var failed []string
+	for _, c := range plan.Changes {
+		if c.Error != "" {
+			failed = append(failed, c.Name)
+		}
+	}
+	if v := plan.Verification; v != nil {
+		plan.Packages = nil
+		for _, c := range v.Changed {
+			plan.Packages = append(plan.Packages, c.Name)
+		}
+		if !v.Passed && plan.Error == "" {
+			plan.Error = "verification failed at " + v.FailedStep
+			if v.RolledBack {
+				plan.Error += "; rolled back to snapshot " + v.Snapshot
+			} else if v.RollbackError != "" {
+				plan.Error += fmt.Sprintf("; rollback to snapshot %s failed: %s", v.Snapshot, v.RollbackError)
+			}
+		}
+	}
+	if plan.Error == "" && len(failed) > 0 {
+		plan.Error = "failed to upgrade " + strings.Join(failed, ", ")
+	}
+	switch {
+	case plan.Error != "":
+		plan.Status = StatusFailed
+	case dryRun:
+		plan.Status, plan.Note = StatusSkipped, "dry run"
+	case len(plan.Pending()) == 0:
+		plan.Status, plan.Note = StatusOK, "up to date"
+	default:
+		plan.Status = StatusOK
+	}
+}
+
+// verifiedUpgrade snapshots the venv, applies plan, then runs `pip check`
+// and command. On failure the venv is rolled back to the snapshot. The
+// command's output is captured rather than streamed, since venvs are
+// verified concurrently.
+func (m *Manager) verifiedUpgrade(venvPath string, plan *UpgradePlan, command string) {
+	venv := plan.Venv
+	snap, err := m.snapshotAt(venvPath, venv, SnapshotOptions{Label: "upgrade", Operation: "upgrade"})
+	if err != nil {
+		plan.Error = fmt.Sprintf("pre-upgrade snapshot failed: %v", err)
+		return
+	}
+	v := &UpgradeVerification{Command: command, Snapshot: snap.ID, Changed: []PackageChange{}}
+	plan.Verification = v
+	before, err := m.pipFreeze(venvPath)
+	if err != nil {
+		plan.Error = err.Error()
+		return
+	}
+	m.applyUpgrade(venvPath, plan)
+	if after, err := m.pipFreeze(venvPath); err == nil {
+		v.Changed = changedPackages(before, after)
+	}
+
+	if out, err := m.pkg(venvPath).check().CombinedOutput(); err != nil {
+		v.FailedStep, v.Output = "pip check", strings.TrimSpace(string(out))
+	} else if out, err := venvCommand(venvPath, shellArgv(command)).CombinedOutput(); err != nil {
+		v.FailedStep, v.Output = "command", fmt.Sprintf("%v\n%s", err, outputTail(out, outputTailLines))
+	} else {
+		v.Passed = true
+		return
+	}
+	if _, err := m.rollbackLocked(venvPath, venv, snap.ID); err != nil {
+		v.RollbackError = err.Error()
+		return
+	}
+	v.RolledBack = true
+}
+
+// changedPackages lists every package whose pin differs between two freezes.
+func changedPackages(before, after map[string]freezeEntry) []PackageChange {
+	d := diffStates(&envState{Packages: before}, &envState{Packages: after})
+	out := []PackageChange{}
+	for _, group := range [][]PackageChange{d.Upgraded, d.Downgraded, d.Changed, d.Added, d.Removed} {
+		out = append(out, group...)
+	}
+	sort.Slice(out, func(i, j int) bool { return normalizePkgName(out[i].Name) < normalizePkgName(out[j].Name) })
+	return out
+}
+
+// shellArgv wraps a command line for the platform shell.
+func shellArgv(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
+
+func (m *Manager) listOutdated(venvPath string) ([]outdatedPackage, error) {
+	output, err := m.pkg(venvPath).outdated().Output()
+	if err != nil {
+		return nil, fmt.Errorf("list failed: %v", err)
+	}
+	var pkgs []outdatedPackage
+	if err := json.Unmarshal(output, &pkgs); err != nil {
+		return nil, fmt.Errorf("parse failed: %v", err)
+	}
+	return pkgs, nil
+}
+
+// UpgradeReport gathers the per-venv results of upgrade plans.
+func UpgradeReport(plans []*UpgradePlan) *BulkReport {
+	results := make([]VenvResult, len(plans))
+	for i, p := range plans {
+		results[i] = p.VenvResult
+	}
+	return NewBulkReport("upgrade", results)
+}
+
+// planUpgrade classifies each outdated package and applies the filters.
+func planUpgrade(venv string, outdated []outdatedPackage, opts UpgradeOptions) *UpgradePlan {
+	include, exclude := nameSet(opts.Include), nameSet(opts.Exclude)
+	plan := &UpgradePlan{VenvResult: VenvResult{Venv: venv}, Changes: []UpgradeChange{}}
+	for _, o := range outdated {
+		c := UpgradeChange{Name: o.Name, Current: o.Version, Latest: o.LatestVersion}
+		c.Level = upgradeLevel(o.Version, o.LatestVersion)
+		c.Target = o.Name + "==" + o.LatestVersion
+		key := normalizePkgName(o.Name)
+		switch {
+		case len(include) > 0 && !include[key]:
+			c.Skipped = "not included"
+		case exclude[key]:
+			c.Skipped = "excluded"
+		case opts.Only != "" && upgradeRank[c.Level] > upgradeRank[opts.Only]:
+			bound := upgradeCap(o.Version, opts.Only)
+			if bound == "" {
+				c.Skipped = fmt.Sprintf("%s upgrade, --only %s", c.Level, opts.Only)
+				break
+			}
+			c.Capped = true
+			c.Target = fmt.Sprintf("%s>=%s,<%s", o.Name, o.Version, bound)
+		case opts.Only != "" && c.Level == UpgradeOther:
+			c.Skipped = fmt.Sprintf("unclassifiable version, --only %s", opts.Only)
+		}
+		plan.Changes = append(plan.Changes, c)
+	}
+	return plan
+}
+
+// upgradeLevel classifies the jump from current to latest by the first
+// release component that differs. An epoch change counts as major; changes
+// below the third component (post releases, 1.2.3.4) count as patch.
+func upgradeLevel(current, latest string) string {
+	cv, err := parseVersion(current)
+	if err != nil {
+		return UpgradeOther
+	}
+	lv, err := parseVersion(latest)
+	if err != nil {
+		return UpgradeOther
+	}
+	if cv.Epoch != lv.Epoch || cv.part(0) != lv.part(0) {
+		return UpgradeMajor
+	}
+	if cv.part(1) != lv.part(1) {
+		return UpgradeMinor
+	}
+	return UpgradePatch
+}
+
+// upgradeCap returns the first release a capped upgrade of current must stay
+// below: the next major for "minor", the next minor for "patch".
+func upgradeCap(current, only string) string {
+	v, err := parseVersion(current)
+	if err != nil || v.Epoch != 0 {
+		return ""
+	}
+	if only == UpgradeMinor {
+		return fmt.Sprintf("%d", v.part(0)+1)
+	}
+	return fmt.Sprintf("%d.%d", v.part(0), v.part(1)+1)
+}
+
+func nameSet(names []string) map[string]bool {
+	set := map[string]bool{}
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			set[normalizePkgName(n)] = true
+		}
+	}
+	return set
+}
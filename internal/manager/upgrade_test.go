@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpgradeLevel(t *testing.T) {
+	cases := []struct{ from, to, want string }{
+		{"1.2.3", "2.0.0", UpgradeMajor},
+		{"1.2.3", "1.3.0", UpgradeMinor},
+		{"1.2.3", "1.2.4", UpgradePatch},
+		{"1.2", "1.2.1", UpgradePatch},
+		{"1.2.3", "1.2.3.post1", UpgradePatch},
+		{"2023.1", "2024.1", UpgradeMajor},
+		{"1.0", "1!0.5", UpgradeMajor},
+		{"1.0rc1", "1.0", UpgradePatch},
+		{"weird-ver", "1.0", UpgradeOther},
+	}
+	for _, c := range cases {
+		if got := upgradeLevel(c.from, c.to); got != c.want {
+			t.Errorf("upgradeLevel(%s, %s)=%s want %s", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestPlanUpgrade(t *testing.T) {
+	outdated := []outdatedPackage{
+		{Name: "requests", Version: "2.31.0", LatestVersion: "3.0.0"},
+		{Name: "Django", Version: "4.2.1", LatestVersion: "4.2.9"},
+		{Name: "numpy", Version: "1.25.0", LatestVersion: "1.26.4"},
+		{Name: "odd", Version: "abc", LatestVersion: "def"},
+	}
+	byName := func(p *UpgradePlan) map[string]UpgradeChange {
+		out := map[string]UpgradeChange{}
+		for _, c := range p.Changes {
+			out[c.Name] = c
+		}
+		return out
+	}
+
+	all := byName(planUpgrade("v", outdated, UpgradeOptions{}))
+	if all["requests"].Target != "requests==3.0.0" || all["requests"].Level != UpgradeMajor || all["requests"].Skipped != "" {
+		t.Fatalf("unfiltered requests: %+v", all["requests"])
+	}
+
+	p := planUpgrade("v", outdated, UpgradeOptions{Only: UpgradePatch})
+	got := byName(p)
+	if c := got["requests"]; !c.Capped || c.Target != "requests>=2.31.0,<2.32" {
+		t.Fatalf("capped requests: %+v", c)
+	}
+	if c := got["numpy"]; !c.Capped || c.Target != "numpy>=1.25.0,<1.26" {
+		t.Fatalf("capped numpy: %+v", c)
+	}
+	if c := got["Django"]; c.Capped || c.Target != "Django==4.2.9" {
+		t.Fatalf("patch-level Django should go to latest: %+v", c)
+	}
+	if got["odd"].Skipped == "" {
+		t.Fatal("unclassifiable versions must be skipped under --only")
+	}
+	if n := len(p.Pending()); n != 3 {
+		t.Fatalf("pending=%d want 3", n)
+	}
+
+	got = byName(planUpgrade("v", outdated, UpgradeOptions{Only: UpgradeMinor, Exclude: []string{"NumPy"}}))
+	if c := got["requests"]; c.Target != "requests>=2.31.0,<3" {
+		t.Fatalf("minor cap: %+v", c)
+	}
+	if got["numpy"].Skipped != "excluded" {
+		t.Fatalf("exclude should match normalized names: %+v", got["numpy"])
+	}
+
+	got = byName(planUpgrade("v", outdated, UpgradeOptions{Include: []string{"django"}}))
+	if got["Django"].Skipped != "" || got["requests"].Skipped != "not included" {
+		t.Fatalf("include filter: %+v", got)
+	}
+}
+
+func TestUpgradeReport(t *testing.T) {
+	ok := []*UpgradePlan{{VenvResult: VenvResult{Venv: "a"}, Changes: []UpgradeChange{{Name: "x"}}}}
+	if err := UpgradeReport(ok).Err(); err != nil {
+		t.Fatal(err)
+	}
+	bad := []*UpgradePlan{
+		{VenvResult: VenvResult{Venv: "a", Error: "list failed"}},
+		{VenvResult: VenvResult{Venv: "b"}},
+	}
+	rep := UpgradeReport(bad)
+	if rep.Failed != 1 || rep.OK != 1 || rep.Results[0].Status != StatusFailed {
+		t.Fatalf("report=%+v", rep)
+	}
+	if err := rep.Err(); err == nil || !strings.Contains(err.Error(), "a: list failed") {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestFinishUpgrade(t *testing.T) {
+	plan := &UpgradePlan{Changes: []UpgradeChange{{Name: "x", Error: "boom"}, {Name: "y"}, {Name: "z", Skipped: "excluded"}}}
+	finishUpgrade(plan, false)
+	if plan.Status != StatusFailed || plan.Error != "failed to upgrade x" {
+		t.Fatalf("plan=%+v", plan.VenvResult)
+	}
+
+	plan = &UpgradePlan{Changes: []UpgradeChange{{Name: "y"}}}
+	finishUpgrade(plan, true)
+	if plan.Status != StatusSkipped || plan.Note != "dry run" {
+		t.Fatalf("dry run plan=%+v", plan.VenvResult)
+	}
+
+	plan = &UpgradePlan{Changes: []UpgradeChange{}}
+	finishUpgrade(plan, false)
+	if plan.Status != StatusOK || plan.Note != "up to date" {
+		t.Fatalf("up to date plan=%+v", plan.VenvResult)
+	}
+}
+
+func TestChangedPackages(t *testing.T) {
+	before := parseFreeze([]string{"a==1.0", "b==2.0", "gone==1.0"})
+	after := parseFreeze([]string{"a==1.1", "b==2.0", "c==0.1", "D==3.0"})
+	var got []string
+	for _, c := range changedPackages(before, after) {
+		got = append(got, c.Name+":"+c.From+">"+c.To)
+	}
+	want := "a:1.0>1.1,c:>0.1,D:>3.0,gone:1.0>"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("changed=%v want %s", got, want)
+	}
+}
+
+func TestFinishUpgradeReportsFailedVerification(t *testing.T) {
+	plan := &UpgradePlan{
+		Changes: []UpgradeChange{{Name: "a"}},
+		Verification: &UpgradeVerification{
+			FailedStep: "pip check", Snapshot: "s1", RolledBack: true,
+			Changed: []PackageChange{{Name: "a", From: "1.0", To: "2.0"}, {Name: "dep", From: "1.0", To: "1.1"}},
+		},
+	}
+	finishUpgrade(plan, false)
+	if plan.Status != StatusFailed || !strings.Contains(plan.Error, "rolled back to snapshot s1") {
+		t.Fatalf("plan=%+v", plan.VenvResult)
+	}
+	if strings.Join(plan.Packages, ",") != "a,dep" {
+		t.Fatalf("touched packages=%v", plan.Packages)
+	}
+
+	plan.Error, plan.Verification = "", &UpgradeVerification{Passed: true}
+	finishUpgrade(plan, false)
+	if plan.Status != StatusOK {
+		t.Fatalf("passed verification reported as failure: %+v", plan.VenvResult)
+	}
+}
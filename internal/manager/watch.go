@@ -3,14 +3,11 @@ package manager
 import (
 	"fmt"
 	"io"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-
-	"github.com/jacopobonomi/venv-manager/internal/utils"
 )
 
 // WatchOptions configures watch mode.
@@ -129,10 +126,18 @@ func (m *Manager) syncImports(path, venv string, logf func(string, ...any)) erro
 		logf("[%s] up to date (%d third-party imports)", time.Now().Format("15:04:05"), len(rep.ThirdParty))
 		return nil
 	}
+	unlock, err := m.lock(venv, "watch")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if snap, err := m.autoSnapshotLocked(m.VenvPath(venv), venv, "watch"); err != nil {
+		return err
+	} else if snap != nil {
+		logf("[%s] snapshot %s", time.Now().Format("15:04:05"), snap.ID)
+	}
 	logf("[%s] installing missing: %s", time.Now().Format("15:04:05"), strings.Join(rep.Missing, ", "))
-	pip := utils.PipPath(m.VenvPath(venv))
-	args := append([]string{"install"}, rep.Missing...)
-	out, err := exec.Command(pip, args...).CombinedOutput()
+	out, err := m.runInstall(m.VenvPath(venv), rep.Missing...)
 	if err != nil {
 		logf("pip install failed:\n%s", string(out))
 		return err
@@ -0,0 +1,186 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+// WheelhouseAddOptions configures WheelhouseAdd.
+type WheelhouseAddOptions struct {
+	// Build runs `pip wheel` instead of `pip download`, so sdist-only
+	// packages land in the wheelhouse as built wheels.
+	Build bool
+}
+
+// WheelhouseReport is the outcome of WheelhouseAdd.
+type WheelhouseReport struct {
+	Venv       string `json:"venv"`
+	Wheelhouse string `json:"wheelhouse"`
+	// Requirements is how many freeze pins were fetched.
+	Requirements int `json:"requirements"`
+	// Added are the file names new to the wheelhouse.
+	Added []string `json:"added"`
+	// Skipped are freeze lines that cannot be fetched from an index
+	// (editable installs, local paths).
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// WheelhouseFile is one distribution file in the wheelhouse.
+type WheelhouseFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Wheelhouse returns the wheelhouse directory.
+func (m *Manager) Wheelhouse() string { return m.wheelhouse }
+
+// SetOffline makes every install resolve packages from the wheelhouse only.
+func (m *Manager) SetOffline(offline bool) { m.offline = offline }
+
+// Offline reports whether installs are restricted to the wheelhouse.
+func (m *Manager) Offline() bool { return m.offline }
+
+// InstallCommand returns the installer command for `install args...` in
+// venvPath. In offline mode it is pointed at the wheelhouse with --no-index,
+// so nothing is fetched from PyPI.
+func (m *Manager) InstallCommand(venvPath string, args ...string) (*exec.Cmd, error) {
+	if m.offline {
+		if err := m.checkOffline(); err != nil {
+			return nil, err
+		}
+		args = append([]string{"--no-index", "--find-links", m.wheelhouse}, args...)
+	}
+	return m.pkg(venvPath).install(args...), nil
+}
+
+// checkOffline fails when offline mode is on but there is no wheelhouse,
+// so operations that create a venv can bail out before touching disk.
+func (m *Manager) checkOffline() error {
+	if !m.offline {
+		return nil
+	}
+	if fi, err := os.Stat(m.wheelhouse); err != nil || !fi.IsDir() {
+		return fmt.Errorf("offline mode: wheelhouse %s does not exist; fill it with 'venv-manager wheelhouse add <venv>'", m.wheelhouse)
+	}
+	return nil
+}
+
+// runInstall installs args in venvPath and returns the combined output.
+func (m *Manager) runInstall(venvPath string, args ...string) ([]byte, error) {
+	cmd, err := m.InstallCommand(venvPath, args...)
+	if err != nil {
+		return nil, err
+	}
+	return cmd.CombinedOutput()
+}
+
+// WheelhouseAdd fetches every package pinned in a venv's freeze into the
+// wheelhouse, with `pip download` (or `pip wheel` when opts.Build).
+// Dependencies are not resolved: the freeze is already complete.
+func (m *Manager) WheelhouseAdd(name string, opts WheelhouseAddOptions) (*WheelhouseReport, error) {
+	venvPath, err := m.requireVenv(name)
+	if err != nil {
+		return nil, err
+	}
+	if !fileExists(utils.PipPath(venvPath)) {
+		return nil, fmt.Errorf("'%s' has no pip; wheelhouse add needs pip download (install it with 'venv-manager install')", name)
+	}
+	freeze, err := m.pkg(venvPath).freeze().Output()
+	if err != nil {
+		return nil, fmt.Errorf("pip freeze failed: %v", err)
+	}
+	rep := &WheelhouseReport{Venv: name, Wheelhouse: m.wheelhouse, Added: []string{}}
+	pins, skipped := fetchablePins(string(freeze))
+	rep.Skipped = skipped
+	rep.Requirements = len(pins)
+	if len(pins) == 0 {
+		return rep, nil
+	}
+	if err := os.MkdirAll(m.wheelhouse, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create wheelhouse: %v", err)
+	}
+	before, err := m.WheelhouseList()
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "vm-wheelhouse-*.txt")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	tmp.WriteString(strings.Join(pins, "\n") + "\n")
+	tmp.Close()
+
+	args := []string{"download", "--no-deps", "-r", tmp.Name(), "-d", m.wheelhouse}
+	if opts.Build {
+		args = []string{"wheel", "--no-deps", "-r", tmp.Name(), "-w", m.wheelhouse}
+	}
+	if out, err := m.pipCmd(venvPath, args...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("pip %s failed: %v\n%s", args[0], err, out)
+	}
+	after, err := m.WheelhouseList()
+	if err != nil {
+		return nil, err
+	}
+	had := map[string]bool{}
+	for _, f := range before {
+		had[f.Name] = true
+	}
+	for _, f := range after {
+		if !had[f.Name] {
+			rep.Added = append(rep.Added, f.Name)
+		}
+	}
+	return rep, nil
+}
+
+// fetchablePins splits freeze output into pins an index can serve and lines
+// it cannot (editables, direct references to local files).
+func fetchablePins(freeze string) (pins, skipped []string) {
+	for _, ln := range strings.Split(freeze, "\n") {
+		ln = strings.TrimSpace(ln)
+		switch {
+		case ln == "" || strings.HasPrefix(ln, "#"):
+		case strings.HasPrefix(ln, "-e ") || strings.HasPrefix(ln, "--editable") || strings.Contains(ln, " @ file:"):
+			skipped = append(skipped, ln)
+		default:
+			pins = append(pins, ln)
+		}
+	}
+	return pins, skipped
+}
+
+// WheelhouseList returns the distribution files in the wheelhouse, sorted
+// by name. A missing wheelhouse is empty.
+func (m *Manager) WheelhouseList() ([]WheelhouseFile, error) {
+	entries, err := os.ReadDir(m.wheelhouse)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []WheelhouseFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, WheelhouseFile{Name: e.Name(), Size: info.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+func defaultWheelhouse(baseDir string) string {
+	return filepath.Join(baseDir, ".venv-manager", "wheelhouse")
+}
@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jacopobonomi/venv-manager/internal/utils"
+)
+
+func TestInstallArgsOffline(t *testing.T) {
+	m, dir := newTestMgr(t)
+	venv := filepath.Join(dir, "a")
+	m.installer = pipInstaller{}
+	os.MkdirAll(filepath.Dir(utils.PipPath(venv)), 0o755)
+	os.WriteFile(utils.PipPath(venv), nil, 0o755)
+	if cmd, _ := m.InstallCommand(venv, "-r", "req.txt"); !reflect.DeepEqual(cmd.Args[1:], []string{"install", "-r", "req.txt"}) {
+		t.Fatalf("online args=%v", cmd.Args)
+	}
+	m.SetOffline(true)
+	if _, err := m.InstallCommand(venv, "x"); err == nil {
+		t.Fatal("offline install without a wheelhouse should fail")
+	}
+	wh := filepath.Join(dir, ".venv-manager", "wheelhouse")
+	if m.Wheelhouse() != wh {
+		t.Fatalf("wheelhouse=%s", m.Wheelhouse())
+	}
+	os.MkdirAll(wh, 0o755)
+	cmd, err := m.InstallCommand(venv, "x==1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"install", "--no-index", "--find-links", wh, "x==1"}
+	if got := cmd.Args[1:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("offline args=%v, want %v", got, want)
+	}
+	if venvs, _ := m.List(); !reflect.DeepEqual(venvs, []string{"a"}) {
+		t.Fatalf("wheelhouse listed as a venv: %v", venvs)
+	}
+}
+
+func TestFetchablePins(t *testing.T) {
+	freeze := "alpha==1.0\n-e git+https://example.com/x.git#egg=x\nlocal @ file:///tmp/local\n# comment\n\nbeta==2.0\n"
+	pins, skipped := fetchablePins(freeze)
+	if !reflect.DeepEqual(pins, []string{"alpha==1.0", "beta==2.0"}) {
+		t.Fatalf("pins=%v", pins)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("skipped=%v", skipped)
+	}
+}
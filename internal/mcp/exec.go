@@ -18,8 +18,19 @@ func (s *Server) installPackages(name string, packages []string) (string, error)
 	if err != nil {
 		return "", err
 	}
-	args := append([]string{"install"}, packages...)
-	out, err := exec.Command(utils.PipPath(venvPath), args...).CombinedOutput()
+	unlock, err := s.mgr.LockVenv(name, "install")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if _, err := s.mgr.AutoSnapshotLocked(name, "install"); err != nil {
+		return "", err
+	}
+	cmd, err := s.mgr.InstallCommand(venvPath, packages...)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return string(out), fmt.Errorf("pip install failed: %v", err)
 	}
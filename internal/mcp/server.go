@@ -220,6 +220,7 @@ func toolCatalog() []toolDef {
 				"properties": map[string]any{
 					"name":  strProp("venv name"),
 					"label": strProp("optional label (e.g. 'pre-upgrade')"),
+					"full":  map[string]any{"type": "boolean", "description": "also archive site-packages so rollback works offline"},
 				},
 			},
 		},
@@ -233,12 +234,35 @@ func toolCatalog() []toolDef {
 		},
 		{
 			Name:        "rollback_venv",
-			Description: "Restore a venv to a previous snapshot. Omit snapshot_id to restore the most recent.",
+			Description: "Restore a venv to a previous snapshot by applying only the package delta. Omit snapshot_id to restore the most recent. Set plan to preview the delta without changing anything.",
 			InputSchema: map[string]any{
 				"type": "object", "required": []string{"name"},
 				"properties": map[string]any{
 					"name":        strProp("venv name"),
 					"snapshot_id": strProp("snapshot id (see list_snapshots)"),
+					"plan":        map[string]any{"type": "boolean", "description": "only return the uninstall/install plan"},
+				},
+			},
+		},
+		{
+			Name:        "dependency_graph",
+			Description: "Return the installed dependency graph of a venv (Requires-Dist with markers evaluated). Pass 'package' to instead get which top-level packages pull that package in, and the version constraints they impose.",
+			InputSchema: map[string]any{
+				"type": "object", "required": []string{"name"},
+				"properties": map[string]any{
+					"name":    strProp("venv name"),
+					"package": strProp("optional package to explain (like `venv-manager why`)"),
+				},
+			},
+		},
+		{
+			Name:        "diff_envs",
+			Description: "Compare two environments and list packages added, removed, upgraded and downgraded plus any Python version change. Each side is a venv name, 'snapshot:<venv>/<id>', or a manifest JSON path.",
+			InputSchema: map[string]any{
+				"type": "object", "required": []string{"a", "b"},
+				"properties": map[string]any{
+					"a": strProp("left side (venv name, snapshot:<venv>/<id>, or manifest path)"),
+					"b": strProp("right side (venv name, snapshot:<venv>/<id>, or manifest path)"),
 				},
 			},
 		},
@@ -361,7 +385,8 @@ func (s *Server) dispatch(name string, args map[string]any) (string, error) {
 		return toJSON(s.mgr.Doctor()), nil
 
 	case "snapshot_venv":
-		snap, err := s.mgr.CreateSnapshot(str(args, "name"), str(args, "label"))
+		full, _ := args["full"].(bool)
+		snap, err := s.mgr.CreateSnapshotWithOptions(str(args, "name"), manager.SnapshotOptions{Label: str(args, "label"), Full: full})
 		if err != nil {
 			return "", err
 		}
@@ -375,12 +400,40 @@ func (s *Server) dispatch(name string, args map[string]any) (string, error) {
 		return toJSON(snaps), nil
 
 	case "rollback_venv":
+		if plan, _ := args["plan"].(bool); plan {
+			p, err := s.mgr.PlanRollback(str(args, "name"), str(args, "snapshot_id"))
+			if err != nil {
+				return "", err
+			}
+			return toJSON(p), nil
+		}
 		snap, err := s.mgr.Rollback(str(args, "name"), str(args, "snapshot_id"))
 		if err != nil {
 			return "", err
 		}
 		return toJSON(snap), nil
 
+	case "dependency_graph":
+		if pkg := str(args, "package"); pkg != "" {
+			rep, err := s.mgr.Why(str(args, "name"), pkg)
+			if err != nil {
+				return "", err
+			}
+			return toJSON(rep), nil
+		}
+		g, err := s.mgr.DependencyGraph(str(args, "name"))
+		if err != nil {
+			return "", err
+		}
+		return toJSON(g), nil
+
+	case "diff_envs":
+		d, err := s.mgr.Diff(str(args, "a"), str(args, "b"))
+		if err != nil {
+			return "", err
+		}
+		return toJSON(d), nil
+
 	case "scan_imports":
 		rep, err := s.mgr.Scan(str(args, "path"), str(args, "venv"))
 		if err != nil {
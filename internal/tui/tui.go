@@ -119,7 +119,12 @@ func (m Model) refreshCmd() tea.Cmd {
 		if err != nil {
 			return refreshedMsg{err: err}
 		}
-		sizes, _ := m.mgr.GetSize("")
+		sizes := map[string]int64{}
+		if rep, err := m.mgr.GetSize(""); err == nil {
+			for _, res := range rep.Results {
+				sizes[res.Venv] = res.SizeBytes
+			}
+		}
 		items := make([]list.Item, 0, len(names))
 		for _, n := range names {
 			items = append(items, venvItem{name: n, size: sizes[n]})
@@ -134,8 +139,11 @@ func (m Model) detailsCmd(name string) tea.Cmd {
 		if err != nil {
 			return detailsMsg{name: name, err: err}
 		}
-		sizes, _ := m.mgr.GetSize(name)
-		return detailsMsg{name: name, packages: pkgs, size: sizes[name]}
+		var size int64
+		if rep, err := m.mgr.GetSize(name); err == nil {
+			size = rep.Results[0].SizeBytes
+		}
+		return detailsMsg{name: name, packages: pkgs, size: size}
 	}
 }
 
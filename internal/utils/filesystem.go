@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 )
@@ -12,7 +13,10 @@ type FileSystem interface {
 	Exists(path string) bool
 	IsDir(path string) bool
 	ReadDir(path string) ([]os.DirEntry, error)
-	GetDirSize(path string) (int64, error)
+	// GetDirSize returns the bytes of the single-link files under path and,
+	// per inode, the size of files with several hardlinks, so a caller
+	// measuring several trees can count each shared inode once.
+	GetDirSize(path string) (int64, map[FileID]int64, error)
 }
 
 type RealFileSystem struct{}
@@ -43,18 +47,29 @@ func (fs *RealFileSystem) ReadDir(path string) ([]os.DirEntry, error) {
 	return os.ReadDir(path)
 }
 
-func (fs *RealFileSystem) GetDirSize(path string) (int64, error) {
+// FileID identifies a file on disk; hardlinks share one.
+type FileID struct {
+	Dev, Ino uint64
+}
+
+func (fs *RealFileSystem) GetDirSize(path string) (int64, map[FileID]int64, error) {
 	var size int64
+	shared := map[FileID]int64{}
 	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() {
-			size += info.Size()
+		if info.IsDir() {
+			return nil
+		}
+		if id, nlink, ok := StatFileID(info); ok && nlink > 1 && info.Mode().IsRegular() {
+			shared[id] = info.Size()
+			return nil
 		}
+		size += info.Size()
 		return nil
 	})
-	return size, err
+	return size, shared, err
 }
 
 func FormatSize(bytes int64) string {
@@ -73,3 +88,51 @@ func FormatSize(bytes int64) string {
 	}
 	return fmt.Sprintf("%.2f GB", float64(bytes)/GB)
 }
+
+// CopyDir copies the tree at src to dst, preserving file modes and
+// recreating symlinks as they are.
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
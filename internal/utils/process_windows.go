@@ -0,0 +1,19 @@
+//go:build windows
+
+package utils
+
+import "os"
+
+// ProcessAlive reports whether a process with this pid exists. On Windows
+// FindProcess opens the process, which fails once it has exited.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}